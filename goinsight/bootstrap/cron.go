@@ -4,6 +4,7 @@ import (
 	"goInsight/global"
 	commonTasks "goInsight/internal/common/tasks"
 	dasTasks "goInsight/internal/das/tasks"
+	ordersTasks "goInsight/internal/orders/tasks"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -29,6 +30,28 @@ func InitializeCron() {
 		if err != nil {
 			global.App.Log.Error(err)
 		}
+
+		// 清理过期的导出文件
+		cleanExportFiles := global.App.Config.Crontab.CleanExportFiles
+		if cleanExportFiles == "" {
+			cleanExportFiles = "0 * * * *"
+		}
+		_, err = global.App.Cron.AddFunc(cleanExportFiles, func() {
+			global.App.Log.Info("Run CleanExpiredExportFiles At:", time.Now())
+			ordersTasks.CleanExpiredExportFiles()
+		})
+		if err != nil {
+			global.App.Log.Error(err)
+		}
+
+		// 清理过期的回滚SQL文件，和导出文件使用相同的周期
+		_, err = global.App.Cron.AddFunc(cleanExportFiles, func() {
+			global.App.Log.Info("Run CleanExpiredRollbackFiles At:", time.Now())
+			ordersTasks.CleanExpiredRollbackFiles()
+		})
+		if err != nil {
+			global.App.Log.Error(err)
+		}
 		global.App.Cron.Start()
 		defer global.App.Cron.Stop()
 		select {}
@@ -94,6 +94,8 @@ func initializeTables(db *gorm.DB) {
 		&usersModels.InsightRoles{},
 		&usersModels.InsightOrganizations{},
 		&usersModels.InsightOrganizationsUsers{},
+		&usersModels.InsightAccessTokens{},
+		&usersModels.InsightAccessTokenLogs{},
 		// common
 		&commonModels.InsightDBEnvironments{},
 		&commonModels.InsightDBConfig{},
@@ -111,6 +113,19 @@ func initializeTables(db *gorm.DB) {
 		&ordersModels.InsightOrderTasks{},
 		&ordersModels.InsightOrderOpLogs{},
 		&ordersModels.InsightOrderMessages{},
+		&ordersModels.InsightFlashbackRecords{},
+		&ordersModels.InsightExportFiles{},
+		&ordersModels.InsightExportDownloadLogs{},
+		&ordersModels.InsightDataMaskingRules{},
+		&ordersModels.InsightOrderTemplates{},
+		&ordersModels.InsightSQLSnippets{},
+		&ordersModels.InsightMigrationHistory{},
+		&ordersModels.InsightOrderComments{},
+		&ordersModels.InsightOrderRevisions{},
+		&ordersModels.InsightOrderVerifications{},
+		&ordersModels.InsightOrderDryRuns{},
+		&ordersModels.InsightOrderWebhooks{},
+		&ordersModels.InsightOrderWebhookDeliveries{},
 	)
 	if err != nil {
 		global.App.Log.Fatal("migrate table failed", err.Error())
@@ -13,7 +13,8 @@ type App struct {
 }
 
 type Crontab struct {
-	SyncDBMetas string `mapstructure:"sync_db_metas" json:"sync_db_metas" yaml:"sync_db_metas"`
+	SyncDBMetas      string `mapstructure:"sync_db_metas" json:"sync_db_metas" yaml:"sync_db_metas"`
+	CleanExportFiles string `mapstructure:"clean_export_files" json:"clean_export_files" yaml:"clean_export_files"`
 }
 
 type Log struct {
@@ -55,10 +56,68 @@ type Das struct {
 }
 
 type Ghost struct {
+	Path            string   `mapstructure:"path" json:"path" yaml:"path"`
+	Args            []string `mapstructure:"args" json:"args" yaml:"args"`
+	PostponeCutOver bool     `mapstructure:"postpone_cut_over" json:"postpone_cut_over" yaml:"postpone_cut_over"`
+}
+
+type PtOSC struct {
 	Path string   `mapstructure:"path" json:"path" yaml:"path"`
 	Args []string `mapstructure:"args" json:"args" yaml:"args"`
 }
 
+type OSC struct {
+	Engine             string `mapstructure:"engine" json:"engine" yaml:"engine"`
+	Tool               string `mapstructure:"tool" json:"tool" yaml:"tool"`
+	NativeMaxTableSize int64  `mapstructure:"native_max_table_size" json:"native_max_table_size" yaml:"native_max_table_size"`
+}
+
+type BatchDML struct {
+	ChunkSize         int64 `mapstructure:"chunk_size" json:"chunk_size" yaml:"chunk_size"`
+	SleepMilliseconds int   `mapstructure:"sleep_ms" json:"sleep_ms" yaml:"sleep_ms"`
+}
+
+type ExecuteGuard struct {
+	Enable            bool   `mapstructure:"enable" json:"enable" yaml:"enable"`
+	Action            string `mapstructure:"action" json:"action" yaml:"action"`
+	CheckInterval     int    `mapstructure:"check_interval" json:"check_interval" yaml:"check_interval"`
+	MaxWait           int    `mapstructure:"max_wait" json:"max_wait" yaml:"max_wait"`
+	MaxThreadsRunning int    `mapstructure:"max_threads_running" json:"max_threads_running" yaml:"max_threads_running"`
+	MaxReplicaLag     int    `mapstructure:"max_replica_lag" json:"max_replica_lag" yaml:"max_replica_lag"`
+	MaxTrxSeconds     int    `mapstructure:"max_trx_seconds" json:"max_trx_seconds" yaml:"max_trx_seconds"`
+}
+
+type S3 struct {
+	Endpoint  string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`
+	Region    string `mapstructure:"region" json:"region" yaml:"region"`
+	Bucket    string `mapstructure:"bucket" json:"bucket" yaml:"bucket"`
+	Prefix    string `mapstructure:"prefix" json:"prefix" yaml:"prefix"`
+	AccessKey string `mapstructure:"access_key" json:"access_key" yaml:"access_key"`
+	SecretKey string `mapstructure:"secret_key" json:"secret_key" yaml:"secret_key"`
+	UseSSL    bool   `mapstructure:"use_ssl" json:"use_ssl" yaml:"use_ssl"`
+}
+
+type Export struct {
+	Storage       string `mapstructure:"storage" json:"storage" yaml:"storage"`
+	LocalDir      string `mapstructure:"local_dir" json:"local_dir" yaml:"local_dir"`
+	RetentionDays int    `mapstructure:"retention_days" json:"retention_days" yaml:"retention_days"`
+	MaxDownloads  int    `mapstructure:"max_downloads" json:"max_downloads" yaml:"max_downloads"`
+	S3            S3     `mapstructure:"s3" json:"s3" yaml:"s3"`
+}
+
+type Masking struct {
+	HashSecret string `mapstructure:"hash_secret" json:"hash_secret" yaml:"hash_secret"`
+}
+
+type Rollback struct {
+	RetentionDays int `mapstructure:"retention_days" json:"retention_days" yaml:"retention_days"`
+}
+
+type Migration struct {
+	RepoRoot string `mapstructure:"repo_root" json:"repo_root" yaml:"repo_root"`
+	GitPath  string `mapstructure:"git_path" json:"git_path" yaml:"git_path"`
+}
+
 type Notify struct {
 	NoticeURL string `mapstructure:"notice_url" json:"notice_url" yaml:"notice_url"`
 	Wechat    struct {
@@ -80,16 +139,24 @@ type Notify struct {
 }
 
 type Configuration struct {
-	App      App      `mapstructure:"app" json:"app" yaml:"app"`
-	Crontab  Crontab  `mapstructure:"crontab" json:"crontab" yaml:"crontab"`
-	Log      Log      `mapstructure:"log" json:"log" yaml:"log"`
-	Database Database `mapstructure:"database" json:"database" yaml:"database"`
-	Redis    Redis    `mapstructure:"redis" json:"redis" yaml:"redis"`
-	RemoteDB RemoteDB `mapstructure:"remotedb" json:"remotedb" yaml:"remotedb"`
-	Das      Das      `mapstructure:"das" json:"das" yaml:"das"`
-	Ghost    Ghost    `mapstructure:"ghost" json:"ghost" yaml:"ghost"`
-	Notify   Notify   `mapstructure:"notify" json:"notify" yaml:"notify"`
-	LDAP     LDAP     `mapstructure:"ldap" json:"ldap" yaml:"ldap"`
+	App       App          `mapstructure:"app" json:"app" yaml:"app"`
+	Crontab   Crontab      `mapstructure:"crontab" json:"crontab" yaml:"crontab"`
+	Log       Log          `mapstructure:"log" json:"log" yaml:"log"`
+	Database  Database     `mapstructure:"database" json:"database" yaml:"database"`
+	Redis     Redis        `mapstructure:"redis" json:"redis" yaml:"redis"`
+	RemoteDB  RemoteDB     `mapstructure:"remotedb" json:"remotedb" yaml:"remotedb"`
+	Das       Das          `mapstructure:"das" json:"das" yaml:"das"`
+	Ghost     Ghost        `mapstructure:"ghost" json:"ghost" yaml:"ghost"`
+	PtOSC     PtOSC        `mapstructure:"ptosc" json:"ptosc" yaml:"ptosc"`
+	OSC       OSC          `mapstructure:"osc" json:"osc" yaml:"osc"`
+	BatchDML  BatchDML     `mapstructure:"batch_dml" json:"batch_dml" yaml:"batch_dml"`
+	Guard     ExecuteGuard `mapstructure:"execute_guard" json:"execute_guard" yaml:"execute_guard"`
+	Export    Export       `mapstructure:"export" json:"export" yaml:"export"`
+	Rollback  Rollback     `mapstructure:"rollback" json:"rollback" yaml:"rollback"`
+	Masking   Masking      `mapstructure:"masking" json:"masking" yaml:"masking"`
+	Migration Migration    `mapstructure:"migration" json:"migration" yaml:"migration"`
+	Notify    Notify       `mapstructure:"notify" json:"notify" yaml:"notify"`
+	LDAP      LDAP         `mapstructure:"ldap" json:"ldap" yaml:"ldap"`
 }
 
 type LDAP struct {
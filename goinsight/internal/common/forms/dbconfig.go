@@ -18,6 +18,8 @@ type AdminCreateDBConfigForm struct {
 	UserName        string                 `form:"user_name"  json:"user_name" binding:"required,min=2,max=128"`
 	Password        string                 `form:"password"  json:"password" binding:"required,min=2,max=256"`
 	InspectParams   map[string]interface{} `form:"inspect_params" json:"inspect_params"`
+	Replicas        []string               `form:"replicas" json:"replicas" binding:"dive,hostname_port"`
+	OSCEngine       string                 `form:"osc_engine" json:"osc_engine" binding:"omitempty,oneof=auto gh-ost pt-osc native"`
 	UseType         models.EnumType        `form:"use_type"  json:"use_type" binding:"required,oneof=查询 工单"`
 	DbType          models.EnumType        `form:"db_type"  json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
 	Environment     int                    `form:"environment"  json:"environment" binding:"required"`
@@ -31,6 +33,8 @@ type AdminUpdateDBConfigForm struct {
 	UserName        string                 `form:"user_name"  json:"user_name" binding:"required,min=2,max=128"`
 	Password        string                 `form:"password"  json:"password"`
 	InspectParams   map[string]interface{} `form:"inspect_params" json:"inspect_params"`
+	Replicas        []string               `form:"replicas" json:"replicas" binding:"dive,hostname_port"`
+	OSCEngine       string                 `form:"osc_engine" json:"osc_engine" binding:"omitempty,oneof=auto gh-ost pt-osc native"`
 	UseType         models.EnumType        `form:"use_type"  json:"use_type" binding:"required,oneof=查询 工单"`
 	DbType          models.EnumType        `form:"db_type"  json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
 	Environment     int                    `form:"environment"  json:"environment" binding:"required"`
@@ -28,6 +28,8 @@ type InsightDBConfig struct {
 	DbType           EnumType       `gorm:"type:ENUM('MySQL', 'TiDB', 'ClickHouse');default:MySQL;comment:数据库类型" json:"db_type"`
 	Environment      int            `gorm:"type:int;null;default:null;comment:环境;index" json:"environment"`
 	InspectParams    datatypes.JSON `gorm:"type:json;null;default:null;comment:语法审核参数" json:"inspect_params"`
+	Replicas         datatypes.JSON `gorm:"type:json;null;default:null;comment:从库列表(host:port)" json:"replicas"`
+	OSCEngine        string         `gorm:"type:varchar(16);not null;default:'';comment:ALTER TABLE使用的OSC引擎，为空时使用全局配置" json:"osc_engine"`
 	OrganizationKey  string         `gorm:"type:varchar(256);not null;index:organization_key;comment:搜索路径" json:"organization_key"`
 	OrganizationPath datatypes.JSON `gorm:"type:json;null;default:null;comment:绝对路径" json:"organization_path"`
 	Remark           string         `gorm:"type:varchar(256);not null;default:'';comment:备注" json:"remark"`
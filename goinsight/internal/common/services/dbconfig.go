@@ -32,7 +32,7 @@ func (s *AdminGetDBConfigServices) Run() (responseData interface{}, total int64,
 		OrganizationKey  string `json:"organization_key"`
 	}
 	var dbs []DBConfig
-	tx := global.App.DB.Select(`a.id,a.instance_id,a.hostname,a.port,a.user_name,a.use_type,a.db_type,a.inspect_params,a.organization_path,b.id as environment, 
+	tx := global.App.DB.Select(`a.id,a.instance_id,a.hostname,a.port,a.user_name,a.use_type,a.db_type,a.inspect_params,a.replicas,a.osc_engine,a.organization_path,b.id as environment, 
 							b.name as environment_name, a.remark, ifnull(
 								concat(
 									(
@@ -94,6 +94,12 @@ func (s *AdminCreateDBConfigService) Run() error {
 	if err != nil {
 		return err
 	}
+
+	// 从库列表
+	jsonReplicas, err := json.Marshal(s.Replicas)
+	if err != nil {
+		return err
+	}
 	// 新增记录
 	db := models.InsightDBConfig{
 		Hostname:         s.Hostname,
@@ -101,6 +107,8 @@ func (s *AdminCreateDBConfigService) Run() error {
 		UserName:         s.UserName,
 		Password:         s.Password,
 		InspectParams:    datatypes.JSON(jsonInspectParams),
+		Replicas:         datatypes.JSON(jsonReplicas),
+		OSCEngine:        s.OSCEngine,
 		UseType:          s.UseType,
 		DbType:           s.DbType,
 		Environment:      s.Environment,
@@ -143,12 +151,20 @@ func (s *AdminUpdateDBConfigService) Run() error {
 		return err
 	}
 
+	// 从库列表
+	jsonReplicas, err := json.Marshal(s.Replicas)
+	if err != nil {
+		return err
+	}
+
 	// 准备更新的数据
 	updates := map[string]interface{}{
 		"hostname":          s.Hostname,
 		"port":              s.Port,
 		"user_name":         s.UserName,
 		"inspect_params":    datatypes.JSON(jsonInspectParams),
+		"replicas":          datatypes.JSON(jsonReplicas),
+		"osc_engine":        s.OSCEngine,
 		"use_type":          s.UseType,
 		"db_type":           s.DbType,
 		"environment":       s.Environment,
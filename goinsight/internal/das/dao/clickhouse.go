@@ -57,7 +57,17 @@ func (c *ClickhouseDB) connect() (driver.Conn, error) {
 	return conn, nil
 }
 
-func (c *ClickhouseDB) Query(query string) (*[]string, *[]map[string]interface{}, error) {
+// 执行不返回结果集的语句
+func (c *ClickhouseDB) Exec(query string, args ...interface{}) error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Exec(c.Ctx, query, args...)
+}
+
+func (c *ClickhouseDB) Query(query string, args ...interface{}) (*[]string, *[]map[string]interface{}, error) {
 	// 连接到db
 	conn, err := c.connect()
 	if err != nil {
@@ -65,7 +75,7 @@ func (c *ClickhouseDB) Query(query string) (*[]string, *[]map[string]interface{}
 	}
 	defer conn.Close()
 	// 执行查询
-	rows, err := conn.Query(c.Ctx, query)
+	rows, err := conn.Query(c.Ctx, query, args...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -89,19 +99,56 @@ func (c *ClickhouseDB) Query(query string) (*[]string, *[]map[string]interface{}
 			if _, ok := vmap[columns[i]]; ok {
 				columns[i] = fmt.Sprintf("%s_%s[别名]", columns[i], uuid.New())
 			}
-			var newV string
-			// 类型断言
-			switch v := v.(type) {
-			case *string:
-				newV = *v
-			case *time.Time:
-				newV = fmt.Sprintf("%v", v.Format("2006-01-02 15:04:05"))
-			default:
-				newV = cast.ToString(v)
-			}
-			vmap[columns[i]] = newV
+			vmap[columns[i]] = toString(v)
 		}
 		resultSlice = append(resultSlice, vmap)
 	}
 	return &columns, &resultSlice, nil
 }
+
+func toString(v interface{}) string {
+	// 类型断言
+	switch v := v.(type) {
+	case *string:
+		return *v
+	case *time.Time:
+		return fmt.Sprintf("%v", v.Format("2006-01-02 15:04:05"))
+	default:
+		return cast.ToString(v)
+	}
+}
+
+// 流式读取查询结果，获取列名后调用onColumns，每读取一行调用一次onRow，避免大结果集全部加载到内存
+func (c *ClickhouseDB) QueryStream(query string, onColumns func(columns []string), onRow func(row []interface{}) error) error {
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rows, err := conn.Query(c.Ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns := rows.Columns()
+	columnTypes := rows.ColumnTypes()
+	onColumns(columns)
+
+	vals := make([]interface{}, len(columns))
+	for i := range columnTypes {
+		vals[i] = reflect.New(columnTypes[i].ScanType()).Interface()
+	}
+	for rows.Next() {
+		if err := rows.Scan(vals...); err != nil {
+			return err
+		}
+		row := make([]interface{}, len(vals))
+		for i, v := range vals {
+			row[i] = toString(v)
+		}
+		if err := onRow(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -7,6 +7,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"goInsight/global"
@@ -68,6 +69,11 @@ func (s *ExecuteMySQLQueryService) validateStatementType(stmt ast.StmtNode) erro
 }
 
 func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
+	return s.Execute(s.C.Request.Context(), requestid.Get(s.C))
+}
+
+// Execute 检查权限后执行查询并记录到insight_das_records，供没有请求上下文的调用方（例如工单的校验查询）使用
+func (s *ExecuteMySQLQueryService) Execute(ctx context.Context, requestID string) (ResponseData, error) {
 	// 初始化SQLText为用户输入的SQLText
 	var responseData ResponseData = ResponseData{SQLText: s.SQLText}
 	var data *[]map[string]interface{}
@@ -84,7 +90,7 @@ func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
 		Username:  s.Username,
 		Schema:    s.Schema,
 		Sqltext:   s.SQLText,
-		RequestID: requestid.Get(s.C),
+		RequestID: requestID,
 		Params:    datatypes.JSON(jsonParams),
 	}
 	global.App.DB.Create(&record)
@@ -95,7 +101,7 @@ func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
 	}
 	// 更新InstanceID
 	global.App.DB.Model(&models.InsightDASRecords{}).
-		Where("request_id=? and username=?", requestid.Get(s.C), s.Username).
+		Where("request_id=? and username=?", requestID, s.Username).
 		Update("InstanceID", uuid)
 	// 根据不同的db类型调用不同的处理逻辑
 	DbType, err := GetDbType(s.InstanceID)
@@ -127,7 +133,7 @@ func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
 	responseData.QueryID = fingerId
 	// 更新QueryID
 	global.App.DB.Model(&models.InsightDASRecords{}).
-		Where("request_id=? and username=?", requestid.Get(s.C), s.Username).
+		Where("request_id=? and username=?", requestID, s.Username).
 		Update("QueryID", fingerId)
 	// 检查语句类型，判断语句类型是否被允许执行
 	err = s.validateStatementType(singleStmt)
@@ -143,7 +149,7 @@ func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
 	}
 	// 更新表名
 	global.App.DB.Model(&models.InsightDASRecords{}).
-		Where("request_id=? and username=?", requestid.Get(s.C), s.Username).
+		Where("request_id=? and username=?", requestID, s.Username).
 		Update("Tables", strings.Join(tmpExtractTables, ";"))
 	// 检查库表权限
 	checker := CheckUserPerm{
@@ -160,23 +166,23 @@ func (s *ExecuteMySQLQueryService) Run() (ResponseData, error) {
 		return responseData, err
 	}
 	// 重写SQL，增加hint和重写limit
-	rewrite := parser.Rewrite{Stmt: singleStmt, RequestID: requestid.Get(s.C), DbType: DbType}
+	rewrite := parser.Rewrite{Stmt: singleStmt, RequestID: requestID, DbType: DbType}
 	SQLText := rewrite.Run()
 	s.SQLText = SQLText
 	responseData.SQLText = s.SQLText
 	// 更新rewrite sql
 	global.App.DB.Model(&models.InsightDASRecords{}).
-		Where("request_id=? and username=?", requestid.Get(s.C), s.Username).
+		Where("request_id=? and username=?", requestID, s.Username).
 		Update("RewriteSqltext", SQLText)
 	// 调用mysql和tidb执行接口
-	var executeApi ExecuteApi = MySQLExecuteApi{ExecuteMySQLQueryForm: s.ExecuteMySQLQueryForm, Ctx: s.C.Request.Context()}
+	var executeApi ExecuteApi = MySQLExecuteApi{ExecuteMySQLQueryForm: s.ExecuteMySQLQueryForm, Ctx: ctx}
 	columns, data, duration, err := CalculateDuration(hostname, port, username, password, executeApi.Execute)
 	if err != nil {
 		return responseData, err
 	}
 	// 更新耗时和影响行数
 	global.App.DB.Model(&models.InsightDASRecords{}).
-		Where("request_id=? and username=?", requestid.Get(s.C), s.Username).
+		Where("request_id=? and username=?", requestID, s.Username).
 		Updates(map[string]interface{}{"ReturnRows": len(*data), "Duration": duration})
 	responseData.Duration = fmt.Sprintf("%dms", duration)
 	responseData.Data = data
@@ -1,39 +1,88 @@
 package base
 
+import "fmt"
+
 // DBConfig holds the configuration details for connecting to a database and executing a SQL task.
 type DBConfig struct {
-	Hostname         string // The hostname of the database server.
-	Port             uint16 // The port number on which the database server is listening.
-	Charset          string // The character set to be used for the database connection.
-	UserName         string // The username for authenticating with the database.
-	Password         string // The password for authenticating with the database.
-	Schema           string // The database schema to be used.
-	DBType           string // The type of the database (e.g., MySQL, PostgreSQL).
-	SQLType          string // The type of the SQL operation (e.g., SELECT, INSERT).
-	SQL              string // The SQL query or command to be executed.
-	OrderID          string // An identifier for the order related to the SQL task.
-	TaskID           string // An identifier for the specific task to be executed.
-	ExportFileFormat string // The format for exporting the data (e.g., CSV, JSON).
+	Hostname         string        // The hostname of the database server.
+	Port             uint16        // The port number on which the database server is listening.
+	Charset          string        // The character set to be used for the database connection.
+	UserName         string        // The username for authenticating with the database.
+	Password         string        // The password for authenticating with the database.
+	Schema           string        // The database schema to be used.
+	DBType           string        // The type of the database (e.g., MySQL, PostgreSQL).
+	SQLType          string        // The type of the SQL operation (e.g., SELECT, INSERT).
+	SQL              string        // The SQL query or command to be executed.
+	OrderID          string        // An identifier for the order related to the SQL task.
+	TaskID           string        // An identifier for the specific task to be executed.
+	ExportFileFormat string        // The format for exporting the data (e.g., CSV, JSON).
+	CSVDelimiter     string        // The field delimiter for CSV exports, empty means a comma.
+	CSVEncoding      string        // The character encoding for CSV exports (UTF8 or GBK).
+	CSVWithBOM       bool          // Whether to write a UTF-8 BOM at the start of CSV exports.
+	BatchExecute     bool          // Whether to split a single-table UPDATE/DELETE into primary key range chunks.
+	MaxAffectedRows  int           // The MAX_AFFECTED_ROWS inspect parameter, which bounds the rows kept for rollback.
+	Replicas         []string      // Registered replicas (host:port) whose replication lag is checked before and during execution.
+	OSCEngine        string        // The online schema change engine for ALTER TABLE, empty means the global default.
+	MaskingRules     []MaskingRule // The data masking rules applied to EXPORT results.
+	MaskingSecret    string        // The HMAC key used by the HASH masking method.
+}
+
+// MaskingRule is a data masking rule for EXPORT results; either Column or Pattern is set.
+// Column matches schema.table.column exactly (an empty Schema matches any schema);
+// Pattern is a regular expression matched case-insensitively against column names.
+type MaskingRule struct {
+	Schema  string
+	Table   string
+	Column  string
+	Pattern string
+	Method  string
+}
+
+func (r MaskingRule) String() string {
+	if r.Column != "" {
+		if r.Schema != "" {
+			return fmt.Sprintf("%s.%s.%s", r.Schema, r.Table, r.Column)
+		}
+		return fmt.Sprintf("%s.%s", r.Table, r.Column)
+	}
+	return fmt.Sprintf("/%s/", r.Pattern)
 }
 
 // ExportFile contains details about an exported file.
 type ExportFile struct {
-	FileName      string `json:"file_name"`      // The name of the exported file.
-	FileSize      int64  `json:"file_size"`      // The size of the exported file in bytes.
-	FilePath      string `json:"file_path"`      // The path where the exported file is stored.
-	ContentType   string `json:"content_type"`   // The MIME type of the exported file.
-	EncryptionKey string `json:"encryption_key"` // The key used to encrypt the file, if any.
-	ExportRows    int64  `json:"export_rows"`    // The number of rows exported in the file.
-	DownloadUrl   string `json:"download_url"`   // The URL to download the exported file.
+	FileName      string   `json:"file_name"`      // The name of the exported file.
+	FileSize      int64    `json:"file_size"`      // The size of the exported file in bytes.
+	FilePath      string   `json:"file_path"`      // The path where the exported file is stored.
+	ContentType   string   `json:"content_type"`   // The MIME type of the exported file.
+	EncryptionKey string   `json:"encryption_key"` // The key used to encrypt the file, if any.
+	ExportRows    int64    `json:"export_rows"`    // The number of rows exported in the file.
+	DownloadUrl   string   `json:"download_url"`   // The URL to download the exported file.
+	ExpireAt      string   `json:"expire_at"`      // The time after which the file is removed, empty means never.
+	MaskedColumns []string `json:"masked_columns"` // The masking rules applied to the exported columns.
 }
 
 // ReturnData contains the results and metadata of a SQL execution task.
 type ReturnData struct {
-	RollbackSQL     string `json:"rollback_sql"`      // The SQL command to rollback the operation.
-	AffectedRows    int64  `json:"affected_rows"`     // The number of rows affected by the SQL execution.
-	ExecuteCostTime string `json:"execute_cost_time"` // The time taken to execute the SQL command.
-	BackupCostTime  string `json:"backup_cost_time"`  // The time taken to backup data, if applicable.
-	ExecuteLog      string `json:"execute_log"`       // The log output of the SQL execution.
-	ExportFile             // Embedded struct containing export file details.
-	Error           string `json:"error"` // Error message, if any occurred during execution.
+	RollbackSQL     string      `json:"rollback_sql"`      // The SQL command to rollback the operation.
+	RollbackSQLFile string      `json:"rollback_sql_file"` // The file storing the rollback SQL, used when it is too large to inline.
+	AffectedRows    int64       `json:"affected_rows"`     // The number of rows affected by the SQL execution.
+	ExecuteCostTime string      `json:"execute_cost_time"` // The time taken to execute the SQL command.
+	BackupCostTime  string      `json:"backup_cost_time"`  // The time taken to backup data, if applicable.
+	ExecuteLog      string      `json:"execute_log"`       // The log output of the SQL execution.
+	ExportFile                  // Embedded struct containing export file details.
+	Error           string      `json:"error"`              // Error message, if any occurred during execution.
+	Progress        interface{} `json:"progress,omitempty"` // The last progress reported by an external tool such as gh-ost.
+}
+
+// DryRunResult is the result of one statement executed in a dry run.
+type DryRunResult struct {
+	SQL          string `json:"sql"`           // The statement as submitted in the order.
+	AffectedRows int64  `json:"affected_rows"` // The number of rows affected before the transaction was rolled back.
+	Error        string `json:"error"`         // Error message, if the statement failed.
+}
+
+// TransactionTask is a DML task executed as part of an order-level transaction.
+type TransactionTask struct {
+	TaskID string // An identifier for the task.
+	SQL    string // The DML statement of the task.
 }
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
 )
 
 func read(ctx context.Context, wg *sync.WaitGroup, std io.ReadCloser, ch chan<- string) {
@@ -28,7 +29,21 @@ func read(ctx context.Context, wg *sync.WaitGroup, std io.ReadCloser, ch chan<-
 }
 
 func Command(ctx context.Context, ch chan<- string, cmd string) error {
-	c := exec.CommandContext(ctx, "bash", "-c", cmd)
+	return run(ctx, ch, exec.CommandContext(ctx, "bash", "-c", cmd))
+}
+
+// CommandArgs 不经过shell直接执行命令，参数原样传递给进程，避免参数被shell解析
+// 取消时先发送SIGTERM，留给进程清理的时间，超时后强制终止
+func CommandArgs(ctx context.Context, ch chan<- string, name string, args ...string) error {
+	c := exec.CommandContext(ctx, name, args...)
+	c.Cancel = func() error {
+		return c.Process.Signal(syscall.SIGTERM)
+	}
+	c.WaitDelay = 30 * time.Second
+	return run(ctx, ch, c)
+}
+
+func run(ctx context.Context, ch chan<- string, c *exec.Cmd) error {
 	// standard output
 	stdout, err := c.StdoutPipe()
 	if err != nil {
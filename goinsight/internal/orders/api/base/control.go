@@ -0,0 +1,98 @@
+package base
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RunningTask 记录正在执行中的任务，用于终止正在执行的语句
+type RunningTask struct {
+	OrderID string
+	TaskID  string
+	Cancel  func() error // 终止语句的方法，例如KILL QUERY或向gh-ost发送panic指令
+	// Control 向执行中的工具发送交互命令，例如gh-ost的throttle/chunk-size，为空表示不支持交互
+	Control  func(command string) (string, error)
+	Progress interface{} // 最近一次解析的执行进度
+}
+
+var (
+	runningTasks = make(map[string]*RunningTask)
+	runningMutex sync.Mutex
+)
+
+// RegisterRunningTask 注册正在执行的任务
+func RegisterRunningTask(orderID, taskID string, cancel func() error) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	runningTasks[taskID] = &RunningTask{OrderID: orderID, TaskID: taskID, Cancel: cancel}
+}
+
+// UnregisterRunningTask 任务执行结束后移除注册信息
+func UnregisterRunningTask(taskID string) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	delete(runningTasks, taskID)
+}
+
+// CancelRunningTask 终止正在执行的任务
+func CancelRunningTask(taskID string) error {
+	runningMutex.Lock()
+	var cancel func() error
+	task, ok := runningTasks[taskID]
+	if ok {
+		cancel = task.Cancel
+	}
+	runningMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("任务`%s`没有正在执行的语句", taskID)
+	}
+	return cancel()
+}
+
+// SetRunningTaskControl 设置任务的交互命令方法
+func SetRunningTaskControl(taskID string, control func(command string) (string, error)) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	if task, ok := runningTasks[taskID]; ok {
+		task.Control = control
+	}
+}
+
+// ControlRunningTask 向执行中的任务发送交互命令
+func ControlRunningTask(taskID, command string) (string, error) {
+	// 在锁内复制交互命令方法，SetRunningTaskControl会在锁内修改
+	runningMutex.Lock()
+	var control func(command string) (string, error)
+	task, ok := runningTasks[taskID]
+	if ok {
+		control = task.Control
+	}
+	runningMutex.Unlock()
+	if !ok {
+		return "", fmt.Errorf("任务`%s`没有正在执行的语句", taskID)
+	}
+	if control == nil {
+		return "", fmt.Errorf("任务`%s`不支持交互命令", taskID)
+	}
+	return control(command)
+}
+
+// SetRunningTaskProgress 更新任务的执行进度
+func SetRunningTaskProgress(taskID string, progress interface{}) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	if task, ok := runningTasks[taskID]; ok {
+		task.Progress = progress
+	}
+}
+
+// GetRunningTaskProgress 获取任务的执行进度，任务未在执行时返回false
+func GetRunningTaskProgress(taskID string) (interface{}, bool) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+	task, ok := runningTasks[taskID]
+	if !ok {
+		return nil, false
+	}
+	return task.Progress, true
+}
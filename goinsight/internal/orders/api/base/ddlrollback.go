@@ -0,0 +1,287 @@
+/*
+@Desc    :   根据执行前的表结构生成DDL的回滚SQL
+*/
+
+package base
+
+import (
+	"database/sql"
+	"fmt"
+	"goInsight/global"
+	"goInsight/pkg/parser"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+	"github.com/pingcap/tidb/pkg/parser/model"
+)
+
+// DDLRollback 生成DDL的反向语句，执行前需要通过SetTableStructure设置涉及的表结构
+type DDLRollback struct {
+	stmt          ast.StmtNode
+	defaultSchema string
+	tables        map[string]*ast.CreateTableStmt // 执行前的表结构
+}
+
+func NewDDLRollback(sqltext, defaultSchema string) (*DDLRollback, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return &DDLRollback{stmt: stmt, defaultSchema: defaultSchema, tables: make(map[string]*ast.CreateTableStmt)}, nil
+}
+
+func (r *DDLRollback) schemaOf(t *ast.TableName) string {
+	if t.Schema.O != "" {
+		return t.Schema.O
+	}
+	return r.defaultSchema
+}
+
+func (r *DDLRollback) tableName(t *ast.TableName) string {
+	return fmt.Sprintf("`%s`.`%s`", r.schemaOf(t), t.Name.O)
+}
+
+// Tables 返回执行前需要获取表结构的表，每个元素为[库名, 表名]
+func (r *DDLRollback) Tables() [][2]string {
+	var tables []*ast.TableName
+	switch s := r.stmt.(type) {
+	case *ast.CreateTableStmt:
+		tables = append(tables, s.Table)
+	case *ast.AlterTableStmt:
+		tables = append(tables, s.Table)
+	case *ast.DropTableStmt:
+		tables = append(tables, s.Tables...)
+	case *ast.DropIndexStmt:
+		tables = append(tables, s.Table)
+	}
+	var data [][2]string
+	for _, t := range tables {
+		data = append(data, [2]string{r.schemaOf(t), t.Name.O})
+	}
+	return data
+}
+
+// Capture 执行前获取涉及的表结构，表不存在时跳过
+func (r *DDLRollback) Capture(db *sql.DB) {
+	for _, t := range r.Tables() {
+		var name, createTable string
+		if err := db.QueryRow(fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`", t[0], t[1])).Scan(&name, &createTable); err != nil {
+			continue
+		}
+		if err := r.SetTableStructure(t[0], t[1], createTable); err != nil {
+			global.App.Log.Error(err)
+		}
+	}
+}
+
+// SetTableStructure 设置执行前SHOW CREATE TABLE的结果
+func (r *DDLRollback) SetTableStructure(schema, table, createTable string) error {
+	stmt, err := parser.NewParseOneStmt(createTable, "", "")
+	if err != nil {
+		return err
+	}
+	create, ok := stmt.(*ast.CreateTableStmt)
+	if !ok {
+		return fmt.Errorf("表`%s`.`%s`的表结构不是CREATE TABLE语句", schema, table)
+	}
+	r.tables[fmt.Sprintf("`%s`.`%s`", schema, table)] = create
+	return nil
+}
+
+func restore(node ast.Node) (string, error) {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags|format.RestoreStringWithoutCharset, &sb)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// 执行前的列定义
+func (r *DDLRollback) oldColumn(table, column string) (string, bool) {
+	create, ok := r.tables[table]
+	if !ok {
+		return "", false
+	}
+	for _, col := range create.Cols {
+		if strings.EqualFold(col.Name.Name.O, column) {
+			def, err := restore(col)
+			return def, err == nil
+		}
+	}
+	return "", false
+}
+
+// 执行前的索引定义，name为空表示主键
+func (r *DDLRollback) oldIndex(table, name string) (string, bool) {
+	create, ok := r.tables[table]
+	if !ok {
+		return "", false
+	}
+	for _, c := range create.Constraints {
+		isPrimary := c.Tp == ast.ConstraintPrimaryKey
+		if (name == "" && isPrimary) || (name != "" && !isPrimary && strings.EqualFold(c.Name, name)) {
+			def, err := restore(c)
+			return def, err == nil
+		}
+	}
+	return "", false
+}
+
+// 未指定索引名时，MySQL使用第一列的列名作为索引名
+func indexName(c *ast.Constraint) string {
+	if c.Name != "" || len(c.Keys) == 0 || c.Keys[0].Column == nil {
+		return c.Name
+	}
+	return c.Keys[0].Column.Name.O
+}
+
+// Generate 生成回滚SQL，返回按执行顺序排列的回滚语句和不可逆操作的警告
+func (r *DDLRollback) Generate() (sqls []string, warnings []string) {
+	switch s := r.stmt.(type) {
+	case *ast.CreateTableStmt:
+		table := r.tableName(s.Table)
+		if _, existed := r.tables[table]; existed {
+			warnings = append(warnings, fmt.Sprintf("表%s执行前已存在，不生成回滚SQL", table))
+			return
+		}
+		sqls = append(sqls, fmt.Sprintf("DROP TABLE %s", table))
+	case *ast.CreateViewStmt:
+		if s.OrReplace {
+			warnings = append(warnings, "CREATE OR REPLACE VIEW语句无法恢复原视图定义")
+			return
+		}
+		sqls = append(sqls, fmt.Sprintf("DROP VIEW %s", r.tableName(s.ViewName)))
+	case *ast.CreateIndexStmt:
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s DROP INDEX `%s`", r.tableName(s.Table), s.IndexName))
+	case *ast.DropIndexStmt:
+		table := r.tableName(s.Table)
+		if def, ok := r.oldIndex(table, s.IndexName); ok {
+			sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD %s", table, def))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("未获取到索引%s的定义，无法生成回滚SQL", s.IndexName))
+		}
+	case *ast.DropTableStmt:
+		for _, t := range s.Tables {
+			table := r.tableName(t)
+			create, ok := r.tables[table]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("未获取到表%s的表结构，无法生成回滚SQL", table))
+				continue
+			}
+			// 恢复到原来的库，不依赖执行回滚SQL时的默认库
+			create.Table.Schema = model.NewCIStr(r.schemaOf(t))
+			if def, err := restore(create); err == nil {
+				sqls = append(sqls, def)
+			}
+			warnings = append(warnings, fmt.Sprintf("DROP TABLE %s不可逆，回滚SQL仅能恢复表结构，数据无法恢复", table))
+		}
+	case *ast.TruncateTableStmt:
+		warnings = append(warnings, fmt.Sprintf("TRUNCATE TABLE %s不可逆，数据无法恢复", r.tableName(s.Table)))
+	case *ast.RenameTableStmt:
+		// 按照相反的顺序反向重命名
+		for i := len(s.TableToTables) - 1; i >= 0; i-- {
+			t := s.TableToTables[i]
+			sqls = append(sqls, fmt.Sprintf("RENAME TABLE %s TO %s", r.tableName(t.NewTable), r.tableName(t.OldTable)))
+		}
+	case *ast.AlterTableStmt:
+		return r.alterTable(s)
+	default:
+		warnings = append(warnings, "当前DDL语句不支持生成回滚SQL")
+	}
+	return
+}
+
+// RollbackSQL 生成回滚SQL并输出不可逆操作的警告
+func (r *DDLRollback) RollbackSQL(logAndPublish func(string)) string {
+	sqls, warnings := r.Generate()
+	for _, w := range warnings {
+		logAndPublish("【警告】" + w)
+	}
+	if len(sqls) > 0 {
+		logAndPublish("生成回滚SQL成功")
+	}
+	return strings.Join(sqls, ";\r\n")
+}
+
+// ALTER TABLE的每个子句生成一条反向语句，按照相反的顺序执行
+func (r *DDLRollback) alterTable(s *ast.AlterTableStmt) (sqls []string, warnings []string) {
+	table := r.tableName(s.Table)
+	// 包含重命名表时，回滚语句需要使用新表名
+	current := table
+	for _, spec := range s.Specs {
+		if spec.Tp == ast.AlterTableRenameTable {
+			current = r.tableName(spec.NewTable)
+		}
+	}
+	var specs []string
+	for i := len(s.Specs) - 1; i >= 0; i-- {
+		spec := s.Specs[i]
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			for _, col := range spec.NewColumns {
+				specs = append(specs, fmt.Sprintf("DROP COLUMN `%s`", col.Name.Name.O))
+			}
+		case ast.AlterTableAddConstraint:
+			switch spec.Constraint.Tp {
+			case ast.ConstraintPrimaryKey:
+				specs = append(specs, "DROP PRIMARY KEY")
+			case ast.ConstraintForeignKey:
+				specs = append(specs, fmt.Sprintf("DROP FOREIGN KEY `%s`", spec.Constraint.Name))
+			case ast.ConstraintCheck:
+				specs = append(specs, fmt.Sprintf("DROP CHECK `%s`", spec.Constraint.Name))
+			default:
+				specs = append(specs, fmt.Sprintf("DROP INDEX `%s`", indexName(spec.Constraint)))
+			}
+		case ast.AlterTableDropColumn:
+			name := spec.OldColumnName.Name.O
+			if def, ok := r.oldColumn(table, name); ok {
+				specs = append(specs, "ADD COLUMN "+def)
+				warnings = append(warnings, fmt.Sprintf("DROP COLUMN `%s`不可逆，回滚SQL仅能恢复列定义，数据无法恢复", name))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("未获取到列`%s`的定义，无法生成回滚SQL", name))
+			}
+		case ast.AlterTableDropIndex:
+			if def, ok := r.oldIndex(table, spec.Name); ok {
+				specs = append(specs, "ADD "+def)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("未获取到索引`%s`的定义，无法生成回滚SQL", spec.Name))
+			}
+		case ast.AlterTableDropPrimaryKey:
+			if def, ok := r.oldIndex(table, ""); ok {
+				specs = append(specs, "ADD "+def)
+			} else {
+				warnings = append(warnings, "未获取到主键的定义，无法生成回滚SQL")
+			}
+		case ast.AlterTableModifyColumn, ast.AlterTableAlterColumn:
+			name := spec.NewColumns[0].Name.Name.O
+			if def, ok := r.oldColumn(table, name); ok {
+				specs = append(specs, "MODIFY COLUMN "+def)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("未获取到列`%s`的定义，无法生成回滚SQL", name))
+			}
+		case ast.AlterTableChangeColumn:
+			oldName, newName := spec.OldColumnName.Name.O, spec.NewColumns[0].Name.Name.O
+			if def, ok := r.oldColumn(table, oldName); ok {
+				specs = append(specs, fmt.Sprintf("CHANGE COLUMN `%s` %s", newName, def))
+			} else {
+				warnings = append(warnings, fmt.Sprintf("未获取到列`%s`的定义，无法生成回滚SQL", oldName))
+			}
+		case ast.AlterTableRenameColumn:
+			specs = append(specs, fmt.Sprintf("RENAME COLUMN `%s` TO `%s`", spec.NewColumnName.Name.O, spec.OldColumnName.Name.O))
+		case ast.AlterTableRenameIndex:
+			specs = append(specs, fmt.Sprintf("RENAME INDEX `%s` TO `%s`", spec.ToKey.O, spec.FromKey.O))
+		case ast.AlterTableRenameTable:
+			specs = append(specs, fmt.Sprintf("RENAME TO %s", table))
+		case ast.AlterTableAlgorithm, ast.AlterTableLock:
+			// 执行选项，不需要回滚
+		default:
+			text, _ := restore(spec)
+			warnings = append(warnings, fmt.Sprintf("子句`%s`不支持生成回滚SQL", text))
+		}
+	}
+	if len(specs) > 0 {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s %s", current, strings.Join(specs, ", ")))
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDDLRollback(t *testing.T) {
+	createTable := "CREATE TABLE `t1` (`id` bigint NOT NULL AUTO_INCREMENT, `c1` varchar(32) NOT NULL DEFAULT '', `c2` int DEFAULT NULL, PRIMARY KEY (`id`), KEY `idx_c1` (`c1`))"
+	tests := []struct {
+		sql          string
+		wantSQL      []string
+		wantWarnings int
+	}{
+		{
+			sql:     "alter table t1 add column c3 int, add index idx_c3(c3), add unique(c2)",
+			wantSQL: []string{"ALTER TABLE `d1`.`t1` DROP INDEX `c2`, DROP INDEX `idx_c3`, DROP COLUMN `c3`"},
+		},
+		{
+			sql:     "alter table t1 modify c1 varchar(64) not null default '', change c2 c4 bigint",
+			wantSQL: []string{"ALTER TABLE `d1`.`t1` CHANGE COLUMN `c4` `c2` INT DEFAULT NULL, MODIFY COLUMN `c1` VARCHAR(32) NOT NULL DEFAULT ''"},
+		},
+		{
+			sql:          "alter table d1.t1 drop column c2, drop index idx_c1",
+			wantSQL:      []string{"ALTER TABLE `d1`.`t1` ADD INDEX `idx_c1`(`c1`), ADD COLUMN `c2` INT DEFAULT NULL"},
+			wantWarnings: 1,
+		},
+		{
+			sql:     "alter table t1 rename to t2",
+			wantSQL: []string{"ALTER TABLE `d1`.`t2` RENAME TO `d1`.`t1`"},
+		},
+		{
+			sql:     "rename table t1 to t2, t3 to t4",
+			wantSQL: []string{"RENAME TABLE `d1`.`t4` TO `d1`.`t3`", "RENAME TABLE `d1`.`t2` TO `d1`.`t1`"},
+		},
+		{
+			sql:     "create table t5(id int primary key)",
+			wantSQL: []string{"DROP TABLE `d1`.`t5`"},
+		},
+		{
+			sql:          "truncate table t1",
+			wantWarnings: 1,
+		},
+		{
+			sql:          "alter table t1 engine=innodb",
+			wantWarnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			r, err := NewDDLRollback(tt.sql, "d1")
+			assert.NoError(t, err)
+			assert.NoError(t, r.SetTableStructure("d1", "t1", createTable))
+			sqls, warnings := r.Generate()
+			assert.Equal(t, tt.wantSQL, sqls)
+			assert.Equal(t, tt.wantWarnings, len(warnings))
+		})
+	}
+
+	// 删除其他库的表时，恢复的表结构指定原来的库
+	r, err := NewDDLRollback("drop table d2.t1", "d1")
+	assert.NoError(t, err)
+	assert.NoError(t, r.SetTableStructure("d2", "t1", createTable))
+	sqls, warnings := r.Generate()
+	assert.Equal(t, []string{"CREATE TABLE `d2`.`t1` (`id` BIGINT NOT NULL AUTO_INCREMENT,`c1` VARCHAR(32) NOT NULL DEFAULT '',`c2` INT DEFAULT NULL,PRIMARY KEY(`id`),INDEX `idx_c1`(`c1`))"}, sqls)
+	assert.Equal(t, 1, len(warnings))
+}
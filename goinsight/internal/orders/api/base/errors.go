@@ -9,6 +9,10 @@ func (e SQLExecuteError) Error() string {
 	return e.Err.Error()
 }
 
+func (e SQLExecuteError) Unwrap() error {
+	return e.Err
+}
+
 // 生成回滚SQL失败
 type RollbackSQLError struct {
 	Err error
@@ -17,3 +21,7 @@ type RollbackSQLError struct {
 func (e RollbackSQLError) Error() string {
 	return e.Err.Error()
 }
+
+func (e RollbackSQLError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,25 @@
+package base
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// SendSocketCommand 向unix socket发送交互命令并返回响应，例如gh-ost的serve-socket-file
+func SendSocketCommand(socketFile, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", socketFile, 3*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
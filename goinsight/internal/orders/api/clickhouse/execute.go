@@ -0,0 +1,241 @@
+/*
+@Desc    :   执行ClickHouse DDL/DML，跟踪mutation的执行进度
+*/
+
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goInsight/internal/das/dao"
+	"goInsight/internal/orders/api/base"
+	"goInsight/pkg/parser"
+	"goInsight/pkg/utils"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 轮询mutation进度的间隔
+var mutationPollInterval = 2 * time.Second
+
+// MutationProgress mutation的执行进度
+type MutationProgress struct {
+	Mutations []string `json:"mutations"`   // 未完成的mutation
+	PartsToDo int64    `json:"parts_to_do"` // 剩余需要处理的part数量
+}
+
+// ClickHouse DDL/DML
+type ExecuteClickHouseSQL struct {
+	*base.DBConfig
+}
+
+func newClickhouseDB(config *base.DBConfig, ctx context.Context) *dao.ClickhouseDB {
+	return &dao.ClickhouseDB{
+		User:     config.UserName,
+		Password: config.Password,
+		Host:     config.Hostname,
+		Port:     int(config.Port),
+		Database: config.Schema,
+		Ctx:      ctx,
+	}
+}
+
+// 转换为ClickHouse的字符串字面量
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func (e *ExecuteClickHouseSQL) Run() (data base.ReturnData, err error) {
+	var executeLog []string
+
+	logAndPublish := func(msg string) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		formattedMsg := fmt.Sprintf("[%s] %s", timestamp, msg)
+		executeLog = append(executeLog, formattedMsg)
+		base.PublishMessageToChannel(e.OrderID, formattedMsg, "")
+	}
+
+	logErrorAndReturn := func(err error, errMsg string) (base.ReturnData, error) {
+		logAndPublish(errMsg + err.Error())
+		data.ExecuteLog = strings.Join(executeLog, "\n")
+		return data, err
+	}
+
+	stmt, err := parser.ParseClickHouseStmt(e.SQL)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "SQL解析失败，错误：")
+	}
+	if stmt.Type != e.SQLType {
+		return logErrorAndReturn(base.SQLExecuteError{Err: fmt.Errorf("语句类型为%s，和工单类型%s不一致", stmt.Type, e.SQLType)}, "执行失败，错误：")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := newClickhouseDB(e.DBConfig, ctx)
+
+	// 注册任务，终止时取消正在执行的语句，未完成的mutation通过KILL MUTATION终止
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancelled.Store(true)
+		cancel()
+		return nil
+	})
+	defer base.UnregisterRunningTask(e.TaskID)
+
+	logAndPublish(fmt.Sprintf("访问数据库(%s:%d)", e.DBConfig.Hostname, e.DBConfig.Port))
+	// 执行前记录表上已有的mutation，只跟踪当前语句产生的mutation
+	var existing map[string]bool
+	if stmt.Mutation {
+		if existing, err = e.mutationIDs(db, stmt); err != nil {
+			return logErrorAndReturn(base.SQLExecuteError{Err: err}, "获取表上已有的mutation失败，错误：")
+		}
+	}
+	startTime := time.Now()
+	if stmt.Cluster != "" {
+		// ON CLUSTER语句返回每个节点的执行结果
+		var rows *[]map[string]interface{}
+		_, rows, err = db.Query(e.SQL)
+		if err == nil {
+			for _, row := range *rows {
+				msg := fmt.Sprintf("节点%v:%v执行状态：%v", row["host"], row["port"], row["status"])
+				if row["error"] != nil && row["error"] != "" {
+					msg += fmt.Sprintf("，错误：%v", row["error"])
+				}
+				logAndPublish(msg)
+			}
+		}
+	} else {
+		err = db.Exec(e.SQL)
+	}
+	if err != nil {
+		if cancelled.Load() {
+			err = errors.New("任务已被终止")
+		}
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "执行失败，错误：")
+	}
+	logAndPublish("执行SQL语句成功")
+
+	if stmt.Mutation {
+		if err := e.waitMutations(ctx, db, stmt, existing, startTime, &cancelled, logAndPublish); err != nil {
+			return logErrorAndReturn(base.SQLExecuteError{Err: err}, "mutation执行失败，错误：")
+		}
+	}
+
+	executeCostTime := utils.HumanfriendlyTimeUnit(time.Since(startTime))
+	logAndPublish("ClickHouse不支持生成回滚SQL")
+	logAndPublish(fmt.Sprintf("执行成功，执行耗时：%s", executeCostTime))
+	data.ExecuteCostTime = executeCostTime
+	data.ExecuteLog = strings.Join(executeLog, "\n")
+	return data, nil
+}
+
+// 语句所在的库
+func (e *ExecuteClickHouseSQL) schema(stmt parser.ClickHouseStmt) string {
+	if stmt.Schema != "" {
+		return stmt.Schema
+	}
+	return e.Schema
+}
+
+// mutation的来源，ON CLUSTER语句查询所有副本
+func mutationSource(stmt parser.ClickHouseStmt) string {
+	if stmt.Cluster != "" {
+		return fmt.Sprintf("clusterAllReplicas(%s, system.mutations)", quoteString(stmt.Cluster))
+	}
+	return "system.mutations"
+}
+
+// 获取表上已有的mutation
+func (e *ExecuteClickHouseSQL) mutationIDs(db *dao.ClickhouseDB, stmt parser.ClickHouseStmt) (map[string]bool, error) {
+	query := fmt.Sprintf("SELECT DISTINCT mutation_id FROM %s WHERE database=? AND table=?", mutationSource(stmt))
+	_, rows, err := db.Query(query, e.schema(stmt), stmt.Table)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(*rows))
+	for _, row := range *rows {
+		ids[fmt.Sprintf("%v", row["mutation_id"])] = true
+	}
+	return ids, nil
+}
+
+// 等待语句产生的mutation执行完成，mutation在后台异步执行，通过system.mutations获取进度；
+// 执行前已有的mutation属于其他会话，不跟踪也不终止
+func (e *ExecuteClickHouseSQL) waitMutations(ctx context.Context, db *dao.ClickhouseDB, stmt parser.ClickHouseStmt, existing map[string]bool, startTime time.Time, cancelled *atomic.Bool, logAndPublish func(string)) error {
+	schema := e.schema(stmt)
+	// 使用服务端的当前时间过滤，避免客户端和服务端时钟不一致
+	query := fmt.Sprintf("SELECT mutation_id, parts_to_do, is_done, latest_fail_reason FROM %s WHERE database=? AND table=? AND create_time>=now()-toIntervalSecond(?)", mutationSource(stmt))
+
+	var (
+		lastMsg string
+		pending []string
+	)
+	for {
+		seconds := int64(time.Since(startTime).Seconds()) + 1
+		_, rows, err := db.Query(query, schema, stmt.Table, seconds)
+		if err != nil {
+			if cancelled.Load() {
+				return e.killMutations(stmt, schema, pending, logAndPublish)
+			}
+			return err
+		}
+		var partsToDo int64
+		pending = pending[:0]
+		for _, row := range *rows {
+			id := fmt.Sprintf("%v", row["mutation_id"])
+			if existing[id] {
+				continue
+			}
+			if reason := fmt.Sprintf("%v", row["latest_fail_reason"]); reason != "" {
+				return fmt.Errorf("mutation %s执行失败，ClickHouse会在后台持续重试，请检查后手动执行KILL MUTATION：%s", id, reason)
+			}
+			if fmt.Sprintf("%v", row["is_done"]) == "1" {
+				continue
+			}
+			if !utils.IsContain(pending, id) {
+				pending = append(pending, id)
+			}
+			var parts int64
+			fmt.Sscanf(fmt.Sprintf("%v", row["parts_to_do"]), "%d", &parts)
+			partsToDo += parts
+		}
+		if len(pending) == 0 {
+			logAndPublish("mutation执行完成")
+			return nil
+		}
+		base.SetRunningTaskProgress(e.TaskID, MutationProgress{Mutations: append([]string(nil), pending...), PartsToDo: partsToDo})
+		if msg := fmt.Sprintf("mutation执行中：%s，剩余%d个part", strings.Join(pending, ","), partsToDo); msg != lastMsg {
+			logAndPublish(msg)
+			lastMsg = msg
+		}
+		select {
+		case <-ctx.Done():
+			return e.killMutations(stmt, schema, pending, logAndPublish)
+		case <-time.After(mutationPollInterval):
+		}
+	}
+}
+
+// 终止未完成的mutation，已经处理的part不会回滚
+func (e *ExecuteClickHouseSQL) killMutations(stmt parser.ClickHouseStmt, schema string, mutations []string, logAndPublish func(string)) error {
+	if len(mutations) == 0 {
+		return errors.New("任务已被终止")
+	}
+	ids := make([]string, 0, len(mutations))
+	for _, id := range mutations {
+		ids = append(ids, quoteString(id))
+	}
+	onCluster := ""
+	if stmt.Cluster != "" {
+		onCluster = " ON CLUSTER " + quoteString(stmt.Cluster)
+	}
+	query := fmt.Sprintf("KILL MUTATION%s WHERE database=? AND table=? AND mutation_id IN (%s)", onCluster, strings.Join(ids, ","))
+	db := newClickhouseDB(e.DBConfig, context.Background())
+	if _, _, err := db.Query(query, schema, stmt.Table); err != nil {
+		return fmt.Errorf("任务已被终止，终止mutation失败：%s", err.Error())
+	}
+	logAndPublish(fmt.Sprintf("已终止mutation：%s，已经处理的part不会回滚", strings.Join(mutations, ",")))
+	return errors.New("任务已被终止")
+}
@@ -0,0 +1,125 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/das/dao"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/file"
+	"goInsight/pkg/utils"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type ExecuteClickHouseExportToFile struct {
+	*base.DBConfig
+}
+
+var filePath string = "./media"
+
+// 流式读取查询结果并写入文件
+func (e *ExecuteClickHouseExportToFile) processRowsAndExport(db *dao.ClickhouseDB) (int64, []string, error) {
+	g := new(errgroup.Group)
+	ch := make(chan []interface{}, 10)
+
+	var (
+		files    []string
+		rowCount int64
+		started  bool
+	)
+	err := db.QueryStream(e.SQL, func(columns []string) {
+		started = true
+		g.Go(func() (err error) {
+			files, err = file.Export(columns, ch, file.NewOptions(e.DBConfig, filePath))
+			return err
+		})
+	}, func(row []interface{}) error {
+		ch <- row
+		rowCount++
+		return nil
+	})
+	close(ch)
+	if !started {
+		return 0, nil, err
+	}
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return rowCount, files, nil
+}
+
+func (e *ExecuteClickHouseExportToFile) Run() (data base.ReturnData, err error) {
+	var (
+		executeLog []string
+		startTime  = time.Now()
+	)
+
+	logAndPublish := func(msg string) {
+		formattedMsg := fmt.Sprintf("[%s] %s", time.Now().Format("2006-01-02 15:04:05"), msg)
+		executeLog = append(executeLog, formattedMsg)
+		base.PublishMessageToChannel(e.OrderID, formattedMsg, "")
+	}
+
+	logErrorAndReturn := func(err error, errMsg string) (base.ReturnData, error) {
+		logAndPublish(fmt.Sprintf("%s: %s", errMsg, err.Error()))
+		data.ExecuteLog = strings.Join(executeLog, "\n")
+		return data, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancel()
+		return nil
+	})
+	defer base.UnregisterRunningTask(e.TaskID)
+
+	// 执行查询，dao.ClickhouseDB会将结果转换为字符串
+	db := newClickhouseDB(e.DBConfig, ctx)
+	logAndPublish(fmt.Sprintf("连接到数据库(%s:%d)，执行SQL语句", e.DBConfig.Hostname, e.DBConfig.Port))
+	rowCount, files, err := e.processRowsAndExport(db)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "Failed to process rows and export")
+	}
+	logAndPublish(fmt.Sprintf("处理行数据，生成文件%s", strings.Join(files, ",")))
+
+	// 加密和压缩文件，XLSX超过行数限制时会拆分为多个文件，压缩到同一个zip文件中
+	encryptFileName := files[0] + ".zip"
+	encryptFilePath := fmt.Sprintf("%s/%s", filePath, encryptFileName)
+	key := utils.GenerateRandomString(24)
+	_ = utils.EncryptAndZipFiles(files, encryptFileName, filePath, key)
+	logAndPublish(fmt.Sprintf("加密和压缩文件%s -> %s", strings.Join(files, ","), encryptFileName))
+
+	// 删除原文件
+	for _, fileName := range files {
+		fullFileName := fmt.Sprintf("%s/%s", filePath, fileName)
+		if err := os.Remove(fullFileName); err != nil {
+			return logErrorAndReturn(err, "Error deleting original file")
+		}
+		logAndPublish(fmt.Sprintf("源文件%s删除成功", fullFileName))
+	}
+
+	executeCostTime := utils.HumanfriendlyTimeUnit(time.Since(startTime))
+	logAndPublish(fmt.Sprintf("执行成功，影响行数%d，执行耗时：%s", rowCount, executeCostTime))
+
+	FileSize, _ := utils.GetFileSize(encryptFilePath)
+	data.ExportFile = base.ExportFile{
+		EncryptionKey: string(key),
+		FileName:      encryptFileName,
+		FilePath:      encryptFilePath,
+		ContentType:   file.Extension(e.ExportFileFormat),
+		FileSize:      FileSize,
+		ExportRows:    rowCount,
+		DownloadUrl:   fmt.Sprintf("%s/orders/download/exportfile/%s", global.App.Config.Notify.NoticeURL, encryptFileName),
+	}
+	data.ExecuteLog = strings.Join(executeLog, "\n")
+	data.ExecuteCostTime = executeCostTime
+	return
+}
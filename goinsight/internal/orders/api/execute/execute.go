@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/clickhouse"
 	"goInsight/internal/orders/api/mysql"
 	"goInsight/internal/orders/api/tidb"
 )
@@ -34,8 +35,7 @@ func NewExecuteSQLAPI(config *base.DBConfig) *ExecuteSQLAPI {
 	case "TiDB":
 		return &ExecuteSQLAPI{config, &TiDBExecutor{config}}
 	case "ClickHouse":
-		// todo
-		// return &ExecuteSQLAPI{config, &ExecuteClickHouseDML{config}}
+		return &ExecuteSQLAPI{config, &ClickHouseExecutor{config}}
 	}
 	return nil
 }
@@ -87,3 +87,37 @@ func (m *TiDBExecutor) Run() (data base.ReturnData, err error) {
 		return
 	}
 }
+
+// ClickHouseExecutor 执行ClickHouse操作，DDL和DML都可能产生mutation，使用相同的执行方式
+type ClickHouseExecutor struct {
+	*base.DBConfig
+}
+
+func (m *ClickHouseExecutor) Run() (data base.ReturnData, err error) {
+	switch m.SQLType {
+	case "DML", "DDL":
+		execute := clickhouse.ExecuteClickHouseSQL{DBConfig: m.DBConfig}
+		return execute.Run()
+	case "EXPORT":
+		execute := clickhouse.ExecuteClickHouseExportToFile{DBConfig: m.DBConfig}
+		return execute.Run()
+	default:
+		data.Error = fmt.Sprintf("不支持的SQL类型：%s", m.SQLType)
+		err = errors.New(data.Error)
+		return
+	}
+}
+
+// 事务模式执行工单的所有DML任务，返回每个任务的执行结果
+func ExecuteInTransaction(config *base.DBConfig, tasks []base.TransactionTask) ([]base.ReturnData, error) {
+	switch config.DBType {
+	case "MySQL":
+		execute := mysql.ExecuteMySQLDMLInTransaction{DBConfig: config, Tasks: tasks}
+		results, err := execute.Run()
+		if err != nil {
+			base.PublishMessageToChannel(config.OrderID, err.Error(), "")
+		}
+		return results, err
+	}
+	return nil, fmt.Errorf("事务模式不支持%s", config.DBType)
+}
@@ -1,14 +1,20 @@
 package file
 
 import (
+	"bufio"
 	"encoding/csv"
+	"fmt"
 	"goInsight/global"
+	"io"
 	"os"
+	"unicode/utf8"
 
 	"github.com/spf13/cast"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
-func ToCSV(columns []string, row <-chan []interface{}, fileName string) (err error) {
+func ToCSV(columns []string, row <-chan []interface{}, fileName string, opts Options) (err error) {
 	// 创建 CSV 文件
 	csvFile, err := os.Create(fileName)
 	if err != nil {
@@ -17,9 +23,32 @@ func ToCSV(columns []string, row <-chan []interface{}, fileName string) (err err
 	}
 	defer csvFile.Close()
 
+	// 使用缓冲写入，避免逐行刷盘
+	buf := bufio.NewWriter(csvFile)
+	var w io.Writer = buf
+	switch opts.Encoding {
+	case "", "UTF8":
+		if opts.BOM {
+			if _, err = buf.WriteString("\xEF\xBB\xBF"); err != nil {
+				return
+			}
+		}
+	case "GBK":
+		// 无法使用GBK表示的字符替换为问号
+		w = encoding.ReplaceUnsupported(simplifiedchinese.GBK.NewEncoder()).Writer(buf)
+	default:
+		return fmt.Errorf("不支持的CSV编码：%s", opts.Encoding)
+	}
+
 	// 创建 CSV Writer
-	csvWriter := csv.NewWriter(csvFile)
-	defer csvWriter.Flush()
+	csvWriter := csv.NewWriter(w)
+	if opts.Delimiter != "" {
+		delimiter, size := utf8.DecodeRuneInString(opts.Delimiter)
+		if size != len(opts.Delimiter) {
+			return fmt.Errorf("CSV分隔符只能为单个字符：%s", opts.Delimiter)
+		}
+		csvWriter.Comma = delimiter
+	}
 
 	// 写入表头
 	if err = csvWriter.Write(columns); err != nil {
@@ -27,9 +56,6 @@ func ToCSV(columns []string, row <-chan []interface{}, fileName string) (err err
 		return
 	}
 
-	// csv起始行号
-	var rowIndex int64 = 2
-
 	// 从通道中读取数据行并写入 CSV 文件
 	for v := range row {
 		// 按行写入到文件
@@ -38,16 +64,13 @@ func ToCSV(columns []string, row <-chan []interface{}, fileName string) (err err
 			global.App.Log.Error("write row error", err.Error())
 			return
 		}
+	}
 
-		// 将缓冲区中的数据写入文件
-		csvWriter.Flush()
-
-		// 检查错误，如果有错误则打印并退出
-		if err = csvWriter.Error(); err != nil {
-			global.App.Log.Error("csv writer error", err.Error())
-			return
-		}
-		rowIndex++
+	// 将缓冲区中的数据写入文件
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		global.App.Log.Error("csv writer error", err.Error())
+		return
 	}
-	return
+	return buf.Flush()
 }
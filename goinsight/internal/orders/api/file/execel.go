@@ -1,64 +1,125 @@
 package file
 
 import (
-	"database/sql"
+	"fmt"
 	"goInsight/global"
-	"strconv"
+	"path/filepath"
 
 	"github.com/xuri/excelize/v2"
 )
 
-func ToExcel(columns []string, row <-chan []interface{}, fileName string) (err error) {
-	// 创建Excel文件
-	sheet := "Sheet1"
-	f := excelize.NewFile()
+var (
+	// excel单个sheet最多的行数，包含表头
+	maxExcelRows = excelize.TotalRows
+	// 单个excel文件最多的sheet数量，超过后拆分为多个文件，避免单个文件过大
+	maxExcelSheets = 4
+)
+
+// excel文件写入器，超过行数限制时自动创建新的sheet和文件
+type excelWriter struct {
+	columns  []interface{}
+	opts     Options
+	files    []string
+	f        *excelize.File
+	sw       *excelize.StreamWriter
+	sheets   int
+	rowIndex int
+}
 
+// 保存当前文件
+func (w *excelWriter) save() error {
+	if w.f == nil {
+		return nil
+	}
+	defer func() {
+		w.f.Close()
+		w.f, w.sw = nil, nil
+	}()
+	// 调用Flush函数来结束流式写入过程
+	if err := w.sw.Flush(); err != nil {
+		global.App.Log.Error("Flush failed", err.Error())
+		return err
+	}
+	fileName := fmt.Sprintf("%s.xlsx", w.opts.Name)
+	if len(w.files) > 0 {
+		fileName = fmt.Sprintf("%s_%d.xlsx", w.opts.Name, len(w.files)+1)
+	}
+	// 保存Excel文件
+	if err := w.f.SaveAs(filepath.Join(w.opts.Dir, fileName)); err != nil {
+		global.App.Log.Error("Error saving excel file", err.Error())
+		return err
+	}
+	w.files = append(w.files, fileName)
+	return nil
+}
+
+// 创建新的sheet，当前文件的sheet数量达到上限时创建新的文件
+func (w *excelWriter) nextSheet() (err error) {
+	if w.f != nil && w.sheets >= maxExcelSheets {
+		if err = w.save(); err != nil {
+			return
+		}
+	} else if w.sw != nil {
+		if err = w.sw.Flush(); err != nil {
+			global.App.Log.Error("Flush failed", err.Error())
+			return
+		}
+	}
+	sheet := "Sheet1"
+	if w.f == nil {
+		w.f = excelize.NewFile()
+		w.sheets = 1
+	} else {
+		w.sheets++
+		sheet = fmt.Sprintf("Sheet%d", w.sheets)
+		if _, err = w.f.NewSheet(sheet); err != nil {
+			return
+		}
+	}
 	// 创建流式写入器
-	sw, err := f.NewStreamWriter(sheet)
-	if err != nil {
+	if w.sw, err = w.f.NewStreamWriter(sheet); err != nil {
 		global.App.Log.Error("new excel stream writer error", err.Error())
 		return
 	}
-
-	// 获取列名并写入表头
-	var columnsInterface []interface{}
-	for _, value := range columns {
-		columnsInterface = append(columnsInterface, value)
-	}
-	if err = sw.SetRow("A1", columnsInterface); err != nil {
+	// 写入表头
+	if err = w.sw.SetRow("A1", w.columns); err != nil {
 		global.App.Log.Error("set row error for columns", err.Error())
 		return
 	}
+	w.rowIndex = 2
+	return nil
+}
 
-	// excel起始行号
-	var rowIndex int64 = 2
-
-	// 读取数据
-	vals := make([]interface{}, len(columns))
-	for i := range columns {
-		vals[i] = new(sql.RawBytes)
+func ToExcel(columns []string, row <-chan []interface{}, opts Options) (files []string, err error) {
+	w := &excelWriter{opts: opts}
+	for _, value := range columns {
+		w.columns = append(w.columns, value)
+	}
+	defer func() {
+		if w.f != nil {
+			w.f.Close()
+		}
+	}()
+	if err = w.nextSheet(); err != nil {
+		return
 	}
 
-	// 从通道中读取数据行并写入 CSV 文件
+	// 从通道中读取数据行并写入 Excel 文件
 	for v := range row {
-		// 写入Excel
-		if err = sw.SetRow("A"+strconv.Itoa(int(rowIndex)), v); err != nil {
+		if w.rowIndex > maxExcelRows {
+			if err = w.nextSheet(); err != nil {
+				return
+			}
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, w.rowIndex)
+		if err = w.sw.SetRow(cell, v); err != nil {
 			global.App.Log.Error("Error writing row to excel", err.Error())
 			return
 		}
-		rowIndex++
-	}
-
-	// 调用Flush函数来结束流式写入过程
-	if err = sw.Flush(); err != nil {
-		global.App.Log.Error("Flush failed", err.Error())
-		return
+		w.rowIndex++
 	}
-
-	// 保存Excel文件
-	if err = f.SaveAs(fileName); err != nil {
-		global.App.Log.Error("Error saving excel file", err.Error())
+	if err = w.save(); err != nil {
 		return
 	}
-	return
+	return w.files, nil
 }
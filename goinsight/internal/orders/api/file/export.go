@@ -0,0 +1,80 @@
+package file
+
+import (
+	"fmt"
+	"goInsight/internal/orders/api/base"
+	"goInsight/pkg/parser"
+	"path/filepath"
+)
+
+// Options 导出文件的选项
+type Options struct {
+	Format    string // 文件格式：XLSX/CSV/JSONL/PARQUET/SQL
+	Dir       string // 文件存储目录
+	Name      string // 文件名，不包含扩展名
+	Delimiter string // CSV分隔符，为空时使用逗号
+	Encoding  string // CSV编码：UTF8/GBK，为空时使用UTF8
+	BOM       bool   // CSV是否写入UTF-8 BOM，方便Excel识别编码
+	Table     string // SQL格式中INSERT语句的表名
+}
+
+// 文件格式对应的扩展名
+var extensions = map[string]string{
+	"XLSX":    "xlsx",
+	"CSV":     "csv",
+	"JSONL":   "jsonl",
+	"PARQUET": "parquet",
+	"SQL":     "sql",
+}
+
+// Extension 返回文件格式对应的扩展名
+func Extension(format string) string {
+	return extensions[format]
+}
+
+// Export 将通道中的数据行写入文件，返回生成的文件名列表
+// 数据行逐行写入，内存占用和导出的行数无关；XLSX超过行数限制时会拆分为多个sheet和多个文件
+func Export(columns []string, row <-chan []interface{}, opts Options) (files []string, err error) {
+	// 写入失败时继续读取通道，避免生产者阻塞
+	defer func() {
+		for range row {
+		}
+	}()
+	ext := Extension(opts.Format)
+	if ext == "" {
+		return nil, fmt.Errorf("不支持的导出文件格式：%s", opts.Format)
+	}
+	fileName := fmt.Sprintf("%s.%s", opts.Name, ext)
+	fullFileName := filepath.Join(opts.Dir, fileName)
+	switch opts.Format {
+	case "XLSX":
+		return ToExcel(columns, row, opts)
+	case "CSV":
+		err = ToCSV(columns, row, fullFileName, opts)
+	case "JSONL":
+		err = ToJSONLines(columns, row, fullFileName)
+	case "PARQUET":
+		err = ToParquet(columns, row, fullFileName)
+	case "SQL":
+		err = ToSQL(columns, row, fullFileName, opts.Table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string{fileName}, nil
+}
+
+// NewOptions 根据任务配置生成导出选项，文件名使用任务ID
+func NewOptions(config *base.DBConfig, dir string) Options {
+	// SQL格式使用查询的表名作为INSERT语句的表名
+	table, _ := parser.GetTableNameFromSelectStatement(config.SQL)
+	return Options{
+		Format:    config.ExportFileFormat,
+		Dir:       dir,
+		Name:      config.TaskID,
+		Delimiter: config.CSVDelimiter,
+		Encoding:  config.CSVEncoding,
+		BOM:       config.CSVWithBOM,
+		Table:     table,
+	}
+}
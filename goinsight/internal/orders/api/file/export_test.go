@@ -0,0 +1,71 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func exportRows(t *testing.T, opts Options, rows ...[]interface{}) []string {
+	ch := make(chan []interface{}, len(rows))
+	for _, row := range rows {
+		ch <- row
+	}
+	close(ch)
+	files, err := Export([]string{"id", "name"}, ch, opts)
+	assert.NoError(t, err)
+	return files
+}
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	rows := [][]interface{}{{"1", "a'b"}, {"2", nil}}
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{Options{Format: "CSV", Delimiter: "|", BOM: true}, "\xEF\xBB\xBFid|name\n1|a'b\n2|\n"},
+		{Options{Format: "CSV", Encoding: "GBK"}, "id,name\n1,a'b\n2,\n"},
+		{Options{Format: "JSONL"}, "{\"id\":\"1\",\"name\":\"a'b\"}\n{\"id\":\"2\",\"name\":null}\n"},
+		{Options{Format: "SQL", Table: "t"}, "INSERT INTO `t` (`id`,`name`) VALUES\n('1','a\\'b'),\n('2',NULL);\n"},
+	}
+	for _, tt := range tests {
+		tt.opts.Dir, tt.opts.Name = dir, "export"
+		files := exportRows(t, tt.opts, rows...)
+		data, err := os.ReadFile(filepath.Join(dir, files[0]))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, string(data), tt.opts.Format)
+	}
+
+	files := exportRows(t, Options{Format: "PARQUET", Dir: dir, Name: "export"}, rows...)
+	assert.Equal(t, []string{"export.parquet"}, files)
+
+	ch := make(chan []interface{})
+	close(ch)
+	_, err := Export([]string{"id"}, ch, Options{Format: "XML"})
+	assert.Error(t, err)
+}
+
+func TestExportExcelSplit(t *testing.T) {
+	defer func(rows, sheets int) { maxExcelRows, maxExcelSheets = rows, sheets }(maxExcelRows, maxExcelSheets)
+	maxExcelRows, maxExcelSheets = 3, 2
+	dir := t.TempDir()
+	var rows [][]interface{}
+	for i := 0; i < 9; i++ {
+		rows = append(rows, []interface{}{i, "x"})
+	}
+	// 每个sheet 2行数据，每个文件2个sheet，9行数据拆分为3个文件
+	files := exportRows(t, Options{Format: "XLSX", Dir: dir, Name: "export"}, rows...)
+	assert.Equal(t, []string{"export.xlsx", "export_2.xlsx", "export_3.xlsx"}, files)
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "export_3.xlsx"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"Sheet1"}, f.GetSheetList())
+	data, err := f.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"id", "name"}, {"8", "x"}}, data)
+}
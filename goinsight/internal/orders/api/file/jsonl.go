@@ -0,0 +1,48 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"goInsight/global"
+	"os"
+)
+
+// ToJSONLines 每行写入一个JSON对象，字段顺序和查询的列顺序一致
+func ToJSONLines(columns []string, row <-chan []interface{}, fileName string) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		global.App.Log.Error("create jsonl file error", err.Error())
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	// 预先编码列名
+	keys := make([][]byte, len(columns))
+	for i, column := range columns {
+		if keys[i], err = json.Marshal(column); err != nil {
+			return
+		}
+	}
+	for v := range row {
+		w.WriteByte('{')
+		for i, value := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.Write(keys[i])
+			w.WriteByte(':')
+			data, err := json.Marshal(value)
+			if err != nil {
+				global.App.Log.Error("write row error", err.Error())
+				return err
+			}
+			w.Write(data)
+		}
+		if _, err = w.WriteString("}\n"); err != nil {
+			global.App.Log.Error("write row error", err.Error())
+			return
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,69 @@
+package file
+
+import (
+	"fmt"
+	"goInsight/global"
+	"os"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquet行组大小，写入器在内存中缓存一个行组的数据
+var parquetRowGroupSize int64 = 16 * 1024 * 1024
+
+// 生成parquet的列定义，所有列均为可空的UTF8字符串
+func parquetSchema(columns []string) []string {
+	// 元数据使用逗号和等号分隔，列名中的特殊字符替换为下划线
+	replacer := strings.NewReplacer(",", "_", "=", "_", " ", "_", ".", "_")
+	md := make([]string, len(columns))
+	seen := make(map[string]int, len(columns))
+	for i, column := range columns {
+		name := replacer.Replace(column)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		// 列名重复时增加序号
+		if n := seen[strings.ToLower(name)]; n > 0 {
+			name = fmt.Sprintf("%s_%d", name, n+1)
+		}
+		seen[strings.ToLower(name)]++
+		md[i] = fmt.Sprintf("name=%s, type=UTF8, repetitiontype=OPTIONAL", name)
+	}
+	return md
+}
+
+func ToParquet(columns []string, row <-chan []interface{}, fileName string) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		global.App.Log.Error("create parquet file error", err.Error())
+		return
+	}
+	defer f.Close()
+
+	pw, err := writer.NewCSVWriterFromWriter(parquetSchema(columns), f, 1)
+	if err != nil {
+		global.App.Log.Error("new parquet writer error", err.Error())
+		return
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	for v := range row {
+		rec := make([]*string, len(v))
+		for i, value := range v {
+			if value != nil {
+				s := cast.ToString(value)
+				rec[i] = &s
+			}
+		}
+		if err = pw.WriteString(rec); err != nil {
+			global.App.Log.Error("write row error", err.Error())
+			return
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		global.App.Log.Error("write parquet footer error", err.Error())
+		return
+	}
+	return nil
+}
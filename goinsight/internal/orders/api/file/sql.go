@@ -0,0 +1,82 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"goInsight/global"
+	"os"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// 每条INSERT语句包含的行数
+var sqlInsertBatchRows = 100
+
+var sqlValueReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+func quoteSQLIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// ToSQL 导出为批量INSERT语句，所有的值按照字符串写入，NULL保持为NULL
+func ToSQL(columns []string, row <-chan []interface{}, fileName, table string) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		global.App.Log.Error("create sql file error", err.Error())
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	if table == "" {
+		table = "export_table"
+	}
+	quotedColumns := make([]string, len(columns))
+	for i, column := range columns {
+		quotedColumns[i] = quoteSQLIdentifier(column)
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", quoteSQLIdentifier(table), strings.Join(quotedColumns, ","))
+
+	var n int
+	for v := range row {
+		if n == 0 {
+			w.WriteString(prefix)
+		} else {
+			w.WriteString(",\n")
+		}
+		w.WriteByte('(')
+		for i, value := range v {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if value == nil {
+				w.WriteString("NULL")
+				continue
+			}
+			w.WriteByte('\'')
+			sqlValueReplacer.WriteString(w, cast.ToString(value))
+			w.WriteByte('\'')
+		}
+		w.WriteByte(')')
+		n++
+		if n >= sqlInsertBatchRows {
+			if _, err = w.WriteString(";\n"); err != nil {
+				global.App.Log.Error("write row error", err.Error())
+				return
+			}
+			n = 0
+		}
+	}
+	if n > 0 {
+		w.WriteString(";\n")
+	}
+	return w.Flush()
+}
@@ -0,0 +1,343 @@
+/*
+@Desc    :   执行前和执行中检查目标实例的健康状态
+*/
+
+package guard
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"goInsight/config"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ActionPause = "pause" // 等待实例恢复后继续执行
+	ActionAbort = "abort" // 终止执行
+)
+
+// Guard 检查Threads_running、从库延迟和长事务，超过阈值时暂停或终止执行
+type Guard struct {
+	*base.DBConfig
+	ConnectionID int64                                 // 执行SQL的连接ID，检查长事务时排除
+	Open         func(*base.DBConfig) (*sql.DB, error) // 创建数据库连接
+	config       config.ExecuteGuard
+	mu           sync.Mutex
+	db           *sql.DB  // 检查使用独立的连接，避免和执行SQL的连接互相阻塞
+	replicas     []string // 没有登记从库时，通过SHOW SLAVE HOSTS发现的从库
+	discovered   bool
+}
+
+func New(dc *base.DBConfig, open func(*base.DBConfig) (*sql.DB, error)) *Guard {
+	return &Guard{DBConfig: dc, Open: open, config: global.App.Config.Guard}
+}
+
+func (g *Guard) Enabled() bool {
+	return g.config.Enable
+}
+
+func (g *Guard) Abort() bool {
+	return strings.EqualFold(g.config.Action, ActionAbort)
+}
+
+func (g *Guard) interval() time.Duration {
+	if g.config.CheckInterval <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(g.config.CheckInterval) * time.Second
+}
+
+// Close 关闭检查使用的连接
+func (g *Guard) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.db != nil {
+		g.db.Close()
+		g.db = nil
+	}
+}
+
+func (g *Guard) conn() (*sql.DB, error) {
+	if g.db == nil {
+		db, err := g.Open(g.DBConfig)
+		if err != nil {
+			return nil, err
+		}
+		g.db = db
+	}
+	return g.db, nil
+}
+
+// Check 检查实例状态，返回不通过的原因，返回空字符串表示检查通过
+func (g *Guard) Check() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	db, err := g.conn()
+	if err != nil {
+		return fmt.Sprintf("访问数据库(%s:%d)失败：%s", g.Hostname, g.Port, err.Error())
+	}
+	// 主库上的检查失败时（例如缺少PROCESS权限）仅记录日志，不阻塞执行
+	if g.config.MaxThreadsRunning > 0 {
+		threadsRunning, err := g.threadsRunning(db)
+		if err != nil {
+			global.App.Log.Warn(fmt.Sprintf("获取Threads_running失败：%s", err.Error()))
+		} else if threadsRunning > g.config.MaxThreadsRunning {
+			return fmt.Sprintf("Threads_running(%d)超过阈值%d", threadsRunning, g.config.MaxThreadsRunning)
+		}
+	}
+	if g.config.MaxTrxSeconds > 0 {
+		trxSeconds, err := g.longestTrx(db)
+		if err != nil {
+			global.App.Log.Warn(fmt.Sprintf("获取长事务失败：%s", err.Error()))
+		} else if trxSeconds > g.config.MaxTrxSeconds {
+			return fmt.Sprintf("存在运行%d秒的长事务，超过阈值%d秒", trxSeconds, g.config.MaxTrxSeconds)
+		}
+	}
+	// 从库不可访问时无法确认延迟，视为检查不通过
+	if g.config.MaxReplicaLag > 0 {
+		for _, replica := range g.checkedReplicas(db) {
+			lag, err := g.replicaLag(replica)
+			if err != nil {
+				return fmt.Sprintf("获取从库(%s)延迟失败：%s", replica, err.Error())
+			}
+			if lag > g.config.MaxReplicaLag {
+				return fmt.Sprintf("从库(%s)延迟%d秒，超过阈值%d秒", replica, lag, g.config.MaxReplicaLag)
+			}
+		}
+	}
+	return ""
+}
+
+// Before 执行前检查，pause模式下等待实例恢复，超过max_wait或cancelled被设置时返回错误
+func (g *Guard) Before(cancelled *atomic.Bool, logAndPublish func(string)) error {
+	if !g.Enabled() {
+		return nil
+	}
+	var lastReason string
+	deadline := time.Now().Add(time.Duration(g.config.MaxWait) * time.Second)
+	for {
+		if cancelled != nil && cancelled.Load() {
+			return errors.New("任务已被终止")
+		}
+		reason := g.Check()
+		if reason == "" {
+			if lastReason != "" {
+				logAndPublish("实例健康检查通过，继续执行")
+			}
+			return nil
+		}
+		if g.Abort() {
+			return fmt.Errorf("实例健康检查不通过，终止执行：%s", reason)
+		}
+		if g.config.MaxWait > 0 && time.Now().After(deadline) {
+			return fmt.Errorf("等待实例恢复超时(%d秒)，终止执行：%s", g.config.MaxWait, reason)
+		}
+		if reason != lastReason {
+			logAndPublish(fmt.Sprintf("实例健康检查不通过，暂停执行：%s", reason))
+			lastReason = reason
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Watch 执行期间定期检查实例状态，检查不通过时调用onViolation，恢复后调用onRecover，返回停止监控的函数
+func (g *Guard) Watch(onViolation func(reason string), onRecover func()) (stop func()) {
+	if !g.Enabled() {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(g.interval())
+		defer ticker.Stop()
+		var lastReason string
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			reason := g.Check()
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if reason == "" {
+				if lastReason != "" && onRecover != nil {
+					onRecover()
+				}
+				lastReason = ""
+				continue
+			}
+			if reason != lastReason {
+				onViolation(reason)
+				lastReason = reason
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// WatchStatement 监控单条语句的执行，abort模式下检查不通过时终止语句，pause模式下仅发送告警
+func (g *Guard) WatchStatement(renderType string) (stop func()) {
+	return g.Watch(func(reason string) {
+		if g.Abort() {
+			g.publish(fmt.Sprintf("实例健康检查不通过，终止执行：%s", reason), renderType)
+			if err := base.CancelRunningTask(g.TaskID); err != nil {
+				global.App.Log.Error(err)
+			}
+			return
+		}
+		g.publish(fmt.Sprintf("实例健康检查不通过：%s，语句执行中无法暂停，请关注实例状态", reason), renderType)
+	}, func() {
+		g.publish("实例健康检查恢复正常", renderType)
+	})
+}
+
+func (g *Guard) publish(msg, renderType string) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	base.PublishMessageToChannel(g.OrderID, fmt.Sprintf("[%s] %s", timestamp, msg), renderType)
+}
+
+func (g *Guard) threadsRunning(db *sql.DB) (int, error) {
+	var count int
+	if strings.EqualFold(g.DBType, "TiDB") {
+		// TiDB没有Threads_running状态，统计集群中活跃的会话数
+		err := db.QueryRow("SELECT COUNT(*) FROM INFORMATION_SCHEMA.CLUSTER_PROCESSLIST WHERE COMMAND != 'Sleep'").Scan(&count)
+		return count, err
+	}
+	var name string
+	err := db.QueryRow("SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &count)
+	return count, err
+}
+
+// 获取运行时间最长的事务的持续时间，单位秒
+func (g *Guard) longestTrx(db *sql.DB) (int, error) {
+	query := "SELECT IFNULL(MAX(TIMESTAMPDIFF(SECOND, trx_started, NOW())), 0) FROM INFORMATION_SCHEMA.INNODB_TRX WHERE trx_mysql_thread_id != ?"
+	if strings.EqualFold(g.DBType, "TiDB") {
+		query = "SELECT IFNULL(MAX(TIMESTAMPDIFF(SECOND, START_TIME, NOW())), 0) FROM INFORMATION_SCHEMA.CLUSTER_TIDB_TRX WHERE SESSION_ID != ?"
+	}
+	var seconds int
+	err := db.QueryRow(query, g.ConnectionID).Scan(&seconds)
+	return seconds, err
+}
+
+// 检查延迟的从库，优先使用登记的从库；没有登记时通过SHOW SLAVE HOSTS发现挂载在主库下的从库，从库需要配置report_host
+func (g *Guard) checkedReplicas(db *sql.DB) []string {
+	if len(g.Replicas) > 0 || strings.EqualFold(g.DBType, "TiDB") {
+		return g.Replicas
+	}
+	if !g.discovered {
+		g.discovered = true
+		// MySQL 8.0.22之前的版本不支持SHOW REPLICAS
+		rows, err := queryRows(db, "SHOW REPLICAS")
+		if err != nil {
+			rows, err = queryRows(db, "SHOW SLAVE HOSTS")
+		}
+		if err != nil {
+			global.App.Log.Warn(fmt.Sprintf("发现从库失败：%s", err.Error()))
+		}
+		g.replicas = discoverReplicas(rows)
+	}
+	return g.replicas
+}
+
+// 解析SHOW SLAVE HOSTS的结果，返回从库的host:port
+func discoverReplicas(rows []map[string]sql.NullString) []string {
+	var replicas []string
+	for _, row := range rows {
+		host, port := row["Host"].String, row["Port"].String
+		if host == "" || port == "" || port == "0" {
+			continue
+		}
+		replicas = append(replicas, net.JoinHostPort(host, port))
+	}
+	return replicas
+}
+
+// 获取从库的复制延迟，单位秒
+func (g *Guard) replicaLag(addr string) (int, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	replica := *g.DBConfig
+	replica.Hostname, replica.Port, replica.Schema = host, uint16(p), ""
+	db, err := g.Open(&replica)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// MySQL 8.0.22之前的版本不支持SHOW REPLICA STATUS
+	row, err := queryRow(db, "SHOW REPLICA STATUS")
+	if err != nil {
+		if row, err = queryRow(db, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	if row == nil {
+		return 0, errors.New("当前实例不是从库")
+	}
+	for _, key := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		if v, ok := row[key]; ok {
+			if !v.Valid {
+				return 0, errors.New("复制线程未运行")
+			}
+			return strconv.Atoi(v.String)
+		}
+	}
+	return 0, errors.New("未获取到复制延迟")
+}
+
+// 返回结果集的第一行，结果集为空时返回nil
+func queryRow(db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := queryRows(db, query)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return rows[0], nil
+}
+
+// 返回结果集的所有行
+func queryRows(db *sql.DB, query string) ([]map[string]sql.NullString, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]sql.NullString
+	for rows.Next() {
+		vals := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range vals {
+			dest[i] = &vals[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]sql.NullString, len(columns))
+		for i, c := range columns {
+			row[c] = vals[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,20 @@
+package guard
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscoverReplicas(t *testing.T) {
+	value := func(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+	rows := []map[string]sql.NullString{
+		{"Server_id": value("2"), "Host": value("10.0.0.2"), "Port": value("3306")},
+		// 从库没有配置report_host
+		{"Server_id": value("3"), "Host": value(""), "Port": value("3306")},
+		{"Server_id": value("4"), "Host": value("fe80::1"), "Port": value("3307")},
+	}
+	assert.Equal(t, []string{"10.0.0.2:3306", "[fe80::1]:3307"}, discoverReplicas(rows))
+	assert.Empty(t, discoverReplicas(nil))
+}
@@ -0,0 +1,480 @@
+package masking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"goInsight/internal/orders/api/base"
+	"goInsight/pkg/parser"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// 脱敏方式
+const (
+	MethodFull    = "FULL"    // 全部替换为*
+	MethodPartial = "PARTIAL" // 保留后4位，其余替换为*
+	MethodHash    = "HASH"    // 使用配置的密钥计算HMAC-SHA256，防止通过预计算的哈希表还原
+	MethodNull    = "NULL"    // 置为NULL
+)
+
+// 部分脱敏时保留的字符数
+const keepChars = 4
+
+// Rule 脱敏规则，定义在base中，执行配置不需要依赖脱敏的实现
+type Rule = base.MaskingRule
+
+// 查询结果的列可能来源的表和列
+type source struct {
+	schema string
+	table  string
+	column string
+}
+
+// Masker 根据脱敏规则处理导出的数据行
+type Masker struct {
+	methods []string // 每一列的脱敏方式，为空表示不脱敏
+	secret  []byte   // HASH脱敏的密钥
+	Applied []string // 生效的脱敏规则，格式：列名: 脱敏方式(规则)
+}
+
+// NewMasker 解析导出的SELECT语句，沿派生表、CTE和UNION的各个分支确定每一列的来源列，并匹配脱敏规则
+// 未指定表名的列匹配FROM子句中的所有表；无法确定来源的列（例如派生表中的通配符参与UNION），
+// 只要语句引用了有精确脱敏规则的表就按该规则脱敏，宁可多脱敏也不遗漏
+func NewMasker(rules []Rule, secret, sqltext, defaultSchema string, columns []string) (*Masker, error) {
+	m := &Masker{methods: make([]string, len(columns)), secret: []byte(secret)}
+	if len(rules) == 0 {
+		return m, nil
+	}
+	patterns := make([]*regexp.Regexp, len(rules))
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则%s的正则表达式错误：%s", rule.String(), err.Error())
+		}
+		patterns[i] = re
+	}
+	sources, unresolved, tables, err := resolveSources(sqltext, defaultSchema, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, column := range columns {
+		// 精确匹配的规则优先于正则匹配的规则
+		matched := -1
+		for j, rule := range rules {
+			if rule.Column != "" && matchColumn(rule, sources[i]) {
+				matched = j
+				break
+			}
+		}
+		if matched < 0 {
+			for j, re := range patterns {
+				if re != nil && matchPattern(re, column, sources[i]) {
+					matched = j
+					break
+				}
+			}
+		}
+		if matched >= 0 {
+			m.methods[i] = rules[matched].Method
+			m.Applied = append(m.Applied, fmt.Sprintf("%s: %s(%s)", column, rules[matched].Method, rules[matched].String()))
+			continue
+		}
+		if !unresolved[i] {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.Column != "" && matchTable(rule, tables) {
+				m.methods[i] = rule.Method
+				m.Applied = append(m.Applied, fmt.Sprintf("%s: %s(%s，无法确定来源)", column, rule.Method, rule.String()))
+				break
+			}
+		}
+	}
+	return m, nil
+}
+
+// 语句是否引用了规则所在的表
+func matchTable(rule Rule, tables []source) bool {
+	for _, t := range tables {
+		if (rule.Schema == "" || strings.EqualFold(rule.Schema, t.schema)) && strings.EqualFold(rule.Table, t.table) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchColumn(rule Rule, sources []source) bool {
+	for _, s := range sources {
+		if (rule.Schema == "" || strings.EqualFold(rule.Schema, s.schema)) &&
+			strings.EqualFold(rule.Table, s.table) &&
+			strings.EqualFold(rule.Column, s.column) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(re *regexp.Regexp, column string, sources []source) bool {
+	if re.MatchString(column) {
+		return true
+	}
+	for _, s := range sources {
+		if re.MatchString(s.column) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask 对数据行进行脱敏，直接修改传入的数据行
+func (m *Masker) Mask(row []interface{}) {
+	if m == nil || len(m.Applied) == 0 {
+		return
+	}
+	for i, method := range m.methods {
+		if method == "" || i >= len(row) || row[i] == nil {
+			continue
+		}
+		row[i] = MaskValue(method, fmt.Sprint(row[i]), m.secret)
+	}
+}
+
+// MaskValue 按脱敏方式处理单个值，secret为HASH脱敏的密钥
+func MaskValue(method, value string, secret []byte) interface{} {
+	switch method {
+	case MethodFull:
+		return strings.Repeat("*", len([]rune(value)))
+	case MethodPartial:
+		runes := []rune(value)
+		if len(runes) <= keepChars {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-keepChars) + string(runes[len(runes)-keepChars:])
+	case MethodHash:
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))
+	case MethodNull:
+		return nil
+	}
+	return value
+}
+
+// 关系的输出列
+type column struct {
+	name       string
+	sources    []source
+	unresolved bool // 无法确定来源
+}
+
+// FROM子句中的关系：基础表、派生表或CTE
+type relation struct {
+	table      *source     // 基础表，列名即来源列
+	columns    []column    // 派生表和CTE的输出列
+	wildcard   []*relation // 通配符展开的关系，只能按列名查找
+	unresolved bool        // 无法解析的关系，例如递归CTE引用自身
+}
+
+// 按列名查找来源，found表示关系中可能存在该列
+func (r *relation) lookup(name string) (sources []source, found, unresolved bool) {
+	if r.unresolved {
+		return nil, true, true
+	}
+	if r.table != nil {
+		return []source{{schema: r.table.schema, table: r.table.table, column: name}}, true, false
+	}
+	for _, c := range r.columns {
+		if strings.EqualFold(c.name, name) {
+			sources = append(sources, c.sources...)
+			found = true
+			unresolved = unresolved || c.unresolved
+		}
+	}
+	for _, w := range r.wildcard {
+		if s, f, u := w.lookup(name); f {
+			sources = append(sources, s...)
+			found = true
+			unresolved = unresolved || u
+		}
+	}
+	return
+}
+
+// 按位置获取输出列，包含通配符时无法确定位置
+func (r *relation) positional() ([]column, bool) {
+	if r.unresolved || r.table != nil || len(r.wildcard) > 0 {
+		return nil, false
+	}
+	return r.columns, true
+}
+
+// FROM子句中带别名的关系
+type namedRelation struct {
+	name string
+	rel  *relation
+}
+
+// 名称解析的作用域：CTE定义和外层查询的FROM子句（用于关联子查询）
+type scope struct {
+	parent *scope
+	ctes   map[string]*relation
+	outer  []namedRelation
+}
+
+func (s *scope) cte(name string) (*relation, bool) {
+	for ; s != nil; s = s.parent {
+		if r, ok := s.ctes[name]; ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+type resolver struct {
+	defaultSchema string
+}
+
+func (r *resolver) with(w *ast.WithClause, parent *scope) *scope {
+	if w == nil {
+		return parent
+	}
+	s := &scope{parent: parent, ctes: make(map[string]*relation)}
+	for _, cte := range w.CTEs {
+		// 递归CTE引用自身时无法确定来源
+		s.ctes[cte.Name.L] = &relation{unresolved: true}
+		rel := r.resultSet(cte.Query.Query, s)
+		if len(cte.ColNameList) > 0 {
+			cols, ok := rel.positional()
+			renamed := make([]column, len(cte.ColNameList))
+			for i, name := range cte.ColNameList {
+				renamed[i] = column{name: name.O, unresolved: true}
+				if ok && len(cols) == len(cte.ColNameList) {
+					renamed[i] = column{name: name.O, sources: cols[i].sources, unresolved: cols[i].unresolved}
+				}
+			}
+			rel = &relation{columns: renamed}
+		}
+		s.ctes[cte.Name.L] = rel
+	}
+	return s
+}
+
+func (r *resolver) resultSet(node ast.Node, s *scope) *relation {
+	switch n := node.(type) {
+	case *ast.SelectStmt:
+		return r.selectStmt(n, s)
+	case *ast.SetOprStmt:
+		return r.setOpr(n.SelectList, r.with(n.With, s))
+	case *ast.SetOprSelectList:
+		return r.setOpr(n, s)
+	case *ast.SubqueryExpr:
+		return r.resultSet(n.Query, s)
+	}
+	return &relation{unresolved: true}
+}
+
+// UNION等集合操作按位置合并各个分支的列来源
+func (r *resolver) setOpr(list *ast.SetOprSelectList, s *scope) *relation {
+	if list == nil {
+		return &relation{unresolved: true}
+	}
+	s = r.with(list.With, s)
+	var result *relation
+	for _, sel := range list.Selects {
+		cols, ok := r.resultSet(sel, s).positional()
+		if !ok || (result != nil && len(cols) != len(result.columns)) {
+			return &relation{unresolved: true}
+		}
+		if result == nil {
+			result = &relation{columns: make([]column, len(cols))}
+			for i, c := range cols {
+				result.columns[i] = column{name: c.name, sources: append([]source(nil), c.sources...), unresolved: c.unresolved}
+			}
+			continue
+		}
+		for i, c := range cols {
+			result.columns[i].sources = append(result.columns[i].sources, c.sources...)
+			result.columns[i].unresolved = result.columns[i].unresolved || c.unresolved
+		}
+	}
+	if result == nil {
+		return &relation{unresolved: true}
+	}
+	return result
+}
+
+func (r *resolver) selectStmt(sel *ast.SelectStmt, s *scope) *relation {
+	s = r.with(sel.With, s)
+	var from []namedRelation
+	if sel.From != nil && sel.From.TableRefs != nil {
+		from = r.from(sel.From.TableRefs, s, from)
+	}
+	rel := &relation{}
+	// TABLE t语句等同于SELECT * FROM t
+	if sel.Fields == nil {
+		if len(from) == 0 {
+			return &relation{unresolved: true}
+		}
+		for _, n := range from {
+			rel.wildcard = append(rel.wildcard, n.rel)
+		}
+		return rel
+	}
+	for _, f := range sel.Fields.Fields {
+		if f.WildCard != nil {
+			matched := false
+			for _, n := range from {
+				if f.WildCard.Table.L == "" || n.name == f.WildCard.Table.L {
+					rel.wildcard = append(rel.wildcard, n.rel)
+					matched = true
+				}
+			}
+			if !matched {
+				rel.wildcard = append(rel.wildcard, &relation{unresolved: true})
+			}
+			continue
+		}
+		name := f.AsName.O
+		if name == "" {
+			if c, ok := f.Expr.(*ast.ColumnNameExpr); ok {
+				name = c.Name.Name.O
+			} else {
+				name = f.Text()
+			}
+		}
+		v := &exprVisitor{r: r, from: from, scope: s}
+		f.Expr.Accept(v)
+		rel.columns = append(rel.columns, column{name: name, sources: v.sources, unresolved: v.unresolved})
+	}
+	return rel
+}
+
+// 遍历FROM子句，记录别名对应的关系
+func (r *resolver) from(node ast.ResultSetNode, s *scope, out []namedRelation) []namedRelation {
+	switch n := node.(type) {
+	case *ast.Join:
+		if n.Left != nil {
+			out = r.from(n.Left, s, out)
+		}
+		if n.Right != nil {
+			out = r.from(n.Right, s, out)
+		}
+	case *ast.TableSource:
+		name := n.AsName.L
+		if t, ok := n.Source.(*ast.TableName); ok {
+			if name == "" {
+				name = t.Name.L
+			}
+			if t.Schema.L == "" {
+				if rel, ok := s.cte(t.Name.L); ok {
+					return append(out, namedRelation{name: name, rel: rel})
+				}
+			}
+			schema := t.Schema.O
+			if schema == "" {
+				schema = r.defaultSchema
+			}
+			return append(out, namedRelation{name: name, rel: &relation{table: &source{schema: schema, table: t.Name.O}}})
+		}
+		return append(out, namedRelation{name: name, rel: r.resultSet(n.Source, s)})
+	}
+	return out
+}
+
+// 收集表达式引用的列的来源，子查询按其输出列计算
+type exprVisitor struct {
+	r          *resolver
+	from       []namedRelation
+	scope      *scope
+	sources    []source
+	unresolved bool
+}
+
+func (v *exprVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	switch n := in.(type) {
+	case *ast.SubqueryExpr:
+		rel := v.r.resultSet(n.Query, &scope{parent: v.scope, outer: v.from})
+		cols, ok := rel.positional()
+		if !ok {
+			v.unresolved = true
+			return in, true
+		}
+		for _, c := range cols {
+			v.sources = append(v.sources, c.sources...)
+			v.unresolved = v.unresolved || c.unresolved
+		}
+		return in, true
+	case *ast.ColumnNameExpr:
+		sources, found, unresolved := lookupColumn(n.Name, v.from)
+		// 关联子查询引用外层查询的列
+		for s := v.scope; !found && s != nil; s = s.parent {
+			sources, found, unresolved = lookupColumn(n.Name, s.outer)
+		}
+		v.sources = append(v.sources, sources...)
+		v.unresolved = v.unresolved || !found || unresolved
+		return in, true
+	}
+	return in, false
+}
+
+func (v *exprVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// 在FROM子句的关系中查找列，未指定表名时匹配所有关系
+func lookupColumn(c *ast.ColumnName, from []namedRelation) (sources []source, found, unresolved bool) {
+	for _, n := range from {
+		if c.Table.L != "" && n.name != c.Table.L {
+			continue
+		}
+		if s, f, u := n.rel.lookup(c.Name.O); f {
+			sources = append(sources, s...)
+			found = true
+			unresolved = unresolved || u
+		}
+	}
+	return
+}
+
+// 解析每一列可能来源的表和列，并返回语句引用的所有表
+func resolveSources(sqltext, defaultSchema string, columns []string) ([][]source, []bool, []source, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	collector := &parser.TableNameCollector{}
+	stmt.Accept(collector)
+	var tables []source
+	for _, t := range collector.Tables {
+		schema := t.Schema.O
+		if schema == "" {
+			schema = defaultSchema
+		}
+		tables = append(tables, source{schema: schema, table: t.Name.O})
+	}
+
+	r := &resolver{defaultSchema: defaultSchema}
+	rel := r.resultSet(stmt, nil)
+	sources := make([][]source, len(columns))
+	unresolved := make([]bool, len(columns))
+	// 没有通配符时，查询结果的列和输出列一一对应，否则按列名查找
+	if cols, ok := rel.positional(); ok && len(cols) == len(columns) {
+		for i, c := range cols {
+			sources[i], unresolved[i] = c.sources, c.unresolved
+		}
+		return sources, unresolved, tables, nil
+	}
+	for i, column := range columns {
+		s, found, u := rel.lookup(column)
+		sources[i], unresolved[i] = s, !found || u
+	}
+	return sources, unresolved, tables, nil
+}
@@ -0,0 +1,107 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		method string
+		value  string
+		want   interface{}
+	}{
+		{MethodFull, "张三abc", "*****"},
+		{MethodPartial, "13812345678", "*******5678"},
+		{MethodPartial, "123", "***"},
+		{MethodHash, "abc", "9946dad4e00e913fc8be8e5d3f7e110a4a9e832f83fb09c345285d78638d8a0e"},
+		{MethodNull, "abc", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MaskValue(tt.method, tt.value, []byte("secret")), tt.method)
+	}
+	// 不同的密钥得到不同的哈希，不能用未加盐的SHA256还原
+	assert.NotEqual(t, MaskValue(MethodHash, "abc", []byte("secret")), MaskValue(MethodHash, "abc", []byte("other")))
+	assert.NotEqual(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", MaskValue(MethodHash, "abc", nil))
+}
+
+func TestNewMasker(t *testing.T) {
+	rules := []Rule{
+		{Schema: "db", Table: "users", Column: "phone", Method: MethodPartial},
+		{Table: "orders", Column: "amount", Method: MethodNull},
+		{Pattern: "id_?card", Method: MethodHash},
+		{Pattern: "phone", Method: MethodFull},
+	}
+	tests := []struct {
+		sql     string
+		columns []string
+		want    []string
+	}{
+		// 别名和表名前缀
+		{"select u.phone as p, o.amount, o.phone from users u join orders o on u.id=o.uid",
+			[]string{"p", "amount", "phone"},
+			[]string{"p: PARTIAL(db.users.phone)", "amount: NULL(orders.amount)", "phone: FULL(/phone/)"}},
+		// 其他库的同名表不匹配精确规则
+		{"select phone, name from other.users",
+			[]string{"phone", "name"},
+			[]string{"phone: FULL(/phone/)"}},
+		// 通配符
+		{"select * from users",
+			[]string{"id", "phone", "IDCard"},
+			[]string{"phone: PARTIAL(db.users.phone)", "IDCard: HASH(/id_?card/)"}},
+		// 表达式中引用的列
+		{"select concat(phone, '') as c from users",
+			[]string{"c"},
+			[]string{"c: PARTIAL(db.users.phone)"}},
+		// 派生表的列追溯到基础表
+		{"select t.phone from (select phone from users) t",
+			[]string{"phone"},
+			[]string{"phone: PARTIAL(db.users.phone)"}},
+		{"select x from (select phone as x from users) t",
+			[]string{"x"},
+			[]string{"x: PARTIAL(db.users.phone)"}},
+		{"select y from (select x as y from (select phone as x from users) a) b",
+			[]string{"y"},
+			[]string{"y: PARTIAL(db.users.phone)"}},
+		{"select x from (select * from users) t",
+			[]string{"x"},
+			nil},
+		// CTE
+		{"with t as (select phone as x from users) select x from t",
+			[]string{"x"},
+			[]string{"x: PARTIAL(db.users.phone)"}},
+		{"with t(x) as (select phone from users) select x from t",
+			[]string{"x"},
+			[]string{"x: PARTIAL(db.users.phone)"}},
+		// UNION的各个分支按位置合并
+		{"select 1 as a union select phone from users",
+			[]string{"a"},
+			[]string{"a: PARTIAL(db.users.phone)"}},
+		{"select name as a from users union all select id from orders",
+			[]string{"a"},
+			nil},
+		// 标量子查询
+		{"select (select phone as x from users limit 1) as y",
+			[]string{"y"},
+			[]string{"y: PARTIAL(db.users.phone)"}},
+		// 无法确定来源时按引用的表的精确规则脱敏
+		{"select a from (select name as a from users union select * from users) t",
+			[]string{"a"},
+			[]string{"a: PARTIAL(db.users.phone，无法确定来源)"}},
+	}
+	for _, tt := range tests {
+		m, err := NewMasker(rules, "secret", tt.sql, "db", tt.columns)
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, m.Applied, tt.sql)
+	}
+
+	m, err := NewMasker(rules, "secret", "select id, phone, amount from users", "db", []string{"id", "phone", "amount"})
+	assert.NoError(t, err)
+	row := []interface{}{"1", "13812345678", nil}
+	m.Mask(row)
+	assert.Equal(t, []interface{}{"1", "*******5678", nil}, row)
+
+	_, err = NewMasker([]Rule{{Pattern: "(", Method: MethodFull}}, "secret", "select 1", "db", []string{"1"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+/*
+@Desc    :   分批执行DML
+*/
+
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/guard"
+	"goInsight/pkg/parser"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+)
+
+// 默认每批次的主键范围大小
+const defaultChunkSize int64 = 1000
+
+// 可拆分执行的单表UPDATE/DELETE语句
+type batchDML struct {
+	stmt     ast.StmtNode
+	where    ast.ExprNode
+	schema   string
+	table    string
+	tableRef string // 包含别名的表引用，用于获取主键范围
+}
+
+// 解析SQL，仅支持不包含ORDER BY/LIMIT的单表UPDATE/DELETE
+func newBatchDML(sqltext, defaultSchema string) (*batchDML, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, err
+	}
+	var refs *ast.TableRefsClause
+	b := &batchDML{stmt: stmt}
+	switch s := stmt.(type) {
+	case *ast.UpdateStmt:
+		if s.MultipleTable || s.Order != nil || s.Limit != nil {
+			return nil, errors.New("分批执行仅支持不包含ORDER BY/LIMIT的单表UPDATE语句")
+		}
+		refs, b.where = s.TableRefs, s.Where
+	case *ast.DeleteStmt:
+		if s.IsMultiTable || s.Order != nil || s.Limit != nil {
+			return nil, errors.New("分批执行仅支持不包含ORDER BY/LIMIT的单表DELETE语句")
+		}
+		refs, b.where = s.TableRefs, s.Where
+	default:
+		return nil, errors.New("分批执行仅支持UPDATE/DELETE语句")
+	}
+	if refs == nil || refs.TableRefs == nil || refs.TableRefs.Right != nil {
+		return nil, errors.New("分批执行不支持多表关联")
+	}
+	source, ok := refs.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return nil, errors.New("分批执行不支持多表关联")
+	}
+	table, ok := source.Source.(*ast.TableName)
+	if !ok {
+		return nil, errors.New("分批执行不支持子查询")
+	}
+	b.schema, b.table = table.Schema.O, table.Name.O
+	if b.schema == "" {
+		b.schema = defaultSchema
+	}
+	if b.tableRef, err = restoreNode(source); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func restoreNode(node ast.Node) (string, error) {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags|format.RestoreStringWithoutCharset, &sb)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// 拼接原始WHERE条件
+func (b *batchDML) whereText(extra string) (string, error) {
+	if b.where == nil {
+		return extra, nil
+	}
+	where, err := restoreNode(b.where)
+	if err != nil {
+		return "", err
+	}
+	if extra == "" {
+		return where, nil
+	}
+	return fmt.Sprintf("(%s) AND %s", where, extra), nil
+}
+
+// 获取WHERE条件匹配的主键范围
+func (b *batchDML) rangeSQL(pk string) (string, error) {
+	where, err := b.whereText("")
+	if err != nil {
+		return "", err
+	}
+	query := fmt.Sprintf("SELECT MIN(`%s`), MAX(`%s`) FROM %s", pk, pk, b.tableRef)
+	if where != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, where)
+	}
+	return query, nil
+}
+
+// 生成指定主键范围[lo, hi)的语句
+func (b *batchDML) chunkSQL(pk string, lo, hi int64) (string, error) {
+	where, err := b.whereText(fmt.Sprintf("`%s` >= %d AND `%s` < %d", pk, lo, pk, hi))
+	if err != nil {
+		return "", err
+	}
+	// 借助SELECT语句解析出新的WHERE表达式
+	stmt, err := parser.NewParseOneStmt("SELECT 1 FROM DUAL WHERE "+where, "", "")
+	if err != nil {
+		return "", err
+	}
+	expr := stmt.(*ast.SelectStmt).Where
+	switch s := b.stmt.(type) {
+	case *ast.UpdateStmt:
+		s.Where = expr
+		defer func() { s.Where = b.where }()
+	case *ast.DeleteStmt:
+		s.Where = expr
+		defer func() { s.Where = b.where }()
+	}
+	return restoreNode(b.stmt)
+}
+
+// 按照步长拆分主键范围，返回左闭右开区间
+func splitChunks(min, max, size int64) [][2]int64 {
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	var chunks [][2]int64
+	for lo := min; lo <= max; lo += size {
+		hi := lo + size
+		if hi > max {
+			hi = max + 1
+		}
+		chunks = append(chunks, [2]int64{lo, hi})
+	}
+	return chunks
+}
+
+// 获取表的主键列，分批执行要求表有且仅有一个整型主键
+func DaoMySQLGetIntPrimaryKey(db *sql.DB, schema, table string) (string, error) {
+	rows, err := db.Query(
+		"SELECT COLUMN_NAME, DATA_TYPE FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND COLUMN_KEY='PRI'",
+		schema, table,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var columns, types []string
+	for rows.Next() {
+		var column, dataType string
+		if err := rows.Scan(&column, &dataType); err != nil {
+			return "", err
+		}
+		columns = append(columns, column)
+		types = append(types, strings.ToLower(dataType))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(columns) != 1 {
+		return "", fmt.Errorf("表`%s`.`%s`没有主键或为联合主键，不支持分批执行", schema, table)
+	}
+	switch types[0] {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		return columns[0], nil
+	}
+	return "", fmt.Errorf("表`%s`.`%s`的主键类型为%s，分批执行仅支持整型主键", schema, table, types[0])
+}
+
+// 分批执行DML，返回累计影响行数；执行中断时返回已执行批次的影响行数和错误
+func (e *ExecuteMySQLDML) executeInBatches(db *sql.DB, g *guard.Guard, ch chan<- int64, cancelled *atomic.Bool, logAndPublish func(string)) (int64, error) {
+	b, err := newBatchDML(e.SQL, e.Schema)
+	if err != nil {
+		return 0, err
+	}
+	pk, err := DaoMySQLGetIntPrimaryKey(db, b.schema, b.table)
+	if err != nil {
+		return 0, err
+	}
+	// 获取WHERE条件匹配的主键范围
+	query, err := b.rangeSQL(pk)
+	if err != nil {
+		return 0, err
+	}
+	var min, max sql.NullInt64
+	if err := db.QueryRow(query).Scan(&min, &max); err != nil {
+		return 0, err
+	}
+	if !min.Valid || !max.Valid {
+		logAndPublish("没有匹配WHERE条件的记录，跳过执行")
+		return 0, nil
+	}
+	cfg := global.App.Config.BatchDML
+	chunks := splitChunks(min.Int64, max.Int64, cfg.ChunkSize)
+	logAndPublish(fmt.Sprintf("主键`%s`范围[%d, %d]，拆分为%d个批次执行", pk, min.Int64, max.Int64, len(chunks)))
+
+	// Send a signal to the processlist goroutine
+	ch <- 1
+	defer close(ch)
+
+	var affectedRows int64
+	for i, chunk := range chunks {
+		// 每个批次执行前检查实例健康状态
+		if err := g.Before(cancelled, logAndPublish); err != nil {
+			return affectedRows, err
+		}
+		query, err := b.chunkSQL(pk, chunk[0], chunk[1])
+		if err != nil {
+			return affectedRows, err
+		}
+		result, err := db.Exec(query)
+		if err != nil {
+			return affectedRows, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return affectedRows, err
+		}
+		affectedRows += rows
+		logAndPublish(fmt.Sprintf("第%d/%d批次执行成功，主键范围[%d, %d)，影响行数%d", i+1, len(chunks), chunk[0], chunk[1], rows))
+		if cfg.SleepMilliseconds > 0 && i < len(chunks)-1 {
+			time.Sleep(time.Duration(cfg.SleepMilliseconds) * time.Millisecond)
+		}
+	}
+	return affectedRows, nil
+}
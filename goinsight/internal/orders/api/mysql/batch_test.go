@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitChunks(t *testing.T) {
+	assert.Equal(t, [][2]int64{{1, 4}, {4, 7}, {7, 9}}, splitChunks(1, 8, 3))
+	assert.Equal(t, [][2]int64{{5, 6}}, splitChunks(5, 5, 100))
+	assert.Equal(t, 2, len(splitChunks(1, 1500, 0)))
+}
+
+func TestBatchDML(t *testing.T) {
+	tests := []struct {
+		sql       string
+		wantRange string
+		wantChunk string
+	}{
+		{
+			sql:       "update t1 set c1=1 where c2>10",
+			wantRange: "SELECT MIN(`id`), MAX(`id`) FROM `t1` WHERE `c2`>10",
+			wantChunk: "UPDATE `t1` SET `c1`=1 WHERE (`c2`>10) AND `id`>=1 AND `id`<1001",
+		},
+		{
+			sql:       "delete from d1.t1 where c2 = 'a' or c3 is null",
+			wantRange: "SELECT MIN(`id`), MAX(`id`) FROM `d1`.`t1` WHERE `c2`='a' OR `c3` IS NULL",
+			wantChunk: "DELETE FROM `d1`.`t1` WHERE (`c2`='a' OR `c3` IS NULL) AND `id`>=1 AND `id`<1001",
+		},
+		{
+			sql:       "update t1 a set a.c1=2",
+			wantRange: "SELECT MIN(`id`), MAX(`id`) FROM `t1` AS `a`",
+			wantChunk: "UPDATE `t1` AS `a` SET `a`.`c1`=2 WHERE `id`>=1 AND `id`<1001",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			b, err := newBatchDML(tt.sql, "d0")
+			assert.NoError(t, err)
+			query, err := b.rangeSQL("id")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRange, query)
+			query, err = b.chunkSQL("id", 1, 1001)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantChunk, query)
+		})
+	}
+
+	// 不支持的语句
+	for _, sql := range []string{
+		"update t1, t2 set t1.c1=t2.c1 where t1.id=t2.id",
+		"delete from t1 where id > 0 limit 10",
+		"insert into t1 values(1)",
+	} {
+		_, err := newBatchDML(sql, "d0")
+		assert.Error(t, err, sql)
+	}
+}
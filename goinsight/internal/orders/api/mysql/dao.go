@@ -7,6 +7,7 @@ import (
 	"goInsight/global"
 	"goInsight/internal/orders/api/base"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -176,16 +177,19 @@ func DaoMySQLGetProcesslist(dbconfig *base.DBConfig, order_id string, connection
 	}
 }
 
-// 获取MySQL数据库的Position
-func DaoMySQLGetBinlogPos(db *sql.DB) (file string, position int64, err error) {
+// 获取MySQL数据库的Position和Executed_Gtid_Set，未开启GTID时gtidSet为空
+func DaoMySQLGetBinlogPos(db *sql.DB) (file string, position int64, gtidSet string, err error) {
 	// Query the database for the master status
+	// MySQL 8.4移除了SHOW MASTER STATUS，使用SHOW BINARY LOG STATUS代替
 	data, err := DaoMySQLQuery(db, "SHOW MASTER STATUS")
 	if err != nil {
-		return file, position, err
+		if data, err = DaoMySQLQuery(db, "SHOW BINARY LOG STATUS"); err != nil {
+			return file, position, gtidSet, err
+		}
 	}
 	// Check if data is empty
 	if len(*data) == 0 {
-		return file, position, errors.New("Failed to get MySQL position: no valid row found，请检查MySQL是否开启了binlog")
+		return file, position, gtidSet, errors.New("Failed to get MySQL position: no valid row found，请检查MySQL是否开启了binlog")
 	}
 	// Expect to return one row of data
 	row := (*data)[0]
@@ -193,7 +197,23 @@ func DaoMySQLGetBinlogPos(db *sql.DB) (file string, position int64, err error) {
 	file = row["File"].(string)
 	position, err = strconv.ParseInt(row["Position"].(string), 10, 64)
 	if err != nil {
-		return file, position, errors.New("Failed to get MySQL position: position parsing error")
+		return file, position, gtidSet, errors.New("Failed to get MySQL position: position parsing error")
+	}
+	if v, ok := row["Executed_Gtid_Set"].(string); ok {
+		gtidSet = strings.ReplaceAll(v, "\n", "")
+	}
+	return file, position, gtidSet, nil
+}
+
+// 终止指定连接正在执行的语句
+func DaoMySQLKillQuery(dbconfig *base.DBConfig, connection_id int64) error {
+	// Create a new database connection
+	db, err := NewMySQLCnx(dbconfig)
+	if err != nil {
+		return err
 	}
-	return file, position, nil
+	defer db.Close()
+	// KILL QUERY terminates the statement but leaves the connection intact
+	_, err = db.Exec(fmt.Sprintf("KILL QUERY %d", connection_id))
+	return err
 }
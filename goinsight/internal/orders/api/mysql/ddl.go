@@ -11,10 +11,11 @@ import (
 	"fmt"
 	"goInsight/global"
 	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
 	"goInsight/pkg/parser"
 	"goInsight/pkg/utils"
-	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -52,13 +53,45 @@ func ExecuteOnlineDDL(dc *base.DBConfig) (data base.ReturnData, err error) {
 	}
 	logAndPublish(fmt.Sprintf("数据库Connection ID：%d", connectionID))
 
+	// 注册任务，支持通过KILL QUERY终止执行
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(dc.OrderID, dc.TaskID, func() error {
+		cancelled.Store(true)
+		return DaoMySQLKillQuery(dc, connectionID)
+	})
+	defer base.UnregisterRunningTask(dc.TaskID)
+
+	// 执行前检查实例健康状态
+	g := guard.New(dc, NewMySQLCnx)
+	g.ConnectionID = connectionID
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
+	// 执行前获取表结构，用于生成回滚SQL
+	rollback, err := base.NewDDLRollback(dc.SQL, dc.Schema)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("不生成回滚SQL，原因：%s", err.Error()))
+	} else {
+		rollback.Capture(db)
+	}
+
 	// SHOW PROCESS
 	ch1 := make(chan int64)
 	go DaoMySQLGetProcesslist(dc, dc.OrderID, connectionID, ch1)
 
+	// 语句开始执行前终止时KILL没有可终止的语句，需要在这里停止
+	if cancelled.Load() {
+		close(ch1)
+		return logErrorAndReturn(base.SQLExecuteError{Err: errors.New("任务已被终止")}, "SQL执行失败，错误：")
+	}
+
 	// 执行SQL
 	startTime := time.Now()
+	stopWatch := g.WatchStatement("")
 	affectedRows, err := DaoMySQLExecute(db, dc.SQL, ch1)
+	stopWatch()
 	if err != nil {
 		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "SQL执行失败，错误：")
 	}
@@ -66,6 +99,11 @@ func ExecuteOnlineDDL(dc *base.DBConfig) (data base.ReturnData, err error) {
 	executeCostTime := utils.HumanfriendlyTimeUnit(endTime.Sub(startTime))
 	logAndPublish(fmt.Sprintf("SQL执行成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
 
+	// 生成回滚SQL
+	if rollback != nil {
+		data.RollbackSQL = rollback.RollbackSQL(logAndPublish)
+	}
+
 	// 返回数据
 	data.ExecuteLog = strings.Join(executeLog, "\n")
 	data.AffectedRows = affectedRows
@@ -78,118 +116,44 @@ type ExecuteMySQLDDL struct {
 	*base.DBConfig
 }
 
-func (e *ExecuteMySQLDDL) ExecuteCommand(command string) (data []string, err error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// ExecuteCommand 执行外部工具，输出推送到前端并交给onOutput处理，返回输出内容
+func (e *ExecuteMySQLDDL) ExecuteCommand(ctx context.Context, onOutput func(string), name string, args ...string) (data []string, err error) {
 	ch := make(chan string)
+	done := make(chan struct{})
 	// 读取输出
 	go func(ch <-chan string) {
-		for {
-			v, ok := <-ch
-			if !ok {
-				break
-			}
+		defer close(done)
+		for v := range ch {
 			data = append(data, v)
-			err = utils.Publish(context.Background(), e.OrderID, v, "ghost")
-			if err != nil {
+			if onOutput != nil {
+				onOutput(v)
+			}
+			if err := utils.Publish(context.Background(), e.OrderID, v, "ghost"); err != nil {
 				global.App.Log.Error(err)
 			}
 		}
 	}(ch)
-	err = base.Command(ctx, ch, command)
-	if err != nil {
-		return
-	}
+	err = base.CommandArgs(ctx, ch, name, args...)
+	close(ch)
+	<-done
 	return
 }
 
-// 执行ghost封装后的DDL
-func (e *ExecuteMySQLDDL) ExecuteDDLWithGhost(sql string) (data base.ReturnData, err error) {
-	var executeLog []string
-
-	// Function to log messages and publish
-	logAndPublish := func(msg string) {
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		formattedMsg := fmt.Sprintf("[%s] %s\n", timestamp, msg)
-		executeLog = append(executeLog, formattedMsg)
-		base.PublishMessageToChannel(e.OrderID, formattedMsg, "ghost")
-	}
-
-	// Logging function for errors
-	logErrorAndReturn := func(err error, errMsg string) (base.ReturnData, error) {
-		logAndPublish(errMsg + err.Error())
-		data.ExecuteLog = strings.Join(executeLog, "\n")
-		return data, err
-	}
-
-	// 移除字符串前后的所有空白字符，包括空格、制表符、换行符等
-	newSQL := strings.TrimSpace(sql)
-	logAndPublish("移除SQL语句前后的所有空白字符，包括空格、制表符、换行符等")
-
-	// 获取表名
-	tableName, err := parser.GetTableNameFromAlterStatement(sql)
-	if err != nil {
-		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "解析SQL提取表名失败")
-	}
-	logAndPublish("从SQL语句中提取表名成功")
-
-	// 正则匹配
-	syntax := `(?i)^ALTER(\s+)TABLE(\s+)([\S]*)(\s+)(ADD|CHANGE|RENAME|MODIFY|DROP|ENGINE|CONVERT)(\s*)([\S\s]*)`
-	re, err := regexp.Compile(syntax)
+// 使用选择的OSC引擎执行ALTER TABLE
+func (e *ExecuteMySQLDDL) ExecuteAlterTable() (data base.ReturnData, err error) {
+	alter, err := ParseAlterTable(e.SQL, e.Schema)
 	if err != nil {
-		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "正则匹配SQL语句失败")
+		return data, base.SQLExecuteError{Err: err}
 	}
-	match := re.FindStringSubmatch(newSQL)
-	if len(match) < 5 {
-		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "正则匹配SQL语句失败")
-	}
-	logAndPublish("正则匹配SQL语句成功")
-
-	//  将反引号处理为空，将双引号处理成单引号
-	vv := strings.Replace(strings.Replace(strings.Join(match[5:], ""), "`", "", -1), "\"", "'", -1)
-	logAndPublish("将反引号处理为空，将双引号处理成单引号")
-
-	// 生成ghost命令
-	logAndPublish("生成gh-ost执行命令")
-
-	ghostCMDParts := []string{
-		global.App.Config.Ghost.Path,
-		strings.Join(global.App.Config.Ghost.Args, " "),
-		fmt.Sprintf("--user=\"%s\" --password=\"%s\"", e.UserName, e.Password),
-		fmt.Sprintf("--host=\"%s\" --port=%d", e.Hostname, e.Port),
-		fmt.Sprintf("--database=%s --table=%s", e.Schema, tableName),
-		fmt.Sprintf("--alter=\"%s\" --execute", vv),
-	}
-	// if strings.Contains(e.Hostname, "rds.aliyuncs.com") {
-	// 	ghostCMDParts = append(ghostCMDParts, "-aliyun-rds=true")
-	// 	ghostCMDParts = append(ghostCMDParts, fmt.Sprintf("-assume-master-host=\"%s\"", e.Hostname))
-	// }
-	// if strings.Contains(e.Hostname, "rds.aliyuncs.com") {
-	ghostCMDParts = append(ghostCMDParts, "-aliyun-rds=true")
-	ghostCMDParts = append(ghostCMDParts, fmt.Sprintf("-assume-master-host=\"%s:%d\"", e.Hostname, e.Port))
-	// }
-
-	ghostCMD := strings.Join(ghostCMDParts, " ")
-
-	startTime := time.Now()
-	// 打印命令，已掩码password
-	re = regexp.MustCompile(`--password="([^"]*)"`)
-	printGhostCMD := re.ReplaceAllString(ghostCMD, `--password="..."`)
-	logAndPublish(fmt.Sprintf("执行gh-ost命令：%s", printGhostCMD))
-	// 执行ghost命令
-	log, err := e.ExecuteCommand(ghostCMD)
-	executeLog = append(executeLog, log...)
+	engine, reason, err := e.selectOSCEngine(alter)
 	if err != nil {
-		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "执行失败，错误：")
+		return data, base.SQLExecuteError{Err: err}
 	}
-	logAndPublish("gh-ost命令执行成功")
-	endTime := time.Now()
-	executeCostTime := utils.HumanfriendlyTimeUnit(endTime.Sub(startTime))
-
-	// 返回数据
-	data.ExecuteLog = strings.Join(executeLog, "")
-	data.ExecuteCostTime = executeCostTime
-	return
+	msg := fmt.Sprintf("[%s] %s", time.Now().Format("2006-01-02 15:04:05"), reason)
+	base.PublishMessageToChannel(e.OrderID, msg, "")
+	data, err = engine.Execute(e, alter)
+	data.ExecuteLog = msg + "\n" + data.ExecuteLog
+	return data, err
 }
 
 func (e *ExecuteMySQLDDL) Run() (data base.ReturnData, err error) {
@@ -213,7 +177,7 @@ func (e *ExecuteMySQLDDL) Run() (data base.ReturnData, err error) {
 	case "DropDatabase":
 		return data, errors.New("【风险】禁止执行drop database操作")
 	case "AlterTable":
-		return e.ExecuteDDLWithGhost(e.SQL)
+		return e.ExecuteAlterTable()
 	default:
 		return data, fmt.Errorf("当前SQL未匹配到规则，执行失败，SQL类型为：%s", sqlType)
 	}
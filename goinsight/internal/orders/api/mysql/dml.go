@@ -6,10 +6,13 @@
 package mysql
 
 import (
+	"errors"
 	"fmt"
 	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
 	"goInsight/pkg/utils"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -53,38 +56,74 @@ func (e *ExecuteMySQLDML) Run() (data base.ReturnData, err error) {
 	}
 	logAndPublish(fmt.Sprintf("数据库Connection ID：%d", connectionID))
 
+	// 注册任务，支持通过KILL QUERY终止执行
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancelled.Store(true)
+		return DaoMySQLKillQuery(e.DBConfig, connectionID)
+	})
+	defer base.UnregisterRunningTask(e.TaskID)
+
+	// 执行前检查实例健康状态
+	g := guard.New(e.DBConfig, NewMySQLCnx)
+	g.ConnectionID = connectionID
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
 	// SHOW PROCESS
 	ch1 := make(chan int64)
 	go DaoMySQLGetProcesslist(e.DBConfig, e.OrderID, connectionID, ch1)
 
 	// 获取执行开始前的binlog position
-	startFile, startPosition, err := DaoMySQLGetBinlogPos(db)
+	startFile, startPosition, startGTIDSet, err := DaoMySQLGetBinlogPos(db)
 	if err != nil {
 		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "获取Start Binlog File和Position失败，错误：")
 	}
 	logAndPublish(fmt.Sprintf("Start Binlog File：%s，Position：%d", startFile, startPosition))
 
+	// 语句开始执行前终止时KILL没有可终止的语句，需要在这里停止
+	if cancelled.Load() {
+		close(ch1)
+		return logErrorAndReturn(base.SQLExecuteError{Err: errors.New("任务已被终止")}, "SQL执行失败，错误：")
+	}
+
 	// 执行SQL
 	startTime := time.Now()
-	affectedRows, err := DaoMySQLExecute(db, e.SQL, ch1)
-	if err != nil {
+	var affectedRows int64
+	if e.BatchExecute {
+		logAndPublish("开始分批执行SQL")
+		affectedRows, err = e.executeInBatches(db, g, ch1, &cancelled, logAndPublish)
+	} else {
+		stopWatch := g.WatchStatement("")
+		affectedRows, err = DaoMySQLExecute(db, e.SQL, ch1)
+		stopWatch()
+	}
+	// 分批执行中断时，已执行的批次仍需要生成回滚SQL
+	if err != nil && affectedRows == 0 {
 		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "SQL执行失败，错误：")
 	}
+	executeErr := err
 	endTime := time.Now()
 	executeCostTime := utils.HumanfriendlyTimeUnit(endTime.Sub(startTime))
-	logAndPublish(fmt.Sprintf("SQL执行成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
+	if executeErr != nil {
+		logAndPublish(fmt.Sprintf("分批执行中断，已执行批次影响行数%d，执行耗时：%s，错误：%s", affectedRows, executeCostTime, executeErr.Error()))
+	} else {
+		logAndPublish(fmt.Sprintf("SQL执行成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
+	}
 
 	data.AffectedRows = affectedRows
 	data.ExecuteCostTime = executeCostTime
 
 	// 获取执行后的binlog position
-	endFile, endPosition, err := DaoMySQLGetBinlogPos(db)
+	endFile, endPosition, endGTIDSet, err := DaoMySQLGetBinlogPos(db)
 	if err != nil {
 		return logErrorAndReturn(base.RollbackSQLError{Err: err}, "获取End Binlog File和Position失败，错误：")
 	}
 	logAndPublish(fmt.Sprintf("End Binlog File：%s，Position：%d", endFile, endPosition))
 
-	var rollbackSQL, backupCostTime string
+	var rollbackSQL, rollbackFile, backupCostTime string
 	// 影响行数大于0，才执行生成回滚SQL操作
 	if affectedRows > 0 {
 		// 生成回滚SQL
@@ -96,8 +135,10 @@ func (e *ExecuteMySQLDML) Run() (data base.ReturnData, err error) {
 			StartFile:     startFile,
 			StartPosition: startPosition,
 			EndFile:       endFile,
-			EndPosition:   endPosition}
-		rollbackSQL, err = binlog.Run()
+			EndPosition:   endPosition,
+			StartGTIDSet:  startGTIDSet,
+			EndGTIDSet:    endGTIDSet}
+		rollbackSQL, rollbackFile, err = binlog.Run()
 		if err != nil {
 			return logErrorAndReturn(base.RollbackSQLError{Err: err}, "生成回滚SQL失败，错误：")
 		}
@@ -109,6 +150,10 @@ func (e *ExecuteMySQLDML) Run() (data base.ReturnData, err error) {
 	// 返回数据
 	data.ExecuteLog = strings.Join(executeLog, "\n")
 	data.RollbackSQL = rollbackSQL
+	data.RollbackSQLFile = rollbackFile
 	data.BackupCostTime = backupCostTime
+	if executeErr != nil {
+		return data, base.SQLExecuteError{Err: executeErr}
+	}
 	return
 }
@@ -0,0 +1,229 @@
+/*
+@Desc    :   试运行：DML在总是回滚的事务中执行，DDL在临时库的空表上执行
+*/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goInsight/internal/orders/api/base"
+	"goInsight/pkg/parser"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/model"
+)
+
+// 试运行的最长时间
+const dryRunTimeout = 10 * time.Minute
+
+// 将DDL引用的表改写到临时库，返回改写后的语句和引用的表名
+// 只允许引用工单库的表，不支持库级别的DDL
+func sandboxDDL(sqltext, schema, sandbox string) (string, []string, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return "", nil, err
+	}
+	switch stmt.(type) {
+	case *ast.CreateDatabaseStmt, *ast.DropDatabaseStmt, *ast.AlterDatabaseStmt:
+		return "", nil, errors.New("试运行不支持库级别的DDL")
+	}
+	c := &parser.TableNameCollector{}
+	stmt.Accept(c)
+	var tables []string
+	seen := make(map[string]bool)
+	for _, t := range c.Tables {
+		if t.Schema.O != "" && !strings.EqualFold(t.Schema.O, schema) {
+			return "", nil, fmt.Errorf("试运行不支持其他库的表`%s`.`%s`", t.Schema.O, t.Name.O)
+		}
+		if !seen[t.Name.L] {
+			seen[t.Name.L] = true
+			tables = append(tables, t.Name.O)
+		}
+		t.Schema = model.NewCIStr(sandbox)
+	}
+	rewritten, err := restoreNode(stmt)
+	if err != nil {
+		return "", nil, err
+	}
+	return rewritten, tables, nil
+}
+
+// 试运行工单的语句，不修改工单库的数据和表结构
+type ExecuteMySQLDryRun struct {
+	*base.DBConfig
+	Statements []string
+}
+
+func (e *ExecuteMySQLDryRun) Run() (results []base.DryRunResult, executeLog string, err error) {
+	var logs []string
+	logAndPublish := func(msg string) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		logs = append(logs, fmt.Sprintf("[%s] %s", timestamp, msg))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dryRunTimeout)
+	defer cancel()
+
+	db, err := NewMySQLCnx(e.DBConfig)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("访问数据库(%s:%d)失败，错误：%s", e.Hostname, e.Port, err.Error()))
+		return nil, strings.Join(logs, "\n"), err
+	}
+	defer db.Close()
+	// 使用同一个连接，保证会话变量和事务生效
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("访问数据库(%s:%d)失败，错误：%s", e.Hostname, e.Port, err.Error()))
+		return nil, strings.Join(logs, "\n"), err
+	}
+	defer conn.Close()
+	logAndPublish(fmt.Sprintf("访问数据库(%s:%d)成功", e.Hostname, e.Port))
+
+	switch e.SQLType {
+	case "DML":
+		results, err = e.dml(ctx, conn, logAndPublish)
+	case "DDL":
+		results, err = e.ddl(ctx, conn, logAndPublish)
+	default:
+		err = fmt.Errorf("试运行不支持%s工单", e.SQLType)
+	}
+	if err != nil {
+		logAndPublish(fmt.Sprintf("试运行失败，错误：%s", err.Error()))
+	}
+	return results, strings.Join(logs, "\n"), err
+}
+
+// 检查DML引用的表是否支持事务，非事务引擎的修改无法回滚
+func (e *ExecuteMySQLDryRun) checkEngines(ctx context.Context, conn *sql.Conn, sqltext string) error {
+	if strings.EqualFold(e.DBType, "TiDB") {
+		return nil
+	}
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return err
+	}
+	c := &parser.TableNameCollector{}
+	stmt.Accept(c)
+	for _, t := range c.Tables {
+		schema := t.Schema.O
+		if schema == "" {
+			schema = e.Schema
+		}
+		var engine sql.NullString
+		err := conn.QueryRowContext(ctx,
+			"SELECT ENGINE FROM information_schema.TABLES WHERE TABLE_SCHEMA=? AND TABLE_NAME=?", schema, t.Name.O).
+			Scan(&engine)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if engine.Valid && !strings.EqualFold(engine.String, "InnoDB") {
+			return fmt.Errorf("表`%s`.`%s`的存储引擎为%s，不支持事务，无法试运行", schema, t.Name.O, engine.String)
+		}
+	}
+	return nil
+}
+
+// DML在同一个事务中依次执行，结束后总是回滚
+func (e *ExecuteMySQLDryRun) dml(ctx context.Context, conn *sql.Conn, logAndPublish func(string)) ([]base.DryRunResult, error) {
+	// 避免长时间等待线上的行锁
+	if _, err := conn.ExecContext(ctx, "SET SESSION innodb_lock_wait_timeout=5"); err != nil {
+		return nil, err
+	}
+	for _, stmt := range e.Statements {
+		if err := e.checkEngines(ctx, conn, stmt); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			logAndPublish(fmt.Sprintf("回滚事务失败，错误：%s", err.Error()))
+			return
+		}
+		logAndPublish("事务已回滚，试运行没有修改数据")
+	}()
+	logAndPublish(fmt.Sprintf("开启事务，共%d条语句", len(e.Statements)))
+
+	results := make([]base.DryRunResult, len(e.Statements))
+	for i, stmt := range e.Statements {
+		results[i].SQL = stmt
+		result, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			results[i].Error = err.Error()
+			logAndPublish(fmt.Sprintf("第%d条语句执行失败，错误：%s", i+1, err.Error()))
+			continue
+		}
+		rows, _ := result.RowsAffected()
+		results[i].AffectedRows = rows
+		logAndPublish(fmt.Sprintf("第%d条语句执行成功，影响行数%d", i+1, rows))
+	}
+	return results, nil
+}
+
+// DDL在临时库中执行，引用的表在首次使用时按线上表结构创建空表
+func (e *ExecuteMySQLDryRun) ddl(ctx context.Context, conn *sql.Conn, logAndPublish func(string)) ([]base.DryRunResult, error) {
+	sandbox := "goinsight_dryrun_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE `%s`", sandbox)); err != nil {
+		return nil, fmt.Errorf("创建临时库`%s`失败：%s", sandbox, err.Error())
+	}
+	logAndPublish(fmt.Sprintf("创建临时库`%s`", sandbox))
+	defer func() {
+		// 执行超时后仍然需要删除临时库
+		if _, err := conn.ExecContext(context.Background(), fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", sandbox)); err != nil {
+			logAndPublish(fmt.Sprintf("删除临时库`%s`失败，错误：%s", sandbox, err.Error()))
+			return
+		}
+		logAndPublish(fmt.Sprintf("删除临时库`%s`", sandbox))
+	}()
+
+	cloned := make(map[string]bool)
+	results := make([]base.DryRunResult, len(e.Statements))
+	for i, stmt := range e.Statements {
+		results[i].SQL = stmt
+		rewritten, tables, err := sandboxDDL(stmt, e.Schema, sandbox)
+		if err != nil {
+			results[i].Error = err.Error()
+			logAndPublish(fmt.Sprintf("第%d条语句无法试运行，错误：%s", i+1, err.Error()))
+			continue
+		}
+		// 复制线上表的结构，表不存在时由语句自身决定是否报错
+		for _, table := range tables {
+			if cloned[strings.ToLower(table)] {
+				continue
+			}
+			cloned[strings.ToLower(table)] = true
+			var count int
+			if err := conn.QueryRowContext(ctx,
+				"SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND TABLE_TYPE='BASE TABLE'", e.Schema, table).
+				Scan(&count); err != nil {
+				return results, err
+			}
+			if count == 0 {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE `%s`.`%s` LIKE `%s`.`%s`", sandbox, table, e.Schema, table)); err != nil {
+				return results, fmt.Errorf("复制表`%s`的结构失败：%s", table, err.Error())
+			}
+			logAndPublish(fmt.Sprintf("复制表`%s`的结构到临时库", table))
+		}
+		if _, err := conn.ExecContext(ctx, rewritten); err != nil {
+			results[i].Error = err.Error()
+			logAndPublish(fmt.Sprintf("第%d条语句执行失败，错误：%s", i+1, err.Error()))
+			continue
+		}
+		logAndPublish(fmt.Sprintf("第%d条语句执行成功", i+1))
+	}
+	return results, nil
+}
@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxDDL(t *testing.T) {
+	tests := []struct {
+		sql    string
+		want   string
+		tables []string
+	}{
+		{
+			sql:    "alter table t1 add column c2 int",
+			want:   "ALTER TABLE `sb`.`t1` ADD COLUMN `c2` INT",
+			tables: []string{"t1"},
+		},
+		{
+			sql:    "create table d1.t2 like t1",
+			want:   "CREATE TABLE `sb`.`t2` LIKE `sb`.`t1`",
+			tables: []string{"t2", "t1"},
+		},
+		{
+			sql:    "rename table t1 to t1_old, T2 to t1",
+			want:   "RENAME TABLE `sb`.`t1` TO `sb`.`t1_old`, `sb`.`T2` TO `sb`.`t1`",
+			tables: []string{"t1", "t1_old", "T2"},
+		},
+	}
+	for _, tt := range tests {
+		got, tables, err := sandboxDDL(tt.sql, "d1", "sb")
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+		assert.Equal(t, tt.tables, tables, tt.sql)
+	}
+
+	_, _, err := sandboxDDL("alter table d2.t1 add column c2 int", "d1", "sb")
+	assert.Error(t, err)
+	_, _, err = sandboxDDL("drop database d1", "d1", "sb")
+	assert.Error(t, err)
+}
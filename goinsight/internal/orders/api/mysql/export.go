@@ -6,6 +6,7 @@ import (
 	"goInsight/global"
 	"goInsight/internal/orders/api/base"
 	"goInsight/internal/orders/api/file"
+	"goInsight/internal/orders/api/masking"
 	"goInsight/pkg/utils"
 	"os"
 	"strings"
@@ -20,7 +21,7 @@ type ExecuteMySQLExportToFile struct {
 
 var filePath string = "./media"
 
-func (e *ExecuteMySQLExportToFile) processRowsAndExport(rows *sql.Rows, columns []string) (int64, string, string, error) {
+func (e *ExecuteMySQLExportToFile) processRowsAndExport(rows *sql.Rows, columns []string, masker *masking.Masker) (int64, []string, error) {
 	g := new(errgroup.Group)
 
 	vals := make([]interface{}, len(columns))
@@ -29,51 +30,41 @@ func (e *ExecuteMySQLExportToFile) processRowsAndExport(rows *sql.Rows, columns
 	}
 	ch := make(chan []interface{}, 10)
 
-	// Determine file format and start export
-	var fileName, fullFileName string
-	switch e.ExportFileFormat {
-	case "CSV":
-		fileName = fmt.Sprintf("%s.csv", e.TaskID)
-		fullFileName = fmt.Sprintf("%s/%s", filePath, fileName)
-		g.Go(func() error {
-			return file.ToCSV(columns, ch, fullFileName)
-		})
-	case "XLSX":
-		fileName = fmt.Sprintf("%s.xlsx", e.TaskID)
-		fullFileName = fmt.Sprintf("%s/%s", filePath, fileName)
-		g.Go(func() error {
-			return file.ToExcel(columns, ch, fullFileName)
-		})
-	}
+	// Start export, rows are streamed to the file writer through the channel
+	var files []string
+	g.Go(func() (err error) {
+		files, err = file.Export(columns, ch, file.NewOptions(e.DBConfig, filePath))
+		return err
+	})
 
 	// Read and process rows
-	rowCount, err := e.readAndProcessRows(rows, vals, ch)
-	if err != nil {
-		return 0, "", "", err
-	}
+	rowCount, err := e.readAndProcessRows(rows, vals, ch, masker)
 
 	// Close channel and wait for export to finish
 	close(ch)
-
-	// Wait to complete
-	if err := g.Wait(); err != nil {
-		return 0, "", "", err
+	if waitErr := g.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return 0, nil, err
 	}
 
-	return rowCount, fileName, fullFileName, nil
+	return rowCount, files, nil
 }
 
-func (e *ExecuteMySQLExportToFile) readAndProcessRows(rows *sql.Rows, vals []interface{}, ch chan []interface{}) (int64, error) {
+func (e *ExecuteMySQLExportToFile) readAndProcessRows(rows *sql.Rows, vals []interface{}, ch chan []interface{}, masker *masking.Masker) (int64, error) {
 	var rowCount int64
 	for rows.Next() {
 		if err := rows.Scan(vals...); err != nil {
 			return 0, err
 		}
 		vmap := e.processRowData(vals)
+		// 按脱敏规则处理敏感列
+		masker.Mask(vmap)
 		ch <- vmap
 		rowCount++
 	}
-	return rowCount, nil
+	return rowCount, rows.Err()
 }
 
 func (e *ExecuteMySQLExportToFile) processRowData(vals []interface{}) []interface{} {
@@ -134,26 +125,37 @@ func (e *ExecuteMySQLExportToFile) Run() (data base.ReturnData, err error) {
 	}
 	logAndPublish("检索列名")
 
+	// Match the masking rules against the columns
+	masker, err := masking.NewMasker(e.MaskingRules, e.MaskingSecret, e.SQL, e.Schema, columns)
+	if err != nil {
+		return logErrorAndReturn(err, "Failed to match masking rules")
+	}
+	if len(masker.Applied) > 0 {
+		logAndPublish(fmt.Sprintf("数据脱敏：%s", strings.Join(masker.Applied, "; ")))
+	}
+
 	// Process rows and export to file
-	rowCount, fileName, fullFileName, err := e.processRowsAndExport(rows, columns)
+	rowCount, files, err := e.processRowsAndExport(rows, columns, masker)
 	if err != nil {
 		return logErrorAndReturn(err, "Failed to process rows and export")
 	}
-	logAndPublish("处理行数据")
+	logAndPublish(fmt.Sprintf("处理行数据，生成文件%s", strings.Join(files, ",")))
 
-	// 加密和压缩文件
-	encryptFileName := fileName + ".zip"
+	// 加密和压缩文件，XLSX超过行数限制时会拆分为多个文件，压缩到同一个zip文件中
+	encryptFileName := files[0] + ".zip"
 	encryptFilePath := fmt.Sprintf("%s/%s", filePath, encryptFileName)
 	key := utils.GenerateRandomString(24)
-	_ = utils.EncryptAndTarGzFiles(fileName, encryptFileName, filePath, key)
-	logAndPublish(fmt.Sprintf("加密和压缩文件%s -> %s", fullFileName, encryptFileName))
+	_ = utils.EncryptAndZipFiles(files, encryptFileName, filePath, key)
+	logAndPublish(fmt.Sprintf("加密和压缩文件%s -> %s", strings.Join(files, ","), encryptFileName))
 
 	// 删除原文件
-	err = os.Remove(fullFileName)
-	if err != nil {
-		return logErrorAndReturn(err, "Error deleting original file")
+	for _, fileName := range files {
+		fullFileName := fmt.Sprintf("%s/%s", filePath, fileName)
+		if err := os.Remove(fullFileName); err != nil {
+			return logErrorAndReturn(err, "Error deleting original file")
+		}
+		logAndPublish(fmt.Sprintf("源文件%s删除成功", fullFileName))
 	}
-	logAndPublish(fmt.Sprintf("源文件%s删除成功", fullFileName))
 
 	// 结束时间
 	endTime := time.Now()
@@ -167,10 +169,11 @@ func (e *ExecuteMySQLExportToFile) Run() (data base.ReturnData, err error) {
 		EncryptionKey: string(key),
 		FileName:      encryptFileName,
 		FilePath:      encryptFilePath,
-		ContentType:   "xlsx",
+		ContentType:   file.Extension(e.ExportFileFormat),
 		FileSize:      FileSize,
 		ExportRows:    rowCount,
 		DownloadUrl:   fmt.Sprintf("%s/orders/download/exportfile/%s", global.App.Config.Notify.NoticeURL, encryptFileName),
+		MaskedColumns: masker.Applied,
 	}
 	data.ExecuteLog = strings.Join(executeLog, "\n")
 	data.ExecuteCostTime = executeCostTime
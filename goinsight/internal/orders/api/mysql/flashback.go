@@ -0,0 +1,279 @@
+/*
+@Desc    :   解析指定范围内的binlog，生成闪回SQL
+*/
+
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goInsight/internal/orders/api/base"
+	"goInsight/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+// 闪回SQL文件存储目录
+const flashbackSQLDir = "./media/flashback"
+
+// Flashback 解析指定时间范围或binlog范围内的行事件，按照相反的顺序生成闪回SQL
+// 表结构使用当前的表结构，范围内发生过DDL的表无法正确生成闪回SQL
+type Flashback struct {
+	*base.DBConfig
+	FlashbackID   string
+	StartTime     *time.Time // 开始时间，为空时不限制
+	EndTime       *time.Time // 结束时间，为空时不限制
+	StartFile     string     // 开始的binlog文件，为空时从第一个binlog文件开始解析
+	StartPosition int64
+	EndFile       string // 结束的binlog文件，为空时解析到当前的binlog位置
+	EndPosition   int64
+	Schemas       []string // 过滤的库名，为空时不过滤
+	Tables        []string // 过滤的表名，为空时不过滤
+	Operations    []string // 过滤的操作类型(INSERT/UPDATE/DELETE)，为空时不过滤
+	MaxStatements int64    // 最多生成的闪回SQL条数，为0时不限制
+}
+
+// 闪回SQL在临时文件中的位置
+type flashbackSegment struct {
+	offset int64
+	length int
+}
+
+func (f *Flashback) match(schema, table, operation string) bool {
+	if len(f.Schemas) > 0 && !containsFold(f.Schemas, schema) {
+		return false
+	}
+	if len(f.Tables) > 0 && !containsFold(f.Tables, table) {
+		return false
+	}
+	if len(f.Operations) > 0 && !containsFold(f.Operations, operation) {
+		return false
+	}
+	return true
+}
+
+func containsFold(items []string, s string) bool {
+	for _, item := range items {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// 确定解析的binlog范围
+func (f *Flashback) positions() (start, stop mysql.Position, err error) {
+	db, err := NewMySQLCnx(f.DBConfig)
+	if err != nil {
+		return start, stop, err
+	}
+	defer db.Close()
+	start = mysql.Position{Name: f.StartFile, Pos: uint32(f.StartPosition)}
+	if start.Name == "" {
+		logs, err := DaoMySQLGetBinaryLogs(db)
+		if err != nil {
+			return start, stop, err
+		}
+		start = mysql.Position{Name: logs[0], Pos: 4}
+	}
+	if start.Pos < 4 {
+		start.Pos = 4
+	}
+	stop = mysql.Position{Name: f.EndFile, Pos: uint32(f.EndPosition)}
+	if stop.Name == "" {
+		file, position, _, err := DaoMySQLGetBinlogPos(db)
+		if err != nil {
+			return start, stop, err
+		}
+		stop = mysql.Position{Name: file, Pos: uint32(position)}
+	}
+	if start.Compare(stop) > -1 {
+		return start, stop, errors.New("开始位置必须小于结束位置")
+	}
+	return start, stop, nil
+}
+
+// Run 解析binlog生成闪回SQL，返回闪回SQL文件和SQL条数
+func (f *Flashback) Run() (flashbackFile string, statements int64, err error) {
+	b := &Binlog{DBConfig: f.DBConfig}
+	defer func() {
+		if b.db != nil {
+			b.db.Close()
+		}
+	}()
+	startPosition, stopPosition, err := f.positions()
+	if err != nil {
+		return "", 0, err
+	}
+	cfg := replication.BinlogSyncerConfig{
+		ServerID:   20231108 + uint32(uint32(time.Now().Unix())%10000),
+		Flavor:     "mysql",
+		Host:       f.Hostname,
+		Port:       f.Port,
+		User:       f.UserName,
+		Password:   f.Password,
+		UseDecimal: true,
+	}
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(startPosition)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(flashbackSQLDir, 0755); err != nil {
+		return "", 0, err
+	}
+	// 闪回SQL需要倒序执行，先顺序写入临时文件并记录每条SQL的位置，解析完成后倒序写入闪回SQL文件
+	tmp, err := os.CreateTemp(flashbackSQLDir, "flashback-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	w := bufio.NewWriter(tmp)
+	var (
+		segments []flashbackSegment
+		offset   int64
+	)
+
+	currentPosition := startPosition
+	for {
+		e, err := streamer.GetEvent(context.Background())
+		if err != nil {
+			return "", 0, err
+		}
+		if e.Header.LogPos > 0 {
+			currentPosition.Pos = e.Header.LogPos
+		}
+		if e.Header.EventType == replication.ROTATE_EVENT {
+			if event, ok := e.Event.(*replication.RotateEvent); ok {
+				currentPosition = mysql.Position{Name: string(event.NextLogName), Pos: uint32(event.Position)}
+			}
+		}
+		if currentPosition.Compare(stopPosition) > 0 {
+			break
+		}
+		// 时间过滤，binlog中的事件按照时间先后写入
+		eventTime := time.Unix(int64(e.Header.Timestamp), 0)
+		if f.EndTime != nil && e.Header.Timestamp > 0 && eventTime.After(*f.EndTime) {
+			break
+		}
+		done := currentPosition.Compare(stopPosition) == 0
+		if f.StartTime != nil && eventTime.Before(*f.StartTime) {
+			if done {
+				break
+			}
+			continue
+		}
+
+		var (
+			operation string
+			rollback  func(*replication.RowsEvent, *ast.CreateTableStmt) (string, error)
+		)
+		switch e.Header.EventType {
+		case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+			operation, rollback = "INSERT", b.generateDeleteSql
+		case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+			operation, rollback = "DELETE", b.generateInsertSql
+		case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+			operation, rollback = "UPDATE", b.generateUpdateSql
+		}
+		if event, ok := e.Event.(*replication.RowsEvent); ok && rollback != nil {
+			schema, table := string(event.Table.Schema), string(event.Table.Table)
+			if f.match(schema, table, operation) {
+				stmt, err := b.parserTableStmt(fmt.Sprintf("`%s`.`%s`", schema, table))
+				if err != nil {
+					return "", 0, err
+				}
+				if int(event.ColumnCount) != len(stmt.Cols) {
+					return "", 0, fmt.Errorf("表`%s`.`%s`的列数和binlog中的列数不一致，范围内可能发生过DDL", schema, table)
+				}
+				rbsql, err := rollback(event, stmt)
+				if err != nil {
+					return "", 0, err
+				}
+				for _, s := range strings.Split(rbsql, rollbackSQLSeparator) {
+					if f.MaxStatements > 0 && int64(len(segments)) >= f.MaxStatements {
+						return "", 0, fmt.Errorf("闪回SQL超过%d条，请缩小解析范围或增加过滤条件", f.MaxStatements)
+					}
+					n, err := w.WriteString(s)
+					if err != nil {
+						return "", 0, err
+					}
+					segments = append(segments, flashbackSegment{offset: offset, length: n})
+					offset += int64(n)
+				}
+			}
+		}
+		if done {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", 0, err
+	}
+	flashbackFile = filepath.Join(flashbackSQLDir, fmt.Sprintf("%s.sql", f.FlashbackID))
+	if err := f.reverse(tmp, segments, flashbackFile); err != nil {
+		return "", 0, err
+	}
+	return flashbackFile, int64(len(segments)), nil
+}
+
+// 将临时文件中的SQL倒序写入闪回SQL文件
+func (f *Flashback) reverse(tmp io.ReaderAt, segments []flashbackSegment, flashbackFile string) error {
+	out, err := os.Create(flashbackFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	var buf []byte
+	for i := len(segments) - 1; i >= 0; i-- {
+		s := segments[i]
+		if cap(buf) < s.length {
+			buf = make([]byte, s.length)
+		}
+		buf = buf[:s.length]
+		if _, err := tmp.ReadAt(buf, s.offset); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		if _, err := w.WriteString(";\n"); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// 获取实例上的binlog文件列表
+func DaoMySQLGetBinaryLogs(db *sql.DB) ([]string, error) {
+	data, err := DaoMySQLQuery(db, "SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	var logs []string
+	for _, row := range *data {
+		if name, ok := row["Log_name"].(string); ok && !utils.IsContain(logs, name) {
+			logs = append(logs, name)
+		}
+	}
+	if len(logs) == 0 {
+		return nil, errors.New("未获取到binlog文件，请检查MySQL是否开启了binlog")
+	}
+	return logs, nil
+}
@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlashbackMatch(t *testing.T) {
+	f := &Flashback{Schemas: []string{"d1"}, Tables: []string{"T1"}, Operations: []string{"DELETE"}}
+	assert.True(t, f.match("d1", "t1", "DELETE"))
+	assert.False(t, f.match("d2", "t1", "DELETE"))
+	assert.False(t, f.match("d1", "t2", "DELETE"))
+	assert.False(t, f.match("d1", "t1", "UPDATE"))
+	assert.True(t, (&Flashback{}).match("d1", "t1", "INSERT"))
+}
+
+func TestFlashbackReverse(t *testing.T) {
+	sqls := []string{"INSERT INTO `d1`.`t1`(`id`) VALUES(1)", "DELETE FROM `d1`.`t1` WHERE `id`=2", "UPDATE `d1`.`t1` SET `c1`='a;b' WHERE `id`=3"}
+	var segments []flashbackSegment
+	var offset int64
+	for _, s := range sqls {
+		segments = append(segments, flashbackSegment{offset: offset, length: len(s)})
+		offset += int64(len(s))
+	}
+	out := filepath.Join(t.TempDir(), "flashback.sql")
+	err := (&Flashback{}).reverse(strings.NewReader(strings.Join(sqls, "")), segments, out)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, sqls[2]+";\n"+sqls[1]+";\n"+sqls[0]+";\n", string(data))
+}
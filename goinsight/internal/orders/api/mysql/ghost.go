@@ -0,0 +1,86 @@
+/*
+@Desc    :   解析gh-ost的进度输出，提供gh-ost交互命令
+*/
+
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gh-ost支持的交互命令
+const (
+	GhostThrottle   = "throttle"
+	GhostNoThrottle = "no-throttle"
+	GhostChunkSize  = "chunk-size"
+	GhostUnpostpone = "unpostpone"
+	GhostPanic      = "panic"
+)
+
+// GhostProgress gh-ost的执行进度
+type GhostProgress struct {
+	CopiedRows   int64   `json:"copied_rows"`
+	TotalRows    int64   `json:"total_rows"`
+	Percent      float64 `json:"percent"`
+	AppliedRows  int64   `json:"applied_rows"`
+	Backlog      string  `json:"backlog"`
+	Elapsed      string  `json:"elapsed"`
+	Streamer     string  `json:"streamer"`
+	Lag          float64 `json:"lag"`
+	HeartbeatLag float64 `json:"heartbeat_lag"`
+	State        string  `json:"state"`
+	ETA          string  `json:"eta"`
+}
+
+// Copy: 3200/10000 32.0%; Applied: 0; Backlog: 0/1000; Time: 5s(total), 4s(copy); streamer: mysql-bin.000003:12345; Lag: 0.01s, HeartbeatLag: 0.05s, State: migrating; ETA: 8s
+// 旧版本的gh-ost没有HeartbeatLag
+var ghostStatusRegexp = regexp.MustCompile(
+	`Copy: (\d+)/(\d+) ([\d.]+)%; Applied: (\d+); Backlog: (\d+/\d+); Time: ([^(;]+)\(total\)[^;]*; ` +
+		`streamer: ([^;]*); Lag: ([\d.]+)s,(?: HeartbeatLag: ([\d.]+)s,)? State: ([^;]+); ETA: (.+)$`,
+)
+
+// ParseGhostProgress 解析gh-ost输出的状态行，不是状态行时返回false
+func ParseGhostProgress(line string) (*GhostProgress, bool) {
+	match := ghostStatusRegexp.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return nil, false
+	}
+	p := &GhostProgress{
+		Backlog:  match[5],
+		Elapsed:  match[6],
+		Streamer: match[7],
+		State:    match[10],
+		ETA:      match[11],
+	}
+	p.CopiedRows, _ = strconv.ParseInt(match[1], 10, 64)
+	p.TotalRows, _ = strconv.ParseInt(match[2], 10, 64)
+	p.Percent, _ = strconv.ParseFloat(match[3], 64)
+	p.AppliedRows, _ = strconv.ParseInt(match[4], 10, 64)
+	p.Lag, _ = strconv.ParseFloat(match[8], 64)
+	if match[9] != "" {
+		p.HeartbeatLag, _ = strconv.ParseFloat(match[9], 64)
+	}
+	return p, true
+}
+
+// GhostCommand 生成发送给gh-ost socket的命令，chunk-size需要指定value
+func GhostCommand(action string, value int) (string, error) {
+	switch action {
+	case GhostThrottle, GhostNoThrottle, GhostUnpostpone, GhostPanic:
+		return action, nil
+	case GhostChunkSize:
+		if value < 10 || value > 100000 {
+			return "", fmt.Errorf("chunk-size的取值范围为[10, 100000]")
+		}
+		return fmt.Sprintf("%s=%d", GhostChunkSize, value), nil
+	}
+	return "", fmt.Errorf("不支持的gh-ost命令：%s", action)
+}
+
+// gh-ost推迟cut-over的标记文件，文件存在时gh-ost不会执行cut-over，unpostpone命令会删除该文件
+func ghostPostponeFlagFile(taskID string) string {
+	return fmt.Sprintf("/tmp/gh-ost.%s.postpone.flag", taskID)
+}
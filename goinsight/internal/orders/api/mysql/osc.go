@@ -0,0 +1,513 @@
+/*
+@Desc    :   ALTER TABLE的Online Schema Change引擎
+*/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
+	"goInsight/pkg/parser"
+	"goInsight/pkg/utils"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+const (
+	EngineAuto   = "auto"
+	EngineGhost  = "gh-ost"
+	EnginePtOSC  = "pt-osc"
+	EngineNative = "native"
+)
+
+const (
+	AlgorithmInstant = "INSTANT"
+	AlgorithmInplace = "INPLACE"
+	AlgorithmCopy    = "COPY" // 需要拷贝数据，由MySQL决定算法
+)
+
+// OSCEngine 执行ALTER TABLE语句的引擎
+type OSCEngine interface {
+	Name() string
+	Execute(e *ExecuteMySQLDDL, alter *AlterTable) (base.ReturnData, error)
+}
+
+// AlterTable 解析后的ALTER TABLE语句
+type AlterTable struct {
+	stmt   *ast.AlterTableStmt
+	Schema string
+	Table  string
+	Specs  string // 逗号分隔的子句，传递给gh-ost/pt-osc的--alter参数
+}
+
+func ParseAlterTable(sqltext, defaultSchema string) (*AlterTable, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, err
+	}
+	s, ok := stmt.(*ast.AlterTableStmt)
+	if !ok {
+		return nil, errors.New("仅支持ALTER TABLE语句")
+	}
+	a := &AlterTable{stmt: s, Schema: s.Table.Schema.O, Table: s.Table.Name.O}
+	if a.Schema == "" {
+		a.Schema = defaultSchema
+	}
+	var specs []string
+	for _, spec := range s.Specs {
+		text, err := restoreNode(spec)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, text)
+	}
+	a.Specs = strings.Join(specs, ", ")
+	return a, nil
+}
+
+// 新增的列是否支持INSTANT，不能指定位置，也不能是主键/唯一键/自增列/存储的计算列
+func instantAddColumns(spec *ast.AlterTableSpec) bool {
+	if spec.Position != nil && spec.Position.Tp != ast.ColumnPositionNone {
+		return false
+	}
+	for _, col := range spec.NewColumns {
+		for _, opt := range col.Options {
+			switch opt.Tp {
+			case ast.ColumnOptionPrimaryKey, ast.ColumnOptionUniqKey, ast.ColumnOptionAutoIncrement:
+				return false
+			case ast.ColumnOptionGenerated:
+				if opt.Stored {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// PredictAlgorithm 按照MySQL 8.0的Online DDL规则保守地预测执行算法
+func (a *AlterTable) PredictAlgorithm() string {
+	algorithm := AlgorithmInstant
+	for _, spec := range a.stmt.Specs {
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			if !instantAddColumns(spec) {
+				return AlgorithmCopy
+			}
+		case ast.AlterTableRenameColumn, ast.AlterTableAlterColumn, ast.AlterTableRenameTable, ast.AlterTableRenameIndex,
+			ast.AlterTableAlgorithm, ast.AlterTableLock:
+		case ast.AlterTableDropIndex:
+			algorithm = AlgorithmInplace
+		case ast.AlterTableAddConstraint:
+			switch spec.Constraint.Tp {
+			case ast.ConstraintIndex, ast.ConstraintKey, ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex, ast.ConstraintFulltext:
+				algorithm = AlgorithmInplace
+			default:
+				return AlgorithmCopy
+			}
+		default:
+			return AlgorithmCopy
+		}
+	}
+	return algorithm
+}
+
+// SQL 返回指定了ALGORITHM的语句，INPLACE同时指定LOCK=NONE，COPY不指定由MySQL决定
+func (a *AlterTable) SQL(algorithm string) (string, error) {
+	var extra []*ast.AlterTableSpec
+	switch algorithm {
+	case AlgorithmInstant:
+		extra = append(extra, &ast.AlterTableSpec{Tp: ast.AlterTableAlgorithm, Algorithm: ast.AlgorithmTypeInstant})
+	case AlgorithmInplace:
+		extra = append(extra,
+			&ast.AlterTableSpec{Tp: ast.AlterTableAlgorithm, Algorithm: ast.AlgorithmTypeInplace},
+			&ast.AlterTableSpec{Tp: ast.AlterTableLock, LockType: ast.LockTypeNone},
+		)
+	}
+	specs := a.stmt.Specs
+	a.stmt.Specs = append(append([]*ast.AlterTableSpec{}, specs...), extra...)
+	defer func() { a.stmt.Specs = specs }()
+	return restoreNode(a.stmt)
+}
+
+// NewOSCEngine 根据名称创建引擎
+func NewOSCEngine(name string) (OSCEngine, error) {
+	switch name {
+	case EngineGhost:
+		cfg := global.App.Config.Ghost
+		return &GhostEngine{Path: cfg.Path, Args: cfg.Args, PostponeCutOver: cfg.PostponeCutOver}, nil
+	case EnginePtOSC:
+		return &PtOSCEngine{Path: global.App.Config.PtOSC.Path, Args: global.App.Config.PtOSC.Args}, nil
+	case EngineNative:
+		return &NativeEngine{}, nil
+	}
+	return nil, fmt.Errorf("不支持的OSC引擎：%s", name)
+}
+
+// 选择执行ALTER TABLE的引擎，实例配置优先于全局配置，返回引擎和选择原因
+func (e *ExecuteMySQLDDL) selectOSCEngine(alter *AlterTable) (OSCEngine, string, error) {
+	name := e.OSCEngine
+	if name == "" {
+		name = global.App.Config.OSC.Engine
+	}
+	if name == "" {
+		name = EngineGhost
+	}
+	if name != EngineAuto {
+		engine, err := NewOSCEngine(name)
+		return engine, fmt.Sprintf("使用配置的OSC引擎：%s", name), err
+	}
+	tool := global.App.Config.OSC.Tool
+	if tool == "" {
+		tool = EngineGhost
+	}
+	if algorithm := alter.PredictAlgorithm(); algorithm == AlgorithmInstant {
+		engine, err := NewOSCEngine(EngineNative)
+		return engine, "预测支持ALGORITHM=INSTANT，使用原生DDL", err
+	}
+	db, err := NewMySQLCnx(e.DBConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	defer db.Close()
+	size, err := DaoMySQLGetTableSize(db, alter.Schema, alter.Table)
+	if err != nil {
+		return nil, "", err
+	}
+	sizeMB := size / 1024 / 1024
+	if sizeMB <= global.App.Config.OSC.NativeMaxTableSize {
+		engine, err := NewOSCEngine(EngineNative)
+		return engine, fmt.Sprintf("表大小%dMB，不超过%dMB，使用原生DDL", sizeMB, global.App.Config.OSC.NativeMaxTableSize), err
+	}
+	engine, err := NewOSCEngine(tool)
+	return engine, fmt.Sprintf("表大小%dMB，超过%dMB，使用%s", sizeMB, global.App.Config.OSC.NativeMaxTableSize, tool), err
+}
+
+// 获取表的数据和索引大小，单位字节
+func DaoMySQLGetTableSize(db *sql.DB, schema, table string) (int64, error) {
+	var size int64
+	err := db.QueryRow(
+		"SELECT IFNULL(DATA_LENGTH+INDEX_LENGTH, 0) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA=? AND TABLE_NAME=?",
+		schema, table,
+	).Scan(&size)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("表`%s`.`%s`不存在", schema, table)
+	}
+	return size, err
+}
+
+// NativeEngine 使用原生ALTER TABLE执行
+type NativeEngine struct{}
+
+func (n *NativeEngine) Name() string {
+	return EngineNative
+}
+
+// 不支持指定的算法时返回的错误
+func isAlgorithmNotSupported(err error) bool {
+	var mysqlErr *mysqlDriver.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	// ER_UNKNOWN_ALTER_ALGORITHM/ER_ALTER_OPERATION_NOT_SUPPORTED/ER_ALTER_OPERATION_NOT_SUPPORTED_REASON
+	switch mysqlErr.Number {
+	case 1800, 1845, 1846:
+		return true
+	}
+	return false
+}
+
+func (n *NativeEngine) Execute(e *ExecuteMySQLDDL, alter *AlterTable) (base.ReturnData, error) {
+	algorithm := alter.PredictAlgorithm()
+	sqltext, err := alter.SQL(algorithm)
+	if err != nil {
+		return base.ReturnData{}, err
+	}
+	dc := *e.DBConfig
+	dc.SQL = sqltext
+	base.PublishMessageToChannel(e.OrderID, fmt.Sprintf("预测执行算法：%s，执行语句：%s", algorithm, sqltext), "")
+	data, err := ExecuteOnlineDDL(&dc)
+	if err == nil || algorithm != AlgorithmInstant || !isAlgorithmNotSupported(err) {
+		return data, err
+	}
+	// 不支持INSTANT时使用INPLACE重试，不会退化为锁表的COPY
+	if dc.SQL, err = alter.SQL(AlgorithmInplace); err != nil {
+		return data, err
+	}
+	base.PublishMessageToChannel(e.OrderID, fmt.Sprintf("不支持ALGORITHM=INSTANT，使用INPLACE重试：%s", dc.SQL), "")
+	retry, err := ExecuteOnlineDDL(&dc)
+	retry.ExecuteLog = data.ExecuteLog + "\n" + retry.ExecuteLog
+	return retry, err
+}
+
+// 外部工具的执行命令
+type oscCommand struct {
+	Path string
+	Args []string
+	// Cancel 终止工具的方法，为空时通过SIGTERM终止进程
+	Cancel func() error
+	// Throttle 暂停/恢复数据拷贝的方法，为空表示不支持暂停
+	Throttle func(throttle bool) error
+	// Control 发送交互命令的方法，为空表示不支持交互
+	Control func(command string) (string, error)
+	// OnOutput 处理工具输出的每一行
+	OnOutput func(line string)
+}
+
+// 生成包含账号密码的临时配置文件，避免密码出现在命令行参数中
+func writeDefaultsFile(user, password string) (string, error) {
+	f, err := os.CreateTemp("", "goinsight-osc-*.cnf")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	_, err = fmt.Fprintf(f, "[client]\nuser=\"%s\"\npassword=\"%s\"\n", quote.Replace(user), quote.Replace(password))
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// GhostEngine 使用gh-ost执行
+type GhostEngine struct {
+	Path            string
+	Args            []string
+	PostponeCutOver bool // 推迟cut-over，直到收到unpostpone命令
+}
+
+func (g *GhostEngine) Name() string {
+	return EngineGhost
+}
+
+// gh-ost交互socket文件，每个任务独立
+func ghostSocketFile(taskID string) string {
+	return fmt.Sprintf("/tmp/gh-ost.%s.sock", taskID)
+}
+
+func (g *GhostEngine) Command(e *ExecuteMySQLDDL, alter *AlterTable, defaultsFile string) oscCommand {
+	args := append([]string{}, g.Args...)
+	args = append(args,
+		"--conf="+defaultsFile,
+		"--host="+e.Hostname,
+		fmt.Sprintf("--port=%d", e.Port),
+		"--database="+alter.Schema,
+		"--table="+alter.Table,
+		"--alter="+alter.Specs,
+		"--serve-socket-file="+ghostSocketFile(e.TaskID),
+		"--execute",
+	)
+	if g.PostponeCutOver {
+		args = append(args, "--postpone-cut-over-flag-file="+ghostPostponeFlagFile(e.TaskID))
+	}
+	// 阿里云RDS无法获取到真实的主库地址
+	if strings.Contains(e.Hostname, "rds.aliyuncs.com") {
+		args = append(args, "--aliyun-rds=true", fmt.Sprintf("--assume-master-host=%s:%d", e.Hostname, e.Port))
+	}
+	socket := ghostSocketFile(e.TaskID)
+	return oscCommand{
+		Path: g.Path,
+		Args: args,
+		Cancel: func() error {
+			_, err := base.SendSocketCommand(socket, GhostPanic)
+			return err
+		},
+		Throttle: func(throttle bool) error {
+			command := GhostNoThrottle
+			if throttle {
+				command = GhostThrottle
+			}
+			_, err := base.SendSocketCommand(socket, command)
+			return err
+		},
+		Control: func(command string) (string, error) {
+			return base.SendSocketCommand(socket, command)
+		},
+		// 解析状态行，更新任务进度并推送到前端
+		OnOutput: func(line string) {
+			if progress, ok := ParseGhostProgress(line); ok {
+				base.SetRunningTaskProgress(e.TaskID, progress)
+				base.PublishMessageToChannel(e.OrderID, progress, "ghost_progress")
+			}
+		},
+	}
+}
+
+func (g *GhostEngine) Execute(e *ExecuteMySQLDDL, alter *AlterTable) (base.ReturnData, error) {
+	if g.PostponeCutOver {
+		flagFile := ghostPostponeFlagFile(e.TaskID)
+		if err := os.WriteFile(flagFile, nil, 0644); err != nil {
+			return base.ReturnData{}, base.SQLExecuteError{Err: err}
+		}
+		defer os.Remove(flagFile)
+		base.PublishMessageToChannel(e.OrderID, "已开启推迟cut-over，数据拷贝完成后需要审核人确认cut-over\n", "ghost")
+	}
+	return e.executeWithTool(g.Name(), alter, g.Command)
+}
+
+// PtOSCEngine 使用pt-online-schema-change执行
+type PtOSCEngine struct {
+	Path string
+	Args []string
+}
+
+func (p *PtOSCEngine) Name() string {
+	return EnginePtOSC
+}
+
+func (p *PtOSCEngine) Command(e *ExecuteMySQLDDL, alter *AlterTable, defaultsFile string) oscCommand {
+	args := append([]string{}, p.Args...)
+	args = append(args,
+		"--alter", alter.Specs,
+		"--execute",
+		fmt.Sprintf("h=%s,P=%d,D=%s,t=%s,F=%s", e.Hostname, e.Port, alter.Schema, alter.Table, defaultsFile),
+	)
+	return oscCommand{Path: p.Path, Args: args}
+}
+
+func (p *PtOSCEngine) Execute(e *ExecuteMySQLDDL, alter *AlterTable) (base.ReturnData, error) {
+	return e.executeWithTool(p.Name(), alter, p.Command)
+}
+
+// 使用外部工具执行ALTER TABLE
+func (e *ExecuteMySQLDDL) executeWithTool(name string, alter *AlterTable, command func(*ExecuteMySQLDDL, *AlterTable, string) oscCommand) (data base.ReturnData, err error) {
+	var executeLog []string
+
+	// Function to log messages and publish
+	logAndPublish := func(msg string) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		formattedMsg := fmt.Sprintf("[%s] %s\n", timestamp, msg)
+		executeLog = append(executeLog, formattedMsg)
+		base.PublishMessageToChannel(e.OrderID, formattedMsg, "ghost")
+	}
+
+	// Logging function for errors
+	logErrorAndReturn := func(err error, errMsg string) (base.ReturnData, error) {
+		logAndPublish(errMsg + err.Error())
+		data.ExecuteLog = strings.Join(executeLog, "")
+		return data, err
+	}
+
+	// 账号密码写入临时配置文件
+	defaultsFile, err := writeDefaultsFile(e.UserName, e.Password)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "生成配置文件失败，错误：")
+	}
+	defer os.Remove(defaultsFile)
+	cmd := command(e, alter, defaultsFile)
+	logAndPublish(fmt.Sprintf("生成%s执行命令", name))
+
+	// 注册任务，等待实例恢复期间可以终止，工具启动后替换为工具的终止方法
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancelled.Store(true)
+		return nil
+	})
+	defer base.UnregisterRunningTask(e.TaskID)
+
+	// 执行前检查实例健康状态
+	g := guard.New(e.DBConfig, NewMySQLCnx)
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
+	// 执行前获取表结构，用于生成回滚SQL
+	rollback, err := base.NewDDLRollback(e.SQL, e.Schema)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("不生成回滚SQL，原因：%s", err.Error()))
+	} else if db, err := NewMySQLCnx(e.DBConfig); err != nil {
+		logAndPublish(fmt.Sprintf("获取表结构失败，不生成回滚SQL，原因：%s", err.Error()))
+		rollback = nil
+	} else {
+		rollback.Capture(db)
+		db.Close()
+	}
+
+	startTime := time.Now()
+	// 命令行参数中不包含密码，可以直接打印
+	logAndPublish(fmt.Sprintf("执行%s命令：%s %s", name, cmd.Path, strings.Join(cmd.Args, " ")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 注册任务，未提供终止方法的工具通过SIGTERM终止
+	cancelTool := cmd.Cancel
+	if cancelTool == nil {
+		cancelTool = func() error {
+			cancel()
+			return nil
+		}
+	}
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancelled.Store(true)
+		return cancelTool()
+	})
+	if cancelled.Load() {
+		return logErrorAndReturn(base.SQLExecuteError{Err: errors.New("任务已被终止")}, "执行失败，错误：")
+	}
+	if cmd.Control != nil {
+		base.SetRunningTaskControl(e.TaskID, cmd.Control)
+	}
+	// 执行期间检查不通过时，pause模式暂停工具的数据拷贝，abort模式或工具不支持暂停时终止执行
+	publish := func(msg string) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		base.PublishMessageToChannel(e.OrderID, fmt.Sprintf("[%s] %s\n", timestamp, msg), "ghost")
+	}
+	stopWatch := g.Watch(func(reason string) {
+		if g.Abort() {
+			publish(fmt.Sprintf("实例健康检查不通过，终止%s：%s", name, reason))
+			if err := base.CancelRunningTask(e.TaskID); err != nil {
+				global.App.Log.Error(err)
+			}
+			return
+		}
+		if cmd.Throttle == nil {
+			publish(fmt.Sprintf("实例健康检查不通过：%s，%s不支持暂停，请关注实例状态", reason, name))
+			return
+		}
+		publish(fmt.Sprintf("实例健康检查不通过，暂停%s数据拷贝：%s", name, reason))
+		if err := cmd.Throttle(true); err != nil {
+			global.App.Log.Error(err)
+		}
+	}, func() {
+		if cmd.Throttle == nil {
+			return
+		}
+		publish(fmt.Sprintf("实例健康检查恢复正常，继续%s数据拷贝", name))
+		if err := cmd.Throttle(false); err != nil {
+			global.App.Log.Error(err)
+		}
+	})
+	log, err := e.ExecuteCommand(ctx, cmd.OnOutput, cmd.Path, cmd.Args...)
+	stopWatch()
+	executeLog = append(executeLog, log...)
+	// 最近一次解析的进度保存到执行结果，任务结束后仍可以查看
+	if progress, ok := base.GetRunningTaskProgress(e.TaskID); ok && progress != nil {
+		data.Progress = progress
+	}
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "执行失败，错误：")
+	}
+	logAndPublish(fmt.Sprintf("%s命令执行成功", name))
+	executeCostTime := utils.HumanfriendlyTimeUnit(time.Since(startTime))
+
+	// 生成回滚SQL
+	if rollback != nil {
+		data.RollbackSQL = rollback.RollbackSQL(logAndPublish)
+	}
+
+	// 返回数据
+	data.ExecuteLog = strings.Join(executeLog, "")
+	data.ExecuteCostTime = executeCostTime
+	return
+}
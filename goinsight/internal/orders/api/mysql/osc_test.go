@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goInsight/internal/orders/api/base"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredictAlgorithm(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"alter table t1 add column c1 int", AlgorithmInstant},
+		{"alter table t1 add column c1 int after id", AlgorithmCopy},
+		{"alter table t1 rename column c1 to c2, alter column c3 set default 1", AlgorithmInstant},
+		{"alter table t1 add index idx_c1(c1)", AlgorithmInplace},
+		{"alter table t1 add column c1 int, drop index idx_c2", AlgorithmInplace},
+		{"alter table t1 modify column c1 bigint", AlgorithmCopy},
+		{"alter table t1 add column c1 int auto_increment primary key", AlgorithmCopy},
+	}
+	for _, tt := range tests {
+		alter, err := ParseAlterTable(tt.sql, "d1")
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, alter.PredictAlgorithm(), tt.sql)
+	}
+}
+
+func TestAlterTableSQL(t *testing.T) {
+	alter, err := ParseAlterTable("alter table d2.t1 add index idx_c1(c1)", "d1")
+	assert.NoError(t, err)
+	assert.Equal(t, "d2", alter.Schema)
+	assert.Equal(t, "t1", alter.Table)
+	assert.Equal(t, "ADD INDEX `idx_c1`(`c1`)", alter.Specs)
+	sql, err := alter.SQL(AlgorithmInplace)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `d2`.`t1` ADD INDEX `idx_c1`(`c1`), ALGORITHM = INPLACE, LOCK = NONE", sql)
+	sql, err = alter.SQL(AlgorithmCopy)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `d2`.`t1` ADD INDEX `idx_c1`(`c1`)", sql)
+}
+
+func TestOSCCommand(t *testing.T) {
+	e := &ExecuteMySQLDDL{DBConfig: &base.DBConfig{Hostname: "127.0.0.1", Port: 3306, UserName: "u1", Password: "p'\"$(id)", TaskID: "t1"}}
+	alter, err := ParseAlterTable("alter table t1 add column c1 varchar(10) comment \"a b\"", "d1")
+	assert.NoError(t, err)
+
+	ghost := (&GhostEngine{Path: "gh-ost", Args: []string{"--chunk-size=800"}}).Command(e, alter, "/tmp/a.cnf")
+	assert.Contains(t, ghost.Args, "--alter=ADD COLUMN `c1` VARCHAR(10) COMMENT 'a b'")
+	assert.Contains(t, ghost.Args, "--conf=/tmp/a.cnf")
+	assert.NotContains(t, strings.Join(ghost.Args, " "), "aliyun")
+	e.Hostname = "rm-xxx.mysql.rds.aliyuncs.com"
+	ghost = (&GhostEngine{Path: "gh-ost"}).Command(e, alter, "/tmp/a.cnf")
+	assert.Contains(t, ghost.Args, "--aliyun-rds=true")
+
+	ptosc := (&PtOSCEngine{Path: "pt-online-schema-change"}).Command(e, alter, "/tmp/a.cnf")
+	assert.Equal(t, []string{"--alter", "ADD COLUMN `c1` VARCHAR(10) COMMENT 'a b'", "--execute",
+		"h=rm-xxx.mysql.rds.aliyuncs.com,P=3306,D=d1,t=t1,F=/tmp/a.cnf"}, ptosc.Args)
+	assert.Nil(t, ptosc.Throttle)
+}
+
+// 使用伪造的工具验证参数原样传递且不包含密码
+func TestOSCCommandWithFakeBinary(t *testing.T) {
+	dir := t.TempDir()
+	fake := filepath.Join(dir, "fake-osc")
+	script := "#!/bin/sh\nfor arg in \"$@\"; do echo \"arg:$arg\"; done\n"
+	assert.NoError(t, os.WriteFile(fake, []byte(script), 0755))
+
+	password := "p'\"$(touch " + filepath.Join(dir, "pwned") + ")"
+	defaultsFile, err := writeDefaultsFile("u1", password)
+	assert.NoError(t, err)
+	defer os.Remove(defaultsFile)
+	content, err := os.ReadFile(defaultsFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `password="p'\"$(touch`)
+
+	e := &ExecuteMySQLDDL{DBConfig: &base.DBConfig{Hostname: "127.0.0.1", Port: 3306, UserName: "u1", Password: password, TaskID: "t1"}}
+	alter, err := ParseAlterTable("alter table t1 add column c1 int comment '$(id)'", "d1")
+	assert.NoError(t, err)
+	cmd := (&PtOSCEngine{Path: fake}).Command(e, alter, defaultsFile)
+
+	ch := make(chan string, 16)
+	assert.NoError(t, base.CommandArgs(context.Background(), ch, cmd.Path, cmd.Args...))
+	close(ch)
+	var output []string
+	for line := range ch {
+		output = append(output, strings.TrimSpace(line))
+	}
+	assert.Contains(t, output, "arg:ADD COLUMN `c1` INT COMMENT '$(id)'")
+	assert.NotContains(t, strings.Join(output, "\n"), password)
+	_, err = os.Stat(filepath.Join(dir, "pwned"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseGhostProgress(t *testing.T) {
+	line := "Copy: 3200/10000 32.0%; Applied: 5; Backlog: 0/1000; Time: 5s(total), 4s(copy); streamer: mysql-bin.000003:12345; Lag: 0.01s, HeartbeatLag: 0.05s, State: migrating; ETA: 8s\n"
+	p, ok := ParseGhostProgress(line)
+	assert.True(t, ok)
+	assert.Equal(t, &GhostProgress{
+		CopiedRows: 3200, TotalRows: 10000, Percent: 32, AppliedRows: 5, Backlog: "0/1000", Elapsed: "5s",
+		Streamer: "mysql-bin.000003:12345", Lag: 0.01, HeartbeatLag: 0.05, State: "migrating", ETA: "8s",
+	}, p)
+
+	p, ok = ParseGhostProgress("Copy: 10/10 100.0%; Applied: 0; Backlog: 0/1000; Time: 1m2s(total), 1m(copy); streamer: mysql-bin.000001:4; Lag: 1.20s, State: postponing cut-over; ETA: due")
+	assert.True(t, ok)
+	assert.Equal(t, "postponing cut-over", p.State)
+	assert.Equal(t, 1.2, p.Lag)
+
+	_, ok = ParseGhostProgress("# Migrating `d1`.`t1`; Ghost table is `d1`.`_t1_gho`")
+	assert.False(t, ok)
+}
+
+func TestGhostCommand(t *testing.T) {
+	command, err := GhostCommand(GhostChunkSize, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, "chunk-size=500", command)
+	_, err = GhostCommand(GhostChunkSize, 0)
+	assert.Error(t, err)
+	command, err = GhostCommand(GhostUnpostpone, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "unpostpone", command)
+	_, err = GhostCommand("sup", 0)
+	assert.Error(t, err)
+}
@@ -1,21 +1,41 @@
 package mysql
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"goInsight/internal/orders/api/base"
 	"goInsight/pkg/parser"
 	"goInsight/pkg/utils"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/pingcap/tidb/pkg/parser/ast"
 
 	"github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 )
 
+const (
+	// 回滚SQL文件存储目录
+	RollbackSQLDir = "./media/rollback"
+	// 回滚SQL文件小于该大小时，同时返回回滚SQL内容
+	maxInlineRollbackSQLSize = 1 << 20
+	// 回滚SQL之间的分隔符
+	rollbackSQLSeparator = ";\r\n"
+)
+
+// 等待binlog事件的超时时间
+var binlogEventTimeout = time.Minute
+
+// Binlog 解析执行SQL期间产生的binlog，生成回滚SQL
+// 设置了StartGTIDSet和EndGTIDSet时基于GTID同步，否则基于file/position同步
 type Binlog struct {
 	*base.DBConfig
 	ConnectionID  int64
@@ -23,17 +43,26 @@ type Binlog struct {
 	StartPosition int64
 	EndFile       string
 	EndPosition   int64
+	StartGTIDSet  string // 执行前的Executed_Gtid_Set
+	EndGTIDSet    string // 执行后的Executed_Gtid_Set
+	db            *sql.DB
+	tables        map[string]*ast.CreateTableStmt // 表结构缓存
 }
 
+// 获取表结构，同一个表只解析一次
 func (b *Binlog) parserTableStmt(table string) (*ast.CreateTableStmt, error) {
-	// 初始化DB
-	db, err := NewMySQLCnx(b.DBConfig)
-	if err != nil {
-		return nil, err
+	if stmt, ok := b.tables[table]; ok {
+		return stmt, nil
+	}
+	if b.db == nil {
+		db, err := NewMySQLCnx(b.DBConfig)
+		if err != nil {
+			return nil, err
+		}
+		b.db = db
 	}
-	defer db.Close()
 	// 查看表结构
-	data, err := DaoMySQLQuery(db, fmt.Sprintf("show create table %s", table))
+	data, err := DaoMySQLQuery(b.db, fmt.Sprintf("show create table %s", table))
 	if err != nil {
 		return nil, err
 	}
@@ -48,11 +77,15 @@ func (b *Binlog) parserTableStmt(table string) (*ast.CreateTableStmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	switch s := stmt.(type) {
-	case *ast.CreateTableStmt:
-		return s, nil
+	s, ok := stmt.(*ast.CreateTableStmt)
+	if !ok {
+		return nil, fmt.Errorf("表%s的表结构不是CREATE TABLE语句", table)
+	}
+	if b.tables == nil {
+		b.tables = make(map[string]*ast.CreateTableStmt)
 	}
-	return nil, nil
+	b.tables[table] = s
+	return s, nil
 }
 
 func (b *Binlog) extractPK(stmt *ast.CreateTableStmt) (bool, []string) {
@@ -78,7 +111,154 @@ func (b *Binlog) extractPK(stmt *ast.CreateTableStmt) (bool, []string) {
 	return len(keys) > 0, keys
 }
 
-func (b *Binlog) Run() (string, error) {
+// 开始同步，优先使用GTID
+func (b *Binlog) startSync(syncer *replication.BinlogSyncer) (*replication.BinlogStreamer, mysql.GTIDSet, mysql.GTIDSet, error) {
+	if b.StartGTIDSet != "" && b.EndGTIDSet != "" {
+		startSet, err := mysql.ParseMysqlGTIDSet(b.StartGTIDSet)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		endSet, err := mysql.ParseMysqlGTIDSet(b.EndGTIDSet)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		streamer, err := syncer.StartSyncGTID(startSet.Clone())
+		return streamer, startSet, endSet, err
+	}
+	streamer, err := syncer.StartSync(mysql.Position{Name: b.StartFile, Pos: uint32(b.StartPosition)})
+	return streamer, nil, nil, err
+}
+
+// 回滚SQL写入的文件
+func (b *Binlog) rollbackFile() string {
+	return filepath.Join(RollbackSQLDir, fmt.Sprintf("%s.sql", b.TaskID))
+}
+
+// 回滚SQL流式写入文件，多条回滚SQL之间以rollbackSQLSeparator分隔
+type rollbackWriter struct {
+	file    string
+	f       *os.File
+	w       *bufio.Writer
+	written int
+}
+
+func newRollbackWriter(file string) (*rollbackWriter, error) {
+	if err := os.MkdirAll(RollbackSQLDir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &rollbackWriter{file: file, f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (r *rollbackWriter) write(sql string) error {
+	if r.written > 0 {
+		if _, err := r.w.WriteString(rollbackSQLSeparator); err != nil {
+			return err
+		}
+	}
+	r.written++
+	_, err := r.w.WriteString(sql)
+	return err
+}
+
+// 写入文件，文件较小时同时返回回滚SQL内容
+func (r *rollbackWriter) finish() (string, string, error) {
+	if err := r.w.Flush(); err != nil {
+		return "", "", err
+	}
+	info, err := os.Stat(r.file)
+	if err != nil {
+		return "", "", err
+	}
+	if info.Size() > maxInlineRollbackSQLSize {
+		return "", r.file, nil
+	}
+	content, err := os.ReadFile(r.file)
+	if err != nil {
+		return "", "", err
+	}
+	return string(content), r.file, nil
+}
+
+func (r *rollbackWriter) close() {
+	r.f.Close()
+}
+
+// Run 解析binlog并将回滚SQL流式写入文件，返回回滚SQL内容和文件路径；
+// 文件超过maxInlineRollbackSQLSize时仅返回文件路径
+func (b *Binlog) Run() (rollbackSQL string, rollbackFile string, err error) {
+	w, err := newRollbackWriter(b.rollbackFile())
+	if err != nil {
+		return "", "", err
+	}
+	defer w.close()
+	if err := b.parse(nil, w.write); err != nil {
+		return "", "", err
+	}
+	return w.finish()
+}
+
+// 保存点在binlog中记录为Query事件，例如：SAVEPOINT `task_1`
+func savepointName(query string) (string, bool) {
+	fields := strings.Fields(query)
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "SAVEPOINT") {
+		return "", false
+	}
+	return strings.Trim(fields[1], "`"), true
+}
+
+// RunSavepoints 解析事务模式执行期间产生的binlog，按执行SQL的连接写入的保存点拆分回滚SQL；
+// savepoints[i]之后的行事件属于taskIDs[i]对应的任务，返回每个任务的回滚SQL内容和文件路径
+func (b *Binlog) RunSavepoints(savepoints []string, taskIDs []string) (rollbackSQLs []string, rollbackFiles []string, err error) {
+	writers := make([]*rollbackWriter, len(taskIDs))
+	for i, taskID := range taskIDs {
+		w, err := newRollbackWriter(filepath.Join(RollbackSQLDir, fmt.Sprintf("%s.sql", taskID)))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer w.close()
+		writers[i] = w
+	}
+	var current int
+	onQuery := func(query string) {
+		name, ok := savepointName(query)
+		if !ok {
+			return
+		}
+		for i, savepoint := range savepoints {
+			if savepoint == name {
+				current = i
+				return
+			}
+		}
+	}
+	write := func(sql string) error {
+		return writers[current].write(sql)
+	}
+	if err := b.parse(onQuery, write); err != nil {
+		return nil, nil, err
+	}
+	rollbackSQLs = make([]string, len(writers))
+	rollbackFiles = make([]string, len(writers))
+	for i, w := range writers {
+		if rollbackSQLs[i], rollbackFiles[i], err = w.finish(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return rollbackSQLs, rollbackFiles, nil
+}
+
+// 解析执行SQL的连接产生的binlog事件，onQuery接收该连接的Query事件，write接收生成的回滚SQL
+func (b *Binlog) parse(onQuery func(query string), write func(sql string) error) error {
+	defer func() {
+		if b.db != nil {
+			b.db.Close()
+			b.db = nil
+		}
+	}()
 	cfg := replication.BinlogSyncerConfig{
 		ServerID:   20231108 + uint32(uint32(time.Now().Unix())%10000),
 		Flavor:     "mysql",
@@ -90,103 +270,128 @@ func (b *Binlog) Run() (string, error) {
 	}
 	syncer := replication.NewBinlogSyncer(cfg)
 	defer syncer.Close()
-	// 定义开始结束的pos
-	startPosition := mysql.Position{Name: b.StartFile, Pos: uint32(b.StartPosition)}
-	stopPosition := mysql.Position{Name: b.EndFile, Pos: uint32(b.EndPosition)}
 	// 开启同步
-	streamer, err := syncer.StartSync(startPosition)
+	streamer, executedSet, endSet, err := b.startSync(syncer)
 	if err != nil {
-		return "", err
+		return err
 	}
+
+	// 执行期间没有产生新的事务
+	if endSet != nil && executedSet.Contain(endSet) {
+		return nil
+	}
+
+	// 定义开始结束的pos
+	startPosition := mysql.Position{Name: b.StartFile, Pos: uint32(b.StartPosition)}
+	stopPosition := mysql.Position{Name: b.EndFile, Pos: uint32(b.EndPosition)}
 	// 声明当前的pos
 	currentPosition := startPosition
 	// 获取当前事件的thread id，用来和执行SQL的thread id进行比较
 	var currentThreadID uint32
-	// 回滚SQL
-	var rbsqls []string
+	// 是否在BEGIN开启的事务中，事务外的Query事件（例如DDL）自动提交
+	var inTransaction bool
 	// 循环
 	for {
-		e, err := streamer.GetEvent(context.Background())
+		// 基于position同步时，处理完结束位置等于停止pos的事件（通常是最后一个事务的XID）后退出
+		var reachedStop bool
+		// 当前事件是否结束了一个事务，基于GTID同步时事务结束后判断是否同步到执行后的GTID
+		var committed bool
+		e, err := getEvent(streamer)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		if e.Header.LogPos > 0 {
-			currentPosition.Pos = e.Header.LogPos
-		}
-
-		if e.Header.EventType == replication.ROTATE_EVENT {
-			if event, ok := e.Event.(*replication.RotateEvent); ok {
-				currentPosition = mysql.Position{Name: string(event.NextLogName),
-					Pos: uint32(event.Position)}
+		if endSet == nil {
+			if e.Header.LogPos > 0 {
+				currentPosition.Pos = e.Header.LogPos
+			}
+			if e.Header.EventType == replication.ROTATE_EVENT {
+				if event, ok := e.Event.(*replication.RotateEvent); ok {
+					currentPosition = mysql.Position{Name: string(event.NextLogName),
+						Pos: uint32(event.Position)}
+				}
+			}
+			if currentPosition.Compare(startPosition) == -1 {
+				continue
+			}
+			// 基于position同步时，事件在停止的pos之后，属于执行结束后的其他事务，退出
+			if currentPosition.Compare(stopPosition) > 0 {
+				return nil
 			}
+			reachedStop = currentPosition.Compare(stopPosition) == 0
 		}
 
-		if currentPosition.Compare(startPosition) == -1 {
-			continue
-		}
-		// 如果当前pos大于停止的pos，退出
-		if currentPosition.Compare(stopPosition) > -1 {
-			break
-		}
 		// 事件类型判断
+		var rollback func(*replication.RowsEvent, *ast.CreateTableStmt) (string, error)
 		switch e.Header.EventType {
-		case replication.QUERY_EVENT:
-			if event, ok := e.Event.(*replication.QueryEvent); ok {
-				currentThreadID = event.SlaveProxyID
-			}
-		case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
-			if event, ok := e.Event.(*replication.RowsEvent); ok {
-				// 获取表的stmt
-				tableName := fmt.Sprintf("`%s`.`%s`", event.Table.Schema, event.Table.Table)
-				stmt, err := b.parserTableStmt(tableName)
+		case replication.GTID_EVENT:
+			if event, ok := e.Event.(*replication.GTIDEvent); ok && executedSet != nil {
+				sid, err := uuid.FromBytes(event.SID)
 				if err != nil {
-					return "", err
+					return err
 				}
-				// 解析回滚语句
-				if b.ConnectionID == int64(currentThreadID) {
-					sql, err := b.generateDeleteSql(event, stmt)
-					if err != nil {
-						return "", err
-					}
-					rbsqls = append(rbsqls, sql)
+				if err := executedSet.Update(fmt.Sprintf("%s:%d", sid, event.GNO)); err != nil {
+					return err
 				}
 			}
-		case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
-			if event, ok := e.Event.(*replication.RowsEvent); ok {
-				// 获取表的stmt
-				tableName := fmt.Sprintf("`%s`.`%s`", event.Table.Schema, event.Table.Table)
-				stmt, err := b.parserTableStmt(tableName)
-				if err != nil {
-					return "", err
+		case replication.QUERY_EVENT:
+			if event, ok := e.Event.(*replication.QueryEvent); ok {
+				currentThreadID = event.SlaveProxyID
+				query := strings.TrimSpace(string(event.Query))
+				switch {
+				case strings.EqualFold(query, "BEGIN"):
+					inTransaction = true
+				case strings.EqualFold(query, "COMMIT"), !inTransaction:
+					inTransaction = false
+					committed = true
 				}
-				if b.ConnectionID == int64(currentThreadID) {
-					sql, err := b.generateInsertSql(event, stmt)
-					if err != nil {
-						return "", err
-					}
-					rbsqls = append(rbsqls, sql)
+				if onQuery != nil && b.ConnectionID == int64(currentThreadID) {
+					onQuery(query)
 				}
 			}
+		case replication.XID_EVENT:
+			// 事务结束，基于GTID同步时同步到执行后的GTID退出，基于position同步时在处理完事件后按pos退出
+			currentThreadID = 0
+			inTransaction = false
+			committed = true
+		case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+			rollback = b.generateDeleteSql
+		case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+			rollback = b.generateInsertSql
 		case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
-			if event, ok := e.Event.(*replication.RowsEvent); ok {
-				// 获取表的stmt
-				tableName := fmt.Sprintf("`%s`.`%s`", event.Table.Schema, event.Table.Table)
-				stmt, err := b.parserTableStmt(tableName)
-				if err != nil {
-					return "", err
-				}
-				if b.ConnectionID == int64(currentThreadID) {
-					sql, err := b.generateUpdateSql(event, stmt)
-					if err != nil {
-						return "", err
-					}
-					rbsqls = append(rbsqls, sql)
-				}
+			rollback = b.generateUpdateSql
+		}
+		// 解析执行SQL的连接产生的行事件
+		if event, ok := e.Event.(*replication.RowsEvent); ok && rollback != nil && b.ConnectionID == int64(currentThreadID) {
+			// 获取表的stmt
+			tableName := fmt.Sprintf("`%s`.`%s`", event.Table.Schema, event.Table.Table)
+			stmt, err := b.parserTableStmt(tableName)
+			if err != nil {
+				return err
+			}
+			sql, err := rollback(event, stmt)
+			if err != nil {
+				return err
+			}
+			if err := write(sql); err != nil {
+				return err
 			}
 		}
+		if reachedStop || (committed && endSet != nil && executedSet.Contain(endSet)) {
+			return nil
+		}
 	}
-	return strings.Join(rbsqls, ";\r\n"), nil
+}
+
+// 获取下一个binlog事件，超时未收到事件时返回错误，避免结束位置之前没有事件时一直阻塞
+func getEvent(streamer *replication.BinlogStreamer) (*replication.BinlogEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), binlogEventTimeout)
+	defer cancel()
+	e, err := streamer.GetEvent(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s内没有收到新的binlog事件，未能同步到结束位置", binlogEventTimeout)
+	}
+	return e, err
 }
 
 func (b *Binlog) generateUpdateSql(e *replication.RowsEvent, stmt *ast.CreateTableStmt) (string, error) {
@@ -0,0 +1,20 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavepointName(t *testing.T) {
+	name, ok := savepointName("SAVEPOINT `task_1`")
+	assert.True(t, ok)
+	assert.Equal(t, "task_1", name)
+	name, ok = savepointName("savepoint task_2")
+	assert.True(t, ok)
+	assert.Equal(t, "task_2", name)
+	_, ok = savepointName("ROLLBACK TO SAVEPOINT `task_1`")
+	assert.False(t, ok)
+	_, ok = savepointName("BEGIN")
+	assert.False(t, ok)
+}
@@ -0,0 +1,188 @@
+/*
+@Desc    :   事务模式执行DML
+*/
+
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
+	"goInsight/pkg/utils"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 在同一个事务中执行工单的所有DML任务
+type ExecuteMySQLDMLInTransaction struct {
+	*base.DBConfig
+	Tasks []base.TransactionTask
+}
+
+// 每个任务执行前创建保存点，全部成功后提交，任一任务失败时回滚整个事务；
+// 返回每个任务的执行结果，回滚SQL按保存点拆分后记录在各自的任务中
+func (e *ExecuteMySQLDMLInTransaction) Run() (results []base.ReturnData, err error) {
+	results = make([]base.ReturnData, len(e.Tasks))
+	logs := make([][]string, len(e.Tasks))
+	var current int
+
+	// Function to log messages and publish
+	logAndPublish := func(msg string) {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		formattedMsg := fmt.Sprintf("[%s] %s", timestamp, msg)
+		logs[current] = append(logs[current], formattedMsg)
+		base.PublishMessageToChannel(e.OrderID, formattedMsg, "")
+	}
+
+	// 所有任务的执行日志
+	finish := func() {
+		for i := range results {
+			results[i].ExecuteLog = strings.Join(logs[i], "\n")
+		}
+	}
+
+	// Logging function for errors
+	logErrorAndReturn := func(err error, errMsg string) ([]base.ReturnData, error) {
+		logAndPublish(errMsg + err.Error())
+		for i := range results {
+			results[i].Error = err.Error()
+		}
+		finish()
+		return results, err
+	}
+
+	if len(e.Tasks) == 0 {
+		return results, nil
+	}
+
+	// CREATE A NEW DATABASE CONNECTION
+	db, err := NewMySQLCnx(e.DBConfig)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, fmt.Sprintf("访问数据库(%s:%d)失败，错误：", e.Hostname, e.Port))
+	}
+	defer db.Close()
+	logAndPublish(fmt.Sprintf("访问数据库(%s:%d)成功", e.Hostname, e.Port))
+
+	// GET CONNECTION ID
+	connectionID, err := DaoMySQLGetConnectionID(db)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "获取数据库Connection ID失败，错误：")
+	}
+	logAndPublish(fmt.Sprintf("数据库Connection ID：%d", connectionID))
+
+	// 注册所有任务，终止任一任务都会终止整个事务
+	var cancelled atomic.Bool
+	for _, task := range e.Tasks {
+		base.RegisterRunningTask(e.OrderID, task.TaskID, func() error {
+			cancelled.Store(true)
+			return DaoMySQLKillQuery(e.DBConfig, connectionID)
+		})
+		defer base.UnregisterRunningTask(task.TaskID)
+	}
+
+	// 执行前检查实例健康状态
+	g := guard.New(e.DBConfig, NewMySQLCnx)
+	g.ConnectionID = connectionID
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
+	// SHOW PROCESS
+	ch1 := make(chan int64)
+	go DaoMySQLGetProcesslist(e.DBConfig, e.OrderID, connectionID, ch1)
+	defer close(ch1)
+
+	// 获取执行开始前的binlog position
+	startFile, startPosition, startGTIDSet, err := DaoMySQLGetBinlogPos(db)
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "获取Start Binlog File和Position失败，错误：")
+	}
+	logAndPublish(fmt.Sprintf("Start Binlog File：%s，Position：%d", startFile, startPosition))
+
+	// 开启事务
+	tx, err := db.Begin()
+	if err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "开启事务失败，错误：")
+	}
+	logAndPublish(fmt.Sprintf("开启事务，共%d个任务", len(e.Tasks)))
+
+	startTime := time.Now()
+	var affectedRows int64
+	savepoints := make([]string, len(e.Tasks))
+	taskIDs := make([]string, len(e.Tasks))
+	for i, task := range e.Tasks {
+		current = i
+		savepoint := fmt.Sprintf("task_%d", i+1)
+		savepoints[i], taskIDs[i] = savepoint, task.TaskID
+		if _, err := tx.Exec("SAVEPOINT " + savepoint); err != nil {
+			tx.Rollback()
+			return logErrorAndReturn(base.SQLExecuteError{Err: err}, "创建保存点失败，事务已回滚，错误：")
+		}
+		stopWatch := g.WatchStatement("")
+		result, err := tx.Exec(task.SQL)
+		stopWatch()
+		if err == nil && cancelled.Load() {
+			err = errors.New("任务已被终止")
+		}
+		if err != nil {
+			// 撤销当前任务的修改后回滚整个事务
+			tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+			tx.Rollback()
+			return logErrorAndReturn(base.SQLExecuteError{Err: err}, fmt.Sprintf("第%d个任务执行失败，事务已回滚，错误：", i+1))
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return logErrorAndReturn(base.SQLExecuteError{Err: err}, "获取影响行数失败，事务已回滚，错误：")
+		}
+		results[i].AffectedRows = rows
+		affectedRows += rows
+		logAndPublish(fmt.Sprintf("第%d个任务执行成功，影响行数%d", i+1, rows))
+	}
+	if err := tx.Commit(); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "提交事务失败，错误：")
+	}
+	executeCostTime := utils.HumanfriendlyTimeUnit(time.Since(startTime))
+	logAndPublish(fmt.Sprintf("事务提交成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
+	for i := range results {
+		results[i].ExecuteCostTime = executeCostTime
+	}
+
+	// 获取执行后的binlog position
+	endFile, endPosition, endGTIDSet, err := DaoMySQLGetBinlogPos(db)
+	if err != nil {
+		return logErrorAndReturn(base.RollbackSQLError{Err: err}, "获取End Binlog File和Position失败，错误：")
+	}
+	logAndPublish(fmt.Sprintf("End Binlog File：%s，Position：%d", endFile, endPosition))
+
+	// 影响行数大于0，才执行生成回滚SQL操作
+	if affectedRows > 0 {
+		logAndPublish("开始解析Binlog生成每个任务的回滚SQL")
+		startTime = time.Now()
+		binlog := Binlog{
+			DBConfig:      e.DBConfig,
+			ConnectionID:  connectionID,
+			StartFile:     startFile,
+			StartPosition: startPosition,
+			EndFile:       endFile,
+			EndPosition:   endPosition,
+			StartGTIDSet:  startGTIDSet,
+			EndGTIDSet:    endGTIDSet}
+		rollbackSQLs, rollbackFiles, err := binlog.RunSavepoints(savepoints, taskIDs)
+		if err != nil {
+			return logErrorAndReturn(base.RollbackSQLError{Err: err}, "生成回滚SQL失败，错误：")
+		}
+		backupCostTime := utils.HumanfriendlyTimeUnit(time.Since(startTime))
+		logAndPublish(fmt.Sprintf("生成回滚SQL成功，耗时：%s", backupCostTime))
+		for i := range results {
+			results[i].RollbackSQL = rollbackSQLs[i]
+			results[i].RollbackSQLFile = rollbackFiles[i]
+			results[i].BackupCostTime = backupCostTime
+		}
+	}
+	finish()
+	return results, nil
+}
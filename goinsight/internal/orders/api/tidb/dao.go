@@ -175,3 +175,16 @@ func DaoTiDBGetProcesslist(dbconfig *base.DBConfig, order_id string, connection_
 		base.PublishMessageToChannel(order_id, row, "processlist")
 	}
 }
+
+// 终止指定连接正在执行的语句
+func DaoTiDBKillQuery(dbconfig *base.DBConfig, connection_id int64) error {
+	// Create a new database connection
+	db, err := NewTiDBCnx(dbconfig)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// TiDB requires the TIDB keyword to kill a query on the current tidb-server
+	_, err = db.Exec(fmt.Sprintf("KILL TIDB QUERY %d", connection_id))
+	return err
+}
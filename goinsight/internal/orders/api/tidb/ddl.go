@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
 	"goInsight/pkg/parser"
 	"goInsight/pkg/utils"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -44,13 +46,39 @@ func ExecuteOnlineDDL(dc *base.DBConfig) (data base.ReturnData, err error) {
 	}
 	logAndPublish(fmt.Sprintf("数据库Connection ID：%d", connectionID))
 
+	// 注册任务，支持通过KILL TIDB QUERY终止执行
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(dc.OrderID, dc.TaskID, func() error {
+		cancelled.Store(true)
+		return DaoTiDBKillQuery(dc, connectionID)
+	})
+	defer base.UnregisterRunningTask(dc.TaskID)
+
+	// 执行前检查实例健康状态
+	g := guard.New(dc, NewTiDBCnx)
+	g.ConnectionID = connectionID
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
+	// 执行前获取表结构，用于生成回滚SQL
+	rollback, err := base.NewDDLRollback(dc.SQL, dc.Schema)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("不生成回滚SQL，原因：%s", err.Error()))
+	} else {
+		rollback.Capture(db)
+	}
+
 	// SHOW PROCESS
 	ch1 := make(chan int64)
 	go DaoTiDBGetProcesslist(dc, dc.OrderID, connectionID, ch1)
 
 	// 执行SQL
 	startTime := time.Now()
+	stopWatch := g.WatchStatement("")
 	affectedRows, err := DaoTiDBExecute(db, dc.SQL, ch1)
+	stopWatch()
 	if err != nil {
 		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "SQL执行失败，错误：")
 	}
@@ -58,6 +86,11 @@ func ExecuteOnlineDDL(dc *base.DBConfig) (data base.ReturnData, err error) {
 	executeCostTime := utils.HumanfriendlyTimeUnit(endTime.Sub(startTime))
 	logAndPublish(fmt.Sprintf("SQL执行成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
 
+	// 生成回滚SQL
+	if rollback != nil {
+		data.RollbackSQL = rollback.RollbackSQL(logAndPublish)
+	}
+
 	// 返回数据
 	data.ExecuteLog = strings.Join(executeLog, "\n")
 	data.AffectedRows = affectedRows
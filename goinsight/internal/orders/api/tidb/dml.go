@@ -1,12 +1,16 @@
 package tidb
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"goInsight/pkg/utils"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/guard"
 )
 
 // TiDB DML
@@ -49,6 +53,22 @@ func (e *ExecuteTiDBDML) Run() (data base.ReturnData, err error) {
 	}
 	logAndPublish(fmt.Sprintf("数据库Connection ID：%d", connectionID))
 
+	// 注册任务，支持通过KILL TIDB QUERY终止执行
+	var cancelled atomic.Bool
+	base.RegisterRunningTask(e.OrderID, e.TaskID, func() error {
+		cancelled.Store(true)
+		return DaoTiDBKillQuery(e.DBConfig, connectionID)
+	})
+	defer base.UnregisterRunningTask(e.TaskID)
+
+	// 执行前检查实例健康状态
+	g := guard.New(e.DBConfig, NewTiDBCnx)
+	g.ConnectionID = connectionID
+	defer g.Close()
+	if err := g.Before(&cancelled, logAndPublish); err != nil {
+		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "实例健康检查失败，错误：")
+	}
+
 	// SHOW PROCESS
 	ch1 := make(chan int64)
 	go DaoTiDBGetProcesslist(e.DBConfig, e.OrderID, connectionID, ch1)
@@ -64,9 +84,31 @@ func (e *ExecuteTiDBDML) Run() (data base.ReturnData, err error) {
 	// }
 	// fmt.Println("sql wa::: ", rw.SQL)
 
+	// 解析SQL，判断是否支持基于修改前镜像生成回滚SQL
+	pre, err := newPreImage(e.SQL, e.Schema)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("不生成回滚SQL，原因：%s", err.Error()))
+	}
+
+	// 语句开始执行前终止时KILL没有可终止的语句，需要在这里停止
+	if cancelled.Load() {
+		close(ch1)
+		return logErrorAndReturn(base.SQLExecuteError{Err: errors.New("任务已被终止")}, "SQL执行失败，错误：")
+	}
+
 	// 执行SQL
 	startTime := time.Now()
-	affectedRows, err := DaoTiDBExecute(db, e.SQL, ch1)
+	stopWatch := g.WatchStatement("")
+	var (
+		affectedRows int64
+		rollbackSQL  string
+	)
+	if pre != nil {
+		affectedRows, rollbackSQL, err = e.executeWithPreImage(db, pre, ch1, &cancelled, logAndPublish)
+	} else {
+		affectedRows, err = DaoTiDBExecute(db, e.SQL, ch1)
+	}
+	stopWatch()
 	if err != nil {
 		return logErrorAndReturn(base.SQLExecuteError{Err: err}, "SQL执行失败，错误：")
 	}
@@ -74,12 +116,63 @@ func (e *ExecuteTiDBDML) Run() (data base.ReturnData, err error) {
 	executeCostTime := utils.HumanfriendlyTimeUnit(endTime.Sub(startTime))
 	logAndPublish(fmt.Sprintf("SQL执行成功，影响行数%d，执行耗时：%s", affectedRows, executeCostTime))
 
-	// TiDB不支持生成回滚SQL
-	logAndPublish("TiDB不支持生成回滚SQL")
-
 	// 返回数据
 	data.ExecuteLog = strings.Join(executeLog, "\n")
 	data.AffectedRows = affectedRows
 	data.ExecuteCostTime = executeCostTime
+	data.RollbackSQL = rollbackSQL
 	return
 }
+
+// 在同一个事务中获取修改前的数据并执行SQL，提交后根据修改前的数据生成回滚SQL；
+// 匹配行数超过最大影响行数或无法生成回滚SQL时，仅执行SQL
+func (e *ExecuteTiDBDML) executeWithPreImage(db *sql.DB, pre *preImage, ch chan<- int64, cancelled *atomic.Bool, logAndPublish func(string)) (int64, string, error) {
+	// Send a signal to the processlist goroutine
+	ch <- 1
+	defer close(ch)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	columns, rows, err := pre.fetch(tx, e.MaxAffectedRows)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("获取修改前的数据失败，不生成回滚SQL，原因：%s", err.Error()))
+	} else {
+		logAndPublish(fmt.Sprintf("获取修改前的数据成功，共%d行", len(rows)))
+	}
+	// 获取修改前的数据期间被终止时不再执行
+	if cancelled.Load() {
+		return 0, "", errors.New("任务已被终止")
+	}
+	result, err := tx.Exec(e.SQL)
+	if err != nil {
+		return 0, "", err
+	}
+	affectedRows, err := result.RowsAffected()
+	if err != nil {
+		return 0, "", err
+	}
+	var primaryKeys []string
+	if columns != nil && !pre.isDelete {
+		if primaryKeys, err = DaoTiDBGetPrimaryKeys(tx, pre.schema, pre.table); err != nil {
+			logAndPublish(fmt.Sprintf("获取表的主键失败，不生成回滚SQL，原因：%s", err.Error()))
+			columns = nil
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, "", err
+	}
+	if columns == nil || affectedRows == 0 {
+		return affectedRows, "", nil
+	}
+	sqls, err := pre.rollbackSQL(columns, rows, primaryKeys)
+	if err != nil {
+		logAndPublish(fmt.Sprintf("不生成回滚SQL，原因：%s", err.Error()))
+		return affectedRows, "", nil
+	}
+	logAndPublish("生成回滚SQL成功")
+	return affectedRows, strings.Join(sqls, ";\r\n"), nil
+}
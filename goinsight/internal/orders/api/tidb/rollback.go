@@ -0,0 +1,301 @@
+/*
+@Desc    :   基于修改前镜像生成TiDB DML的回滚SQL
+*/
+
+package tidb
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"goInsight/pkg/parser"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+)
+
+// 默认最多保存的修改前镜像行数
+const defaultMaxPreImageRows = 100
+
+// 单表UPDATE/DELETE语句的修改前镜像
+type preImage struct {
+	schema   string
+	table    string
+	isDelete bool
+	from     string   // 获取修改前数据的SELECT语句中FROM及之后的部分
+	assigned []string // UPDATE语句修改的列
+}
+
+// 一行修改前的数据，值已格式化为SQL字面量
+type preImageRow map[string]string
+
+func restoreNode(node ast.Node) (string, error) {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags|format.RestoreStringWithoutCharset, &sb)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// 解析SQL，生成获取修改前数据的SELECT语句，仅支持单表UPDATE/DELETE
+func newPreImage(sqltext, defaultSchema string) (*preImage, error) {
+	stmt, err := parser.NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, err
+	}
+	p := &preImage{}
+	var (
+		refs  *ast.TableRefsClause
+		where ast.ExprNode
+		order *ast.OrderByClause
+		limit *ast.Limit
+	)
+	switch s := stmt.(type) {
+	case *ast.UpdateStmt:
+		if s.MultipleTable {
+			return nil, errors.New("多表UPDATE语句不支持生成回滚SQL")
+		}
+		refs, where, order, limit = s.TableRefs, s.Where, s.Order, s.Limit
+		for _, assignment := range s.List {
+			p.assigned = append(p.assigned, assignment.Column.Name.O)
+		}
+	case *ast.DeleteStmt:
+		if s.IsMultiTable {
+			return nil, errors.New("多表DELETE语句不支持生成回滚SQL")
+		}
+		refs, where, order, limit = s.TableRefs, s.Where, s.Order, s.Limit
+		p.isDelete = true
+	default:
+		return nil, errors.New("仅UPDATE/DELETE语句支持生成回滚SQL")
+	}
+	if refs == nil || refs.TableRefs == nil || refs.TableRefs.Right != nil {
+		return nil, errors.New("多表关联语句不支持生成回滚SQL")
+	}
+	source, ok := refs.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return nil, errors.New("多表关联语句不支持生成回滚SQL")
+	}
+	table, ok := source.Source.(*ast.TableName)
+	if !ok {
+		return nil, errors.New("子查询语句不支持生成回滚SQL")
+	}
+	p.schema, p.table = table.Schema.O, table.Name.O
+	if p.schema == "" {
+		p.schema = defaultSchema
+	}
+	// 拼接SELECT语句，保留原语句的WHERE/ORDER BY/LIMIT
+	tableRef, err := restoreNode(source)
+	if err != nil {
+		return nil, err
+	}
+	query := "FROM " + tableRef
+	if where != nil {
+		text, err := restoreNode(where)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf("%s WHERE %s", query, text)
+	}
+	// OrderByClause和Limit还原后已包含关键字
+	if order != nil {
+		text, err := restoreNode(order)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf("%s %s", query, text)
+	}
+	if limit != nil {
+		text, err := restoreNode(limit)
+		if err != nil {
+			return nil, err
+		}
+		query = fmt.Sprintf("%s %s", query, text)
+	}
+	p.from = query
+	return p, nil
+}
+
+// 获取修改前数据的SELECT语句，只查询指定的列，未指定时查询所有列
+func (p *preImage) query(columns []string) string {
+	if len(columns) == 0 {
+		return "SELECT * " + p.from
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = fmt.Sprintf("`%s`", c)
+	}
+	return fmt.Sprintf("SELECT %s %s", strings.Join(quoted, ","), p.from)
+}
+
+// 表的列信息
+type tableColumn struct {
+	Name  string
+	Extra string
+}
+
+// 排除VIRTUAL/STORED GENERATED列，生成列不能出现在回滚的INSERT和UPDATE中
+func storedColumns(columns []tableColumn) []string {
+	var result []string
+	for _, c := range columns {
+		// 有默认表达式的普通列EXTRA为DEFAULT_GENERATED，不能排除
+		extra := strings.ToUpper(c.Extra)
+		if strings.Contains(extra, "VIRTUAL GENERATED") || strings.Contains(extra, "STORED GENERATED") {
+			continue
+		}
+		result = append(result, c.Name)
+	}
+	return result
+}
+
+// 获取修改前的数据并加锁，不包含生成列，返回的行数超过maxRows时返回错误
+func (p *preImage) fetch(tx *sql.Tx, maxRows int) (columns []string, rows []preImageRow, err error) {
+	if maxRows <= 0 {
+		maxRows = defaultMaxPreImageRows
+	}
+	tableColumns, err := DaoTiDBGetColumns(tx, p.schema, p.table)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err := tx.Query(p.query(storedColumns(tableColumns)) + " FOR UPDATE")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer result.Close()
+	columnTypes, err := result.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ct := range columnTypes {
+		columns = append(columns, ct.Name())
+	}
+	vals := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	for result.Next() {
+		if len(rows) >= maxRows {
+			return nil, nil, fmt.Errorf("匹配的行数超过最大影响行数%d", maxRows)
+		}
+		if err := result.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		row := make(preImageRow, len(columns))
+		for i, c := range columns {
+			row[c] = sqlLiteral(vals[i], columnTypes[i].DatabaseTypeName())
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, result.Err()
+}
+
+// 将查询结果转换为SQL字面量
+func sqlLiteral(v sql.RawBytes, dbType string) string {
+	if v == nil {
+		return "NULL"
+	}
+	switch strings.ToUpper(dbType) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE",
+		"UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		return string(v)
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT":
+		return "0x" + hex.EncodeToString(v)
+	}
+	return "'" + literalReplacer.Replace(string(v)) + "'"
+}
+
+var literalReplacer = strings.NewReplacer(
+	"\\", "\\\\", "'", "\\'", "\"", "\\\"",
+	"\x00", "\\0", "\n", "\\n", "\r", "\\r", "\x1a", "\\Z",
+)
+
+// 根据修改前的数据生成回滚SQL，DELETE生成INSERT，UPDATE根据主键生成UPDATE
+func (p *preImage) rollbackSQL(columns []string, rows []preImageRow, primaryKeys []string) ([]string, error) {
+	table := fmt.Sprintf("`%s`.`%s`", p.schema, p.table)
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = fmt.Sprintf("`%s`", c)
+	}
+	if !p.isDelete {
+		if len(primaryKeys) == 0 {
+			return nil, fmt.Errorf("表%s没有主键，UPDATE语句不支持生成回滚SQL", table)
+		}
+		for _, col := range p.assigned {
+			for _, pk := range primaryKeys {
+				if strings.EqualFold(col, pk) {
+					return nil, fmt.Errorf("UPDATE语句修改了主键列`%s`，不支持生成回滚SQL", pk)
+				}
+			}
+		}
+	}
+	var sqls []string
+	for _, row := range rows {
+		if p.isDelete {
+			values := make([]string, len(columns))
+			for i, c := range columns {
+				values[i] = row[c]
+			}
+			sqls = append(sqls, fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)", table, strings.Join(quoted, ","), strings.Join(values, ",")))
+			continue
+		}
+		var sets, conditions []string
+		for i, c := range columns {
+			sets = append(sets, fmt.Sprintf("%s=%s", quoted[i], row[c]))
+		}
+		for _, pk := range primaryKeys {
+			conditions = append(conditions, fmt.Sprintf("`%s`=%s", pk, row[pk]))
+		}
+		sqls = append(sqls, fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(sets, ","), strings.Join(conditions, " AND ")))
+	}
+	return sqls, nil
+}
+
+// 获取表的主键列
+func DaoTiDBGetPrimaryKeys(tx *sql.Tx, schema, table string) ([]string, error) {
+	rows, err := tx.Query(
+		"SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA=? AND TABLE_NAME=? AND CONSTRAINT_NAME='PRIMARY' ORDER BY ORDINAL_POSITION",
+		schema, table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// 获取表的列，按列的顺序返回
+func DaoTiDBGetColumns(tx *sql.Tx, schema, table string) ([]tableColumn, error) {
+	rows, err := tx.Query(
+		"SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA=? AND TABLE_NAME=? ORDER BY ORDINAL_POSITION",
+		schema, table,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		if err := rows.Scan(&column.Name, &column.Extra); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("表`%s`.`%s`不存在", schema, table)
+	}
+	return columns, nil
+}
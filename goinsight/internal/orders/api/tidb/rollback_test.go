@@ -0,0 +1,68 @@
+package tidb
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreImage(t *testing.T) {
+	tests := []struct {
+		sql       string
+		wantQuery string
+		wantSQL   []string
+	}{
+		{
+			sql:       "update t1 set c1='x' where c2>10 order by id limit 2",
+			wantQuery: "SELECT * FROM `t1` WHERE `c2`>10 ORDER BY `id` LIMIT 2",
+			wantSQL:   []string{"UPDATE `d0`.`t1` SET `id`=1,`c1`='a\\'b',`c2`=NULL WHERE `id`=1"},
+		},
+		{
+			sql:       "delete from d1.t1 where c2 = 'a'",
+			wantQuery: "SELECT * FROM `d1`.`t1` WHERE `c2`='a'",
+			wantSQL:   []string{"INSERT INTO `d1`.`t1`(`id`,`c1`,`c2`) VALUES(1,'a\\'b',NULL)"},
+		},
+	}
+	columns := []string{"id", "c1", "c2"}
+	rows := []preImageRow{{
+		"id": sqlLiteral(sql.RawBytes("1"), "BIGINT"),
+		"c1": sqlLiteral(sql.RawBytes("a'b"), "VARCHAR"),
+		"c2": sqlLiteral(nil, "VARCHAR"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			p, err := newPreImage(tt.sql, "d0")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantQuery, p.query(nil))
+			sqls, err := p.rollbackSQL(columns, rows, []string{"id"})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, sqls)
+		})
+	}
+
+	p, err := newPreImage("update t1 set id=2 where id=1", "d0")
+	assert.NoError(t, err)
+	_, err = p.rollbackSQL(columns, rows, []string{"id"})
+	assert.Error(t, err)
+
+	// 生成列不查询，也不出现在回滚SQL中
+	p, err = newPreImage("delete from t1 where id=1", "d0")
+	assert.NoError(t, err)
+	stored := storedColumns([]tableColumn{
+		{Name: "id", Extra: "auto_increment"},
+		{Name: "c1", Extra: "DEFAULT_GENERATED"},
+		{Name: "c2", Extra: "VIRTUAL GENERATED"},
+		{Name: "c3", Extra: "STORED GENERATED"},
+	})
+	assert.Equal(t, []string{"id", "c1"}, stored)
+	assert.Equal(t, "SELECT `id`,`c1` FROM `t1` WHERE `id`=1", p.query(stored))
+	sqls, err := p.rollbackSQL(stored, rows, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"INSERT INTO `d0`.`t1`(`id`,`c1`) VALUES(1,'a\\'b')"}, sqls)
+
+	_, err = newPreImage("update t1, t2 set t1.c1=t2.c1 where t1.id=t2.id", "d0")
+	assert.Error(t, err)
+	_, err = newPreImage("insert into t1 values(1)", "d0")
+	assert.Error(t, err)
+}
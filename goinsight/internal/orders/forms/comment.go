@@ -0,0 +1,17 @@
+package forms
+
+type GetOrderCommentsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	TaskID  string `form:"task_id" json:"task_id" binding:"omitempty,uuid"`
+}
+
+type CreateOrderCommentForm struct {
+	OrderID  string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	TaskID   string `form:"task_id" json:"task_id" binding:"omitempty,uuid"`
+	ParentID uint64 `form:"parent_id" json:"parent_id"`
+	Content  string `form:"content" json:"content" binding:"required,max=8192"`
+}
+
+type UpdateOrderCommentForm struct {
+	Content string `form:"content" json:"content" binding:"required,max=8192"`
+}
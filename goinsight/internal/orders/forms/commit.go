@@ -21,19 +21,58 @@ type GetUsersForm struct {
 }
 
 type CreateOrderForm struct {
+	Title            string            `form:"title" json:"title" binding:"required,min=5,max=96"`
+	Remark           string            `form:"remark" json:"remark" binding:"max=1024"`
+	IsRestrictAccess *bool             `form:"is_restrict_access" json:"is_restrict_access" validate:"boolean" binding:"required"`
+	DBType           models.EnumType   `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
+	SQLType          models.EnumType   `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL EXPORT"`
+	Environment      int               `form:"environment" json:"environment" binding:"required"`
+	InstanceID       string            `form:"instance_id" json:"instance_id" binding:"required_without=Targets"`
+	Schema           string            `form:"schema" json:"schema" binding:"max=1024"`
+	Approver         []string          `form:"approver" json:"approver" binding:"required"`
+	Executor         []string          `form:"executor" json:"executor" binding:"required"`
+	Reviewer         []string          `form:"reviewer" json:"reviewer" binding:"required"`
+	CC               []string          `form:"cc" json:"cc"`
+	Content          string            `form:"content" json:"content" binding:"required_without=SnippetID"`
+	SnippetID        uint64            `form:"snippet_id" json:"snippet_id"` // 使用SQL片段时，Content为片段替换参数后的SQL
+	SnippetParams    map[string]string `form:"snippet_params" json:"snippet_params"`
+	ScheduleTime     string            `form:"schedule_time" json:"schedule_time"`
+	ExportFileFormat models.EnumType   `form:"export_file_format" json:"export_file_format" binding:"required,oneof=XLSX CSV JSONL PARQUET SQL"`
+	CSVDelimiter     string            `form:"csv_delimiter" json:"csv_delimiter" binding:"omitempty,max=4"`
+	CSVEncoding      models.EnumType   `form:"csv_encoding" json:"csv_encoding" binding:"omitempty,oneof=UTF8 GBK"`
+	CSVWithBOM       bool              `form:"csv_with_bom" json:"csv_with_bom"`
+	IsBatchExecute   bool              `form:"is_batch_execute" json:"is_batch_execute"`
+	IsTransaction    bool              `form:"is_transaction" json:"is_transaction"`
+	Targets          []OrderTarget     `form:"targets" json:"targets" binding:"omitempty,max=64,dive"` // 多目标工单的实例和库，为空时使用InstanceID和Schema
+	Verifications    []Verification    `form:"verifications" json:"verifications" binding:"omitempty,max=20,dive"`
+}
+
+// 工单的执行目标，Schema和SchemaPattern二选一
+type OrderTarget struct {
+	InstanceID    string `form:"instance_id" json:"instance_id" binding:"required,uuid"`
+	Schema        string `form:"schema" json:"schema" binding:"required_without=SchemaPattern,max=128"`
+	SchemaPattern string `form:"schema_pattern" json:"schema_pattern" binding:"max=128"` // LIKE匹配实例的库，例如orders_db_%
+}
+
+// 从git仓库的迁移脚本创建工单
+type CreateMigrationOrderForm struct {
 	Title            string          `form:"title" json:"title" binding:"required,min=5,max=96"`
 	Remark           string          `form:"remark" json:"remark" binding:"max=1024"`
 	IsRestrictAccess *bool           `form:"is_restrict_access" json:"is_restrict_access" validate:"boolean" binding:"required"`
-	DBType           models.EnumType `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
-	SQLType          models.EnumType `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL EXPORT"`
+	DBType           models.EnumType `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB"`
+	SQLType          models.EnumType `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL"`
 	Environment      int             `form:"environment" json:"environment" binding:"required"`
-	InstanceID       string          `form:"instance_id" json:"instance_id" binding:"required,uuid"`
+	InstanceID       string          `form:"instance_id" json:"instance_id" binding:"required_without=Targets"`
 	Schema           string          `form:"schema" json:"schema" binding:"max=1024"`
 	Approver         []string        `form:"approver" json:"approver" binding:"required"`
 	Executor         []string        `form:"executor" json:"executor" binding:"required"`
 	Reviewer         []string        `form:"reviewer" json:"reviewer" binding:"required"`
 	CC               []string        `form:"cc" json:"cc"`
-	Content          string          `form:"content" json:"content" binding:"required"`
 	ScheduleTime     string          `form:"schedule_time" json:"schedule_time"`
-	ExportFileFormat models.EnumType `form:"export_file_format" json:"export_file_format" binding:"required,oneof=XLSX CSV"`
+	Targets          []OrderTarget   `form:"targets" json:"targets" binding:"omitempty,max=64,dive"`
+	Repository       string          `form:"repository" json:"repository" binding:"required,max=256"` // 相对于migration.repo_root的仓库目录
+	Path             string          `form:"path" json:"path" binding:"max=512"`                      // 迁移脚本在仓库中的目录
+	Ref              string          `form:"ref" json:"ref" binding:"max=256"`                        // 分支、标签或提交，为空时使用HEAD
+	FromVersion      string          `form:"from_version" json:"from_version" binding:"max=64"`
+	ToVersion        string          `form:"to_version" json:"to_version" binding:"max=64"`
 }
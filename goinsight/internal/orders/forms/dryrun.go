@@ -0,0 +1,9 @@
+package forms
+
+type StartDryRunForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
+
+type GetDryRunsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
@@ -0,0 +1,32 @@
+package forms
+
+import "goInsight/pkg/pagination"
+
+type CreateFlashbackForm struct {
+	InstanceID    string   `form:"instance_id" json:"instance_id" binding:"required,uuid"`
+	StartTime     string   `form:"start_time" json:"start_time" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	EndTime       string   `form:"end_time" json:"end_time" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	StartFile     string   `form:"start_file" json:"start_file" binding:"max=128"`
+	StartPosition int64    `form:"start_position" json:"start_position" binding:"min=0"`
+	EndFile       string   `form:"end_file" json:"end_file" binding:"max=128"`
+	EndPosition   int64    `form:"end_position" json:"end_position" binding:"min=0"`
+	Schemas       []string `form:"schemas" json:"schemas" binding:"dive,max=128"`
+	Tables        []string `form:"tables" json:"tables" binding:"dive,max=128"`
+	Operations    []string `form:"operations" json:"operations" binding:"dive,oneof=INSERT UPDATE DELETE"`
+}
+
+type GetFlashbackListForm struct {
+	PaginationQ pagination.Pagination
+	InstanceID  string `form:"instance_id" json:"instance_id" binding:"omitempty,uuid"`
+}
+
+type CreateFlashbackOrderForm struct {
+	FlashbackID string   `form:"flashback_id" json:"flashback_id" binding:"required,uuid"`
+	Title       string   `form:"title" json:"title" binding:"required,min=5,max=96"`
+	Remark      string   `form:"remark" json:"remark" binding:"max=1024"`
+	Schema      string   `form:"schema" json:"schema" binding:"required,max=128"`
+	Approver    []string `form:"approver" json:"approver" binding:"required"`
+	Executor    []string `form:"executor" json:"executor" binding:"required"`
+	Reviewer    []string `form:"reviewer" json:"reviewer" binding:"required"`
+	CC          []string `form:"cc" json:"cc"`
+}
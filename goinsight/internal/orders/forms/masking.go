@@ -0,0 +1,25 @@
+package forms
+
+import "goInsight/pkg/pagination"
+
+type GetMaskingRulesForm struct {
+	PaginationQ pagination.Pagination
+	Search      string `form:"search"`
+	InstanceID  string `form:"instance_id" json:"instance_id" binding:"omitempty,uuid"`
+}
+
+// 按库名.表名.列名匹配时Table和Column必填，按列名匹配时Pattern必填
+type CreateMaskingRuleForm struct {
+	InstanceID string `form:"instance_id" json:"instance_id" binding:"omitempty,uuid"`
+	Schema     string `form:"schema" json:"schema" binding:"excluded_with=Pattern,max=128"`
+	Table      string `form:"table" json:"table" binding:"required_without=Pattern,excluded_with=Pattern,max=128"`
+	Column     string `form:"column" json:"column" binding:"required_with=Table,excluded_with=Pattern,max=128"`
+	Pattern    string `form:"pattern" json:"pattern" binding:"max=256"`
+	Method     string `form:"method" json:"method" binding:"required,oneof=FULL PARTIAL HASH NULL"`
+	IsEnable   bool   `form:"is_enable" json:"is_enable"`
+	Remark     string `form:"remark" json:"remark" binding:"max=1024"`
+}
+
+type UpdateMaskingRuleForm struct {
+	CreateMaskingRuleForm
+}
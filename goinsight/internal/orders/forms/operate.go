@@ -1,9 +1,10 @@
 package forms
 
 type ApproveForm struct {
-	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
-	Msg     string `form:"msg" json:"msg" binding:"max=256"`
-	Status  string `form:"status" json:"status" binding:"required,oneof=pass reject"`
+	OrderID  string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	Msg      string `form:"msg" json:"msg" binding:"max=256"`
+	Status   string `form:"status" json:"status" binding:"required,oneof=pass reject"`
+	Revision int    `form:"revision" json:"revision" binding:"required,min=1"` // 审批时看到的工单内容版本
 }
 
 type FeedbackForm struct {
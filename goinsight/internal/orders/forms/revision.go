@@ -0,0 +1,17 @@
+package forms
+
+type ReviseOrderForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	Content string `form:"content" json:"content" binding:"required"`
+	Msg     string `form:"msg" json:"msg" binding:"max=1024"`
+}
+
+type GetOrderRevisionsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
+
+type GetOrderRevisionDiffForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	From    int    `form:"from" json:"from" binding:"required,min=1"`
+	To      int    `form:"to" json:"to" binding:"required,min=1"`
+}
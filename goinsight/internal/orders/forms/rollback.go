@@ -0,0 +1,14 @@
+package forms
+
+import "goInsight/pkg/pagination"
+
+type CreateRollbackOrderForm struct {
+	OrderID string   `form:"order_id" json:"order_id" binding:"required,uuid"`
+	TaskIDs []string `form:"task_ids" json:"task_ids" binding:"required,min=1,dive,uuid"`
+	Title   string   `form:"title" json:"title" binding:"omitempty,min=5,max=96"`
+	Remark  string   `form:"remark" json:"remark" binding:"max=1024"`
+}
+
+type GetRollbackOrdersForm struct {
+	PaginationQ pagination.Pagination
+}
@@ -0,0 +1,25 @@
+package forms
+
+// 统计的时间范围和环境，Format为csv时导出CSV文件
+type StatisticsForm struct {
+	StartDate   string `form:"start_date" json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate     string `form:"end_date" json:"end_date" binding:"required,datetime=2006-01-02"`
+	Environment int    `form:"environment" json:"environment"`
+	Format      string `form:"format" json:"format" binding:"omitempty,oneof=json csv"`
+}
+
+type OrderStatisticsForm struct {
+	StatisticsForm
+	Dimension string `form:"dimension" json:"dimension" binding:"required,oneof=environment organization applicant"`
+	Interval  string `form:"interval" json:"interval" binding:"omitempty,oneof=day week month"`
+}
+
+type RollbackStatisticsForm struct {
+	StatisticsForm
+	Interval string `form:"interval" json:"interval" binding:"omitempty,oneof=day week month"`
+}
+
+type TableStatisticsForm struct {
+	StatisticsForm
+	Limit int `form:"limit" json:"limit" binding:"omitempty,min=1,max=200"`
+}
@@ -5,9 +5,11 @@ import (
 )
 
 type SyntaxInspectForm struct {
-	DBType     models.EnumType `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
-	SQLType    models.EnumType `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL EXPORT"`
-	InstanceID string          `form:"instance_id" json:"instance_id" binding:"required,uuid"`
-	Schema     string          `form:"schema" json:"schema" binding:"max=1024"`
-	Content    string          `form:"content" json:"content" binding:"required"`
+	DBType        models.EnumType   `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
+	SQLType       models.EnumType   `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL EXPORT"`
+	InstanceID    string            `form:"instance_id" json:"instance_id" binding:"required,uuid"`
+	Schema        string            `form:"schema" json:"schema" binding:"max=1024"`
+	Content       string            `form:"content" json:"content" binding:"required_without=SnippetID"`
+	SnippetID     uint64            `form:"snippet_id" json:"snippet_id"` // 使用SQL片段时，Content为片段替换参数后的SQL
+	SnippetParams map[string]string `form:"snippet_params" json:"snippet_params"`
 }
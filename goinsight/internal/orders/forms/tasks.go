@@ -10,12 +10,18 @@ type GetTasksForm struct {
 	PaginationQ pagination.Pagination
 	Search      string `form:"search"`
 	Progress    string `form:"progress" json:"progress"`
+	InstanceID  string `form:"instance_id" json:"instance_id" binding:"omitempty,uuid"`
+	Schema      string `form:"schema" json:"schema"`
 }
 
 type PreviewTasksForm struct {
 	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
 }
 
+type GetTaskTargetsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
+
 type ExecuteSingleTaskForm struct {
 	ID      int64  `form:"id" json:"id" binding:"required"`
 	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
@@ -24,3 +30,31 @@ type ExecuteSingleTaskForm struct {
 type ExecuteAllTaskForm struct {
 	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
 }
+
+type PauseTasksForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	Msg     string `form:"msg" json:"msg" binding:"max=256"`
+}
+
+type ResumeTasksForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	Msg     string `form:"msg" json:"msg" binding:"max=256"`
+}
+
+type CancelTaskForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	TaskID  string `form:"task_id" json:"task_id" binding:"omitempty,uuid"` // 为空时终止工单中执行中的任务
+	Msg     string `form:"msg" json:"msg" binding:"max=256"`
+}
+
+type GhostControlForm struct {
+	OrderID   string `form:"order_id" json:"order_id" binding:"required,uuid"`
+	TaskID    string `form:"task_id" json:"task_id" binding:"required,uuid"`
+	Action    string `form:"action" json:"action" binding:"required,oneof=throttle no-throttle chunk-size unpostpone panic"`
+	ChunkSize int    `form:"chunk_size" json:"chunk_size"`
+	Msg       string `form:"msg" json:"msg" binding:"max=256"`
+}
+
+type GetGhostProgressForm struct {
+	TaskID string `form:"task_id" json:"task_id" binding:"required,uuid"`
+}
@@ -0,0 +1,71 @@
+package forms
+
+import (
+	"goInsight/internal/common/models"
+	"goInsight/pkg/pagination"
+	"goInsight/pkg/snippet"
+)
+
+// 模板预填的工单字段，和CreateOrderForm对应，所有字段都是可选的
+type OrderTemplateFields struct {
+	Title            string          `form:"title" json:"title,omitempty" binding:"max=96"`
+	Remark           string          `form:"remark" json:"remark,omitempty" binding:"max=1024"`
+	IsRestrictAccess *bool           `form:"is_restrict_access" json:"is_restrict_access,omitempty"`
+	DBType           models.EnumType `form:"db_type" json:"db_type,omitempty" binding:"omitempty,oneof=MySQL TiDB ClickHouse"`
+	SQLType          models.EnumType `form:"sql_type" json:"sql_type,omitempty" binding:"omitempty,oneof=DML DDL EXPORT"`
+	Environment      int             `form:"environment" json:"environment,omitempty"`
+	InstanceID       string          `form:"instance_id" json:"instance_id,omitempty" binding:"omitempty,uuid"`
+	Schema           string          `form:"schema" json:"schema,omitempty" binding:"max=1024"`
+	Approver         []string        `form:"approver" json:"approver,omitempty"`
+	Executor         []string        `form:"executor" json:"executor,omitempty"`
+	Reviewer         []string        `form:"reviewer" json:"reviewer,omitempty"`
+	CC               []string        `form:"cc" json:"cc,omitempty"`
+	ExportFileFormat models.EnumType `form:"export_file_format" json:"export_file_format,omitempty" binding:"omitempty,oneof=XLSX CSV JSONL PARQUET SQL"`
+	CSVDelimiter     string          `form:"csv_delimiter" json:"csv_delimiter,omitempty" binding:"omitempty,max=4"`
+	CSVEncoding      models.EnumType `form:"csv_encoding" json:"csv_encoding,omitempty" binding:"omitempty,oneof=UTF8 GBK"`
+	CSVWithBOM       bool            `form:"csv_with_bom" json:"csv_with_bom,omitempty"`
+	IsBatchExecute   bool            `form:"is_batch_execute" json:"is_batch_execute,omitempty"`
+	IsTransaction    bool            `form:"is_transaction" json:"is_transaction,omitempty"`
+}
+
+type GetOrderTemplatesForm struct {
+	PaginationQ pagination.Pagination
+	Search      string `form:"search"`
+}
+
+type CreateOrderTemplateForm struct {
+	Name   string              `form:"name" json:"name" binding:"required,min=2,max=128"`
+	Scope  string              `form:"scope" json:"scope" binding:"required,oneof=个人 组织"`
+	Fields OrderTemplateFields `form:"fields" json:"fields"`
+	Remark string              `form:"remark" json:"remark" binding:"max=1024"`
+}
+
+type UpdateOrderTemplateForm struct {
+	CreateOrderTemplateForm
+}
+
+type GetSQLSnippetsForm struct {
+	PaginationQ pagination.Pagination
+	Search      string          `form:"search"`
+	DBType      models.EnumType `form:"db_type" json:"db_type" binding:"omitempty,oneof=MySQL TiDB ClickHouse"`
+	SQLType     models.EnumType `form:"sql_type" json:"sql_type" binding:"omitempty,oneof=DML DDL EXPORT"`
+}
+
+type CreateSQLSnippetForm struct {
+	Name    string          `form:"name" json:"name" binding:"required,min=2,max=128"`
+	Scope   string          `form:"scope" json:"scope" binding:"required,oneof=个人 组织"`
+	DBType  models.EnumType `form:"db_type" json:"db_type" binding:"required,oneof=MySQL TiDB ClickHouse"`
+	SQLType models.EnumType `form:"sql_type" json:"sql_type" binding:"required,oneof=DML DDL EXPORT"`
+	Content string          `form:"content" json:"content" binding:"required"`
+	Params  []snippet.Param `form:"params" json:"params"`
+	Remark  string          `form:"remark" json:"remark" binding:"max=1024"`
+}
+
+type UpdateSQLSnippetForm struct {
+	CreateSQLSnippetForm
+}
+
+type RenderSQLSnippetForm struct {
+	SnippetID uint64            `form:"snippet_id" json:"snippet_id" binding:"required"`
+	Params    map[string]string `form:"params" json:"params"`
+}
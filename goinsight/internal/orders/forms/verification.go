@@ -0,0 +1,23 @@
+package forms
+
+// 执行完成后自动运行的校验查询
+// ROWS断言返回的行数，VALUE断言第一行第一列的值
+type Verification struct {
+	SQL      string `form:"sql" json:"sql" binding:"required,max=4096"`
+	Type     string `form:"type" json:"type" binding:"required,oneof=ROWS VALUE"`
+	Operator string `form:"operator" json:"operator" binding:"required,oneof== != > >= < <="`
+	Expected string `form:"expected" json:"expected" binding:"max=1024"`
+}
+
+type GetVerificationsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
+
+type UpdateVerificationsForm struct {
+	OrderID       string         `form:"order_id" json:"order_id" binding:"required,uuid"`
+	Verifications []Verification `form:"verifications" json:"verifications" binding:"omitempty,max=20,dive"`
+}
+
+type RunVerificationsForm struct {
+	OrderID string `form:"order_id" json:"order_id" binding:"required,uuid"`
+}
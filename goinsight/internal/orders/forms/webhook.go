@@ -0,0 +1,31 @@
+package forms
+
+import "goInsight/pkg/pagination"
+
+type GetWebhooksForm struct {
+	PaginationQ pagination.Pagination
+	Search      string `form:"search"`
+}
+
+// 更新时Secret为空或为查询返回的掩码表示不修改密钥
+type CreateWebhookForm struct {
+	Name       string   `form:"name" json:"name" binding:"required,max=128"`
+	URL        string   `form:"url" json:"url" binding:"required,url,max=1024"`
+	Secret     string   `form:"secret" json:"secret" binding:"max=256"`
+	Events     []string `form:"events" json:"events" binding:"required,min=1,dive,oneof=created approved rejected executing completed failed closed"`
+	MaxRetries int      `form:"max_retries" json:"max_retries" binding:"min=0,max=10"`
+	IsEnable   bool     `form:"is_enable" json:"is_enable"`
+	Remark     string   `form:"remark" json:"remark" binding:"max=1024"`
+}
+
+type UpdateWebhookForm struct {
+	CreateWebhookForm
+}
+
+type GetWebhookDeliveriesForm struct {
+	PaginationQ pagination.Pagination
+	WebhookID   uint64 `form:"webhook_id" json:"webhook_id"`
+	OrderID     string `form:"order_id" json:"order_id" binding:"omitempty,uuid"`
+	Event       string `form:"event" json:"event"`
+	Status      string `form:"status" json:"status" binding:"omitempty,oneof=发送中 成功 失败"`
+}
@@ -20,6 +20,7 @@ type InsightOrderRecords struct {
 	Title            string          `gorm:"type:varchar(128);not null;default:'';comment:工单标题;index:idx_title" json:"title"`
 	OrderID          uuid.UUID       `gorm:"type:char(36);comment:工单ID;uniqueIndex:uniq_order_id" json:"order_id"`
 	HookOrderID      uuid.UUID       `gorm:"type:char(36);comment:HOOK源工单ID;index:idx_hook_order_id" json:"hook_order_id"`
+	RollbackOrderID  uuid.UUID       `gorm:"type:char(36);comment:回滚的源工单ID;index:idx_rollback_order_id" json:"rollback_order_id"`
 	Remark           string          `gorm:"type:varchar(1024);not null;default:'';comment:工单备注" json:"remark"`
 	IsRestrictAccess bool            `gorm:"type:tinyint(1);not null;default:0;comment:是否限制访问" json:"is_restrict_access"`
 	DBType           models.EnumType `gorm:"type:ENUM('MySQL', 'TiDB', 'ClickHouse');default:'MySQL';comment:DB类型" json:"db_type"`
@@ -38,7 +39,20 @@ type InsightOrderRecords struct {
 	ScheduleTime     *time.Time      `gorm:"type:datetime;null;default:null;comment:计划执行时间" json:"schedule_time"`
 	FixVersion       string          `gorm:"type:varchar(128);not null;default:'';comment:上线版本;index" json:"fix_version"`
 	Content          string          `gorm:"type:text;null;comment:工单内容" json:"content"`
-	ExportFileFormat models.EnumType `gorm:"type:ENUM('XLSX', 'CSV');default:'XLSX';comment:导出文件格式" json:"export_file_format"`
+	ExportFileFormat models.EnumType `gorm:"type:ENUM('XLSX', 'CSV', 'JSONL', 'PARQUET', 'SQL');default:'XLSX';comment:导出文件格式" json:"export_file_format"`
+	CSVDelimiter     string          `gorm:"type:varchar(4);not null;default:'';comment:CSV分隔符，为空时使用逗号" json:"csv_delimiter"`
+	CSVEncoding      models.EnumType `gorm:"type:ENUM('UTF8', 'GBK');default:'UTF8';comment:CSV编码" json:"csv_encoding"`
+	CSVWithBOM       bool            `gorm:"type:tinyint(1);not null;default:0;comment:CSV是否写入BOM" json:"csv_with_bom"`
+	IsBatchExecute   bool            `gorm:"type:tinyint(1);not null;default:0;comment:DML是否分批执行" json:"is_batch_execute"`
+	IsTransaction    bool            `gorm:"type:tinyint(1);not null;default:0;comment:DML工单的所有任务是否在同一个事务中执行" json:"is_transaction"`
+	Targets          datatypes.JSON  `gorm:"type:json;null;default:null;comment:多目标工单的实例和库列表" json:"targets"`
+	Revision         int             `gorm:"type:int;not null;default:1;comment:工单内容的版本" json:"revision"`
+}
+
+// 工单的执行目标
+type OrderTarget struct {
+	InstanceID uuid.UUID `json:"instance_id"`
+	Schema     string    `json:"schema"`
 }
 
 func (InsightOrderRecords) TableName() string {
@@ -60,14 +74,16 @@ func (InsightOrderOpLogs) TableName() string {
 // 工单记录生成的执行任务
 type InsightOrderTasks struct {
 	*models.Model
-	OrderID  uuid.UUID       `gorm:"type:char(36);comment:关联insight_order_records的order_id;index" json:"order_id"`
-	TaskID   uuid.UUID       `gorm:"type:char(36);comment:任务ID;index" json:"task_id"`
-	DBType   models.EnumType `gorm:"type:ENUM('MySQL', 'TiDB', 'ClickHouse');default:'MySQL';comment:DB类型" json:"db_type"`
-	SQLType  models.EnumType `gorm:"type:ENUM('DML', 'DDL', 'EXPORT');default:'DML';comment:SQL类型" json:"sql_type"`
-	Executor string          `gorm:"type:varchar(128);null;default:null;comment:任务执行人" json:"executor"`
-	SQL      string          `gorm:"type:text;null;comment:SQL语句" json:"sql"`
-	Progress models.EnumType `gorm:"type:ENUM('未执行', '执行中', '已完成', '已失败', '已暂停');default:'未执行';comment:进度" json:"progress"`
-	Result   datatypes.JSON  `gorm:"type:json;null;default:null;comment:执行结果" json:"result"`
+	OrderID    uuid.UUID       `gorm:"type:char(36);comment:关联insight_order_records的order_id;index" json:"order_id"`
+	TaskID     uuid.UUID       `gorm:"type:char(36);comment:任务ID;index" json:"task_id"`
+	InstanceID uuid.UUID       `gorm:"type:char(36);comment:任务的目标实例，为空时使用工单的实例;index" json:"instance_id"`
+	Schema     string          `gorm:"type:varchar(128);not null;default:'';comment:任务的目标库" json:"schema"`
+	DBType     models.EnumType `gorm:"type:ENUM('MySQL', 'TiDB', 'ClickHouse');default:'MySQL';comment:DB类型" json:"db_type"`
+	SQLType    models.EnumType `gorm:"type:ENUM('DML', 'DDL', 'EXPORT');default:'DML';comment:SQL类型" json:"sql_type"`
+	Executor   string          `gorm:"type:varchar(128);null;default:null;comment:任务执行人" json:"executor"`
+	SQL        string          `gorm:"type:text;null;comment:SQL语句" json:"sql"`
+	Progress   models.EnumType `gorm:"type:ENUM('未执行', '执行中', '已完成', '已失败', '已暂停');default:'未执行';comment:进度" json:"progress"`
+	Result     datatypes.JSON  `gorm:"type:json;null;default:null;comment:执行结果" json:"result"`
 }
 
 func (InsightOrderTasks) TableName() string {
@@ -85,3 +101,237 @@ type InsightOrderMessages struct {
 func (InsightOrderMessages) TableName() string {
 	return "insight_order_messages"
 }
+
+// 闪回任务
+type InsightFlashbackRecords struct {
+	*models.Model
+	FlashbackID   uuid.UUID       `gorm:"type:char(36);comment:闪回任务ID;uniqueIndex:uniq_flashback_id" json:"flashback_id"`
+	Username      string          `gorm:"type:varchar(32);not null;default:'';comment:创建人;index" json:"username"`
+	InstanceID    uuid.UUID       `gorm:"type:char(36);comment:关联insight_db_config的instance_id;index" json:"instance_id"`
+	Schemas       datatypes.JSON  `gorm:"type:json;null;default:null;comment:过滤的库名" json:"schemas"`
+	Tables        datatypes.JSON  `gorm:"type:json;null;default:null;comment:过滤的表名" json:"tables"`
+	Operations    datatypes.JSON  `gorm:"type:json;null;default:null;comment:过滤的操作类型" json:"operations"`
+	StartTime     *time.Time      `gorm:"type:datetime;null;default:null;comment:开始时间" json:"start_time"`
+	EndTime       *time.Time      `gorm:"type:datetime;null;default:null;comment:结束时间" json:"end_time"`
+	StartFile     string          `gorm:"type:varchar(128);not null;default:'';comment:开始的binlog文件" json:"start_file"`
+	StartPosition int64           `gorm:"type:bigint;not null;default:0;comment:开始的binlog位置" json:"start_position"`
+	EndFile       string          `gorm:"type:varchar(128);not null;default:'';comment:结束的binlog文件" json:"end_file"`
+	EndPosition   int64           `gorm:"type:bigint;not null;default:0;comment:结束的binlog位置" json:"end_position"`
+	Progress      models.EnumType `gorm:"type:ENUM('执行中', '已完成', '已失败');default:'执行中';comment:进度" json:"progress"`
+	Statements    int64           `gorm:"type:bigint;not null;default:0;comment:闪回SQL条数" json:"statements"`
+	FilePath      string          `gorm:"type:varchar(256);not null;default:'';comment:闪回SQL文件" json:"-"`
+	FileSize      int64           `gorm:"type:bigint;not null;default:0;comment:闪回SQL文件大小" json:"file_size"`
+	Error         string          `gorm:"type:text;null;comment:错误信息" json:"error"`
+	OrderID       uuid.UUID       `gorm:"type:char(36);comment:生成的工单ID;index" json:"order_id"`
+}
+
+func (InsightFlashbackRecords) TableName() string {
+	return "insight_flashback_records"
+}
+
+// 导出文件表，记录导出文件的存储位置和生命周期
+type InsightExportFiles struct {
+	*models.Model
+	OrderID      uuid.UUID       `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	TaskID       uuid.UUID       `gorm:"type:char(36);comment:任务ID;uniqueIndex:uniq_task_id" json:"task_id"`
+	FileName     string          `gorm:"type:varchar(256);not null;default:'';comment:文件名" json:"file_name"`
+	FileSize     int64           `gorm:"type:bigint;not null;default:0;comment:文件大小" json:"file_size"`
+	Storage      string          `gorm:"type:varchar(16);not null;default:'local';comment:存储类型(local,s3)" json:"storage"`
+	ObjectKey    string          `gorm:"type:varchar(512);not null;default:'';comment:文件在存储中的名称" json:"-"`
+	ExpireAt     *time.Time      `gorm:"type:datetime;null;default:null;comment:过期时间，为空表示不过期;index" json:"expire_at"`
+	MaxDownloads int             `gorm:"type:int;not null;default:0;comment:最多下载次数，0表示不限制" json:"max_downloads"`
+	Downloads    int             `gorm:"type:int;not null;default:0;comment:已下载次数" json:"downloads"`
+	Status       models.EnumType `gorm:"type:ENUM('有效', '已过期');default:'有效';comment:文件状态" json:"status"`
+}
+
+func (InsightExportFiles) TableName() string {
+	return "insight_export_files"
+}
+
+// 导出文件下载审计表
+type InsightExportDownloadLogs struct {
+	*models.Model
+	TaskID    uuid.UUID `gorm:"type:char(36);comment:任务ID;index:idx_task_id" json:"task_id"`
+	Username  string    `gorm:"type:varchar(32);not null;default:'';comment:下载用户;index:idx_username" json:"username"`
+	ClientIP  string    `gorm:"type:varchar(64);not null;default:'';comment:客户端IP" json:"client_ip"`
+	UserAgent string    `gorm:"type:varchar(512);not null;default:'';comment:客户端UserAgent" json:"user_agent"`
+	IsSuccess bool      `gorm:"type:tinyint(1);not null;default:0;comment:是否下载成功" json:"is_success"`
+	Msg       string    `gorm:"type:varchar(1024);not null;default:'';comment:失败原因" json:"msg"`
+}
+
+func (InsightExportDownloadLogs) TableName() string {
+	return "insight_export_download_logs"
+}
+
+// 导出工单的数据脱敏规则
+type InsightDataMaskingRules struct {
+	*models.Model
+	InstanceID uuid.UUID       `gorm:"type:char(36);comment:关联insight_db_config的instance_id，为空时对所有实例生效;index" json:"instance_id"`
+	Schema     string          `gorm:"type:varchar(128);not null;default:'';comment:库名，为空时匹配所有库" json:"schema"`
+	Table      string          `gorm:"type:varchar(128);not null;default:'';comment:表名" json:"table"`
+	Column     string          `gorm:"type:varchar(128);not null;default:'';comment:列名" json:"column"`
+	Pattern    string          `gorm:"type:varchar(256);not null;default:'';comment:列名的正则表达式，和库名.表名.列名二选一" json:"pattern"`
+	Method     models.EnumType `gorm:"type:ENUM('FULL', 'PARTIAL', 'HASH', 'NULL');default:'FULL';comment:脱敏方式" json:"method"`
+	IsEnable   *bool           `gorm:"type:tinyint(1);not null;default:1;comment:是否启用" json:"is_enable"` // 指针类型，创建时保留false
+	Remark     string          `gorm:"type:varchar(1024);not null;default:'';comment:备注" json:"remark"`
+}
+
+func (InsightDataMaskingRules) TableName() string {
+	return "insight_data_masking_rules"
+}
+
+// 工单模板，创建工单时预填表单字段
+type InsightOrderTemplates struct {
+	*models.Model
+	Name            string          `gorm:"type:varchar(128);not null;default:'';comment:模板名称" json:"name"`
+	Scope           models.EnumType `gorm:"type:ENUM('个人', '组织');default:'个人';comment:可见范围" json:"scope"`
+	Username        string          `gorm:"type:varchar(32);not null;default:'';comment:创建人;index" json:"username"`
+	OrganizationKey string          `gorm:"type:varchar(256);not null;default:'';comment:组织范围的模板关联的组织;index" json:"organization_key"`
+	Fields          datatypes.JSON  `gorm:"type:json;null;default:null;comment:预填的工单字段" json:"fields"`
+	Remark          string          `gorm:"type:varchar(1024);not null;default:'';comment:备注" json:"remark"`
+}
+
+func (InsightOrderTemplates) TableName() string {
+	return "insight_order_templates"
+}
+
+// 参数化SQL片段，占位符格式为{{name}}
+type InsightSQLSnippets struct {
+	*models.Model
+	Name            string          `gorm:"type:varchar(128);not null;default:'';comment:片段名称" json:"name"`
+	Scope           models.EnumType `gorm:"type:ENUM('个人', '组织');default:'个人';comment:可见范围" json:"scope"`
+	Username        string          `gorm:"type:varchar(32);not null;default:'';comment:创建人;index" json:"username"`
+	OrganizationKey string          `gorm:"type:varchar(256);not null;default:'';comment:组织范围的片段关联的组织;index" json:"organization_key"`
+	DBType          models.EnumType `gorm:"type:ENUM('MySQL', 'TiDB', 'ClickHouse');default:'MySQL';comment:DB类型" json:"db_type"`
+	SQLType         models.EnumType `gorm:"type:ENUM('DML', 'DDL', 'EXPORT');default:'DML';comment:SQL类型" json:"sql_type"`
+	Content         string          `gorm:"type:text;null;comment:SQL片段" json:"content"`
+	Params          datatypes.JSON  `gorm:"type:json;null;default:null;comment:参数定义" json:"params"`
+	Remark          string          `gorm:"type:varchar(1024);not null;default:'';comment:备注" json:"remark"`
+}
+
+func (InsightSQLSnippets) TableName() string {
+	return "insight_sql_snippets"
+}
+
+// 迁移脚本的执行历史，按实例和库记录已提交的版本
+type InsightMigrationHistory struct {
+	*models.Model
+	InstanceID  uuid.UUID       `gorm:"type:char(36);comment:关联insight_db_config的instance_id;uniqueIndex:uniq_version" json:"instance_id"`
+	Schema      string          `gorm:"type:varchar(128);not null;default:'';comment:库名;uniqueIndex:uniq_version" json:"schema"`
+	Version     string          `gorm:"type:varchar(64);not null;default:'';comment:版本号;uniqueIndex:uniq_version" json:"version"`
+	Description string          `gorm:"type:varchar(256);not null;default:'';comment:描述" json:"description"`
+	Repository  string          `gorm:"type:varchar(256);not null;default:'';comment:仓库" json:"repository"`
+	Script      string          `gorm:"type:varchar(512);not null;default:'';comment:脚本在仓库中的路径" json:"script"`
+	Checksum    string          `gorm:"type:char(64);not null;default:'';comment:脚本内容的SHA256" json:"checksum"`
+	CommitSHA   string          `gorm:"type:char(40);not null;default:'';comment:脚本所在的提交" json:"commit_sha"`
+	OrderID     uuid.UUID       `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	Statements  int             `gorm:"type:int;not null;default:0;comment:脚本的语句数量" json:"statements"`
+	TaskIDs     datatypes.JSON  `gorm:"type:json;null;default:null;comment:脚本在每个目标上对应的任务" json:"task_ids"`
+	Status      models.EnumType `gorm:"type:ENUM('待执行', '已执行', '失败');default:'待执行';comment:状态" json:"status"`
+}
+
+func (InsightMigrationHistory) TableName() string {
+	return "insight_migration_history"
+}
+
+// 工单评论，TaskID不为空时评论关联到具体的语句
+type InsightOrderComments struct {
+	*models.Model
+	OrderID  uuid.UUID      `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	TaskID   uuid.UUID      `gorm:"type:char(36);comment:关联insight_order_tasks的task_id，为空时评论整个工单;index:idx_task_id" json:"task_id"`
+	ParentID uint64         `gorm:"type:bigint;not null;default:0;comment:回复的评论ID" json:"parent_id"`
+	Username string         `gorm:"type:varchar(32);not null;default:'';comment:评论人;index:idx_username" json:"username"`
+	Content  string         `gorm:"type:text;null;comment:评论内容，markdown格式" json:"content"`
+	Mentions datatypes.JSON `gorm:"type:json;null;default:null;comment:提及的用户" json:"mentions"`
+}
+
+func (InsightOrderComments) TableName() string {
+	return "insight_order_comments"
+}
+
+// 工单内容的修改历史，首次修改时同时记录原始版本
+type InsightOrderRevisions struct {
+	*models.Model
+	OrderID  uuid.UUID `gorm:"type:char(36);comment:工单ID;uniqueIndex:uniq_revision" json:"order_id"`
+	Revision int       `gorm:"type:int;not null;default:1;comment:版本;uniqueIndex:uniq_revision" json:"revision"`
+	Username string    `gorm:"type:varchar(32);not null;default:'';comment:修改人" json:"username"`
+	Content  string    `gorm:"type:text;null;comment:工单内容" json:"content"`
+	Msg      string    `gorm:"type:varchar(1024);not null;default:'';comment:修改说明" json:"msg"`
+}
+
+func (InsightOrderRevisions) TableName() string {
+	return "insight_order_revisions"
+}
+
+// 工单执行完成后运行的校验查询，多目标工单对每个目标分别校验
+type InsightOrderVerifications struct {
+	*models.Model
+	OrderID    uuid.UUID       `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	InstanceID uuid.UUID       `gorm:"type:char(36);comment:关联insight_db_config的instance_id" json:"instance_id"`
+	Schema     string          `gorm:"type:varchar(128);not null;default:'';comment:库名" json:"schema"`
+	SQL        string          `gorm:"type:text;null;comment:校验查询" json:"sql"`
+	Type       models.EnumType `gorm:"type:ENUM('ROWS', 'VALUE');default:'ROWS';comment:断言类型" json:"type"`
+	Operator   string          `gorm:"type:varchar(2);not null;default:'=';comment:比较运算符" json:"operator"`
+	Expected   string          `gorm:"type:varchar(1024);not null;default:'';comment:期望值" json:"expected"`
+	Status     models.EnumType `gorm:"type:ENUM('待执行', '通过', '失败', '错误');default:'待执行';comment:校验状态" json:"status"`
+	Actual     string          `gorm:"type:varchar(1024);not null;default:'';comment:实际值" json:"actual"`
+	Error      string          `gorm:"type:varchar(1024);not null;default:'';comment:执行错误" json:"error"`
+	ExecutedAt *time.Time      `gorm:"type:datetime;null;default:null;comment:执行时间" json:"executed_at"`
+}
+
+func (InsightOrderVerifications) TableName() string {
+	return "insight_order_verifications"
+}
+
+// 工单的试运行记录，多目标工单对每个目标分别试运行
+type InsightOrderDryRuns struct {
+	*models.Model
+	OrderID    uuid.UUID       `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	Revision   int             `gorm:"type:int;not null;default:1;comment:试运行的工单内容版本" json:"revision"`
+	InstanceID uuid.UUID       `gorm:"type:char(36);comment:关联insight_db_config的instance_id" json:"instance_id"`
+	Schema     string          `gorm:"type:varchar(128);not null;default:'';comment:库名" json:"schema"`
+	Username   string          `gorm:"type:varchar(32);not null;default:'';comment:发起人" json:"username"`
+	Status     models.EnumType `gorm:"type:ENUM('执行中', '成功', '失败');default:'执行中';comment:状态" json:"status"`
+	Results    datatypes.JSON  `gorm:"type:json;null;default:null;comment:每条语句的影响行数和错误" json:"results"`
+	ExecuteLog string          `gorm:"type:text;null;comment:执行日志" json:"execute_log"`
+	Error      string          `gorm:"type:varchar(1024);not null;default:'';comment:错误信息" json:"error"`
+}
+
+func (InsightOrderDryRuns) TableName() string {
+	return "insight_order_dry_runs"
+}
+
+// 工单事件的Webhook，事件发生时向URL推送签名的JSON
+type InsightOrderWebhooks struct {
+	*models.Model
+	Name       string         `gorm:"type:varchar(128);not null;default:'';comment:名称" json:"name"`
+	URL        string         `gorm:"type:varchar(1024);not null;default:'';comment:推送地址" json:"url"`
+	Secret     string         `gorm:"type:varchar(256);not null;default:'';comment:签名密钥" json:"secret"`
+	Events     datatypes.JSON `gorm:"type:json;null;default:null;comment:订阅的事件" json:"events"`
+	MaxRetries *int           `gorm:"type:int;not null;default:3;comment:失败后的最大重试次数" json:"max_retries"` // 指针类型，创建时保留0
+	IsEnable   *bool          `gorm:"type:tinyint(1);not null;default:1;comment:是否启用" json:"is_enable"`  // 指针类型，创建时保留false
+	Remark     string         `gorm:"type:varchar(1024);not null;default:'';comment:备注" json:"remark"`
+}
+
+func (InsightOrderWebhooks) TableName() string {
+	return "insight_order_webhooks"
+}
+
+// Webhook的投递记录
+type InsightOrderWebhookDeliveries struct {
+	*models.Model
+	WebhookID    uint64          `gorm:"type:bigint;not null;comment:关联insight_order_webhooks的id;index:idx_webhook_id" json:"webhook_id"`
+	DeliveryID   uuid.UUID       `gorm:"type:char(36);comment:投递ID;uniqueIndex:uniq_delivery_id" json:"delivery_id"`
+	OrderID      uuid.UUID       `gorm:"type:char(36);comment:工单ID;index:idx_order_id" json:"order_id"`
+	Event        string          `gorm:"type:varchar(32);not null;default:'';comment:事件" json:"event"`
+	Payload      datatypes.JSON  `gorm:"type:json;null;default:null;comment:推送内容" json:"payload"`
+	Status       models.EnumType `gorm:"type:ENUM('发送中', '成功', '失败');default:'发送中';comment:状态" json:"status"`
+	Attempts     int             `gorm:"type:int;not null;default:0;comment:已尝试次数" json:"attempts"`
+	ResponseCode int             `gorm:"type:int;not null;default:0;comment:最后一次响应的状态码" json:"response_code"`
+	ResponseBody string          `gorm:"type:varchar(1024);not null;default:'';comment:最后一次响应的内容" json:"response_body"`
+	Error        string          `gorm:"type:varchar(1024);not null;default:'';comment:最后一次错误" json:"error"`
+}
+
+func (InsightOrderWebhookDeliveries) TableName() string {
+	return "insight_order_webhook_deliveries"
+}
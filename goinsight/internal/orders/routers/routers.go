@@ -3,11 +3,28 @@ package routers
 import (
 	"goInsight/global"
 	"goInsight/internal/orders/views"
+	userModels "goInsight/internal/users/models"
+	"goInsight/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 使用访问令牌认证的工单API，供CI等系统创建和跟踪工单
+func OpenRoutes(r *gin.Engine) {
+	open := r.Group("/api/v1/open/orders")
+	{
+		open.POST("commit", middleware.AccessTokenMiddleware(userModels.ScopeCreateOrder), views.CreateOrdersView)
+		open.POST("hook", middleware.AccessTokenMiddleware(userModels.ScopeCreateOrder), views.HookOrdersView)
+		open.GET("detail/:order_id", middleware.AccessTokenMiddleware(userModels.ScopeReadOrder), views.GetDetailView)
+		open.GET("detail/oplogs", middleware.AccessTokenMiddleware(userModels.ScopeReadOrder), views.GetOpLogsView)
+		open.GET("tasks/:order_id", middleware.AccessTokenMiddleware(userModels.ScopeReadOrder), views.GetTasksView)
+		open.POST("tasks/execute-single", middleware.AccessTokenMiddleware(userModels.ScopeExecuteOrder), views.ExecuteSingleTaskView)
+		open.POST("tasks/execute-all", middleware.AccessTokenMiddleware(userModels.ScopeExecuteOrder), views.ExecuteAllTaskView)
+	}
+}
+
 func Routers(r *gin.Engine) {
+	OpenRoutes(r)
 	r.GET("/ws/:channel", views.WebSocketHandler)
 	v1 := r.Group("/api/v1/orders")
 	v1.Use(global.App.JWT.MiddlewareFunc())
@@ -18,20 +35,89 @@ func Routers(r *gin.Engine) {
 		v1.GET("schemas", views.GetSchemasView)
 		v1.GET("users", views.GetUsersView)
 		v1.POST("commit", views.CreateOrdersView)
+		v1.POST("commit/migration", views.CreateMigrationOrderView)
 		v1.GET("list", views.GetListView)
 		v1.GET("detail/:order_id", views.GetDetailView)
 		v1.GET("detail/oplogs", views.GetOpLogsView)
+		v1.GET("detail/comments", views.GetOrderCommentsView)
+		v1.POST("detail/comments", views.CreateOrderCommentView)
+		v1.PUT("detail/comments/:id", views.UpdateOrderCommentView)
+		v1.DELETE("detail/comments/:id", views.DeleteOrderCommentView)
 		v1.PUT("operate/approve", views.ApproveView)
 		v1.PUT("operate/feedback", views.FeedbackView)
 		v1.PUT("operate/review", views.ReviewView)
 		v1.PUT("operate/close", views.CloseView)
 		v1.PUT("operate/update-schedule", views.UpdateScheduleView)
+		v1.PUT("operate/revise", views.ReviseOrderView)
+		v1.GET("detail/revisions", views.GetOrderRevisionsView)
+		v1.GET("detail/revisions/diff", views.GetOrderRevisionDiffView)
+		v1.GET("verifications", views.GetVerificationsView)
+		v1.PUT("verifications", views.UpdateVerificationsView)
+		v1.POST("verifications/run", views.RunVerificationsView)
+		v1.GET("dry-runs", views.GetDryRunsView)
+		v1.POST("dry-runs", views.StartDryRunView)
 		v1.POST("hook", views.HookOrdersView)
+		v1.POST("rollback", views.CreateRollbackOrderView)
+		v1.GET("rollback/:order_id", views.GetRollbackOrdersView)
 		v1.POST("generate-tasks", views.GenerateTasksView)
 		v1.GET("tasks/:order_id", views.GetTasksView)
 		v1.GET("tasks/preview", views.PreviewTasksView)
+		v1.GET("tasks/targets", views.GetTaskTargetsView)
 		v1.POST("tasks/execute-single", views.ExecuteSingleTaskView)
 		v1.POST("tasks/execute-all", views.ExecuteAllTaskView)
+		v1.POST("tasks/pause", views.PauseTasksView)
+		v1.POST("tasks/resume", views.ResumeTasksView)
+		v1.POST("tasks/cancel", views.CancelTaskView)
+		v1.POST("tasks/ghost/control", views.GhostControlView)
+		v1.GET("tasks/ghost/progress", views.GetGhostProgressView)
 		v1.GET("download/exportfile/:task_id", views.DownloadExportFileView)
+		v1.GET("templates", views.GetOrderTemplatesView)
+		v1.POST("templates", views.CreateOrderTemplateView)
+		v1.PUT("templates/:id", views.UpdateOrderTemplateView)
+		v1.DELETE("templates/:id", views.DeleteOrderTemplateView)
+		v1.GET("snippets", views.GetSQLSnippetsView)
+		v1.POST("snippets", views.CreateSQLSnippetView)
+		v1.PUT("snippets/:id", views.UpdateSQLSnippetView)
+		v1.DELETE("snippets/:id", views.DeleteSQLSnippetView)
+		v1.POST("snippets/render", views.RenderSQLSnippetView)
+	}
+	// 闪回仅允许管理员操作
+	flashback := v1.Group("flashback")
+	flashback.Use(middleware.HasAdminPermission())
+	{
+		flashback.POST("", views.CreateFlashbackView)
+		flashback.GET("", views.GetFlashbackListView)
+		flashback.POST("order", views.CreateFlashbackOrderView)
+		flashback.GET("download/:flashback_id", views.DownloadFlashbackFileView)
+	}
+	// 脱敏规则仅允许管理员维护
+	masking := v1.Group("masking-rules")
+	masking.Use(middleware.HasAdminPermission())
+	{
+		masking.GET("", views.GetMaskingRulesView)
+		masking.POST("", views.CreateMaskingRuleView)
+		masking.PUT(":id", views.UpdateMaskingRuleView)
+		masking.DELETE(":id", views.DeleteMaskingRuleView)
+	}
+	// 工单统计仅允许管理员查看
+	statistics := v1.Group("statistics")
+	statistics.Use(middleware.HasAdminPermission())
+	{
+		statistics.GET("orders", views.OrderStatisticsView)
+		statistics.GET("approval-latency", views.ApprovalLatencyStatisticsView)
+		statistics.GET("execution", views.ExecutionStatisticsView)
+		statistics.GET("tables", views.TableStatisticsView)
+		statistics.GET("rollbacks", views.RollbackStatisticsView)
+	}
+	// 工单事件的Webhook仅允许管理员维护
+	webhooks := v1.Group("webhooks")
+	webhooks.Use(middleware.HasAdminPermission())
+	{
+		webhooks.GET("", views.GetWebhooksView)
+		webhooks.POST("", views.CreateWebhookView)
+		webhooks.PUT(":id", views.UpdateWebhookView)
+		webhooks.DELETE(":id", views.DeleteWebhookView)
+		webhooks.GET("deliveries", views.GetWebhookDeliveriesView)
+		webhooks.POST("deliveries/:id/redeliver", views.RedeliverWebhookView)
 	}
 }
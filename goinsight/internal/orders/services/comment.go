@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/forms"
+	ordersModels "goInsight/internal/orders/models"
+	"goInsight/pkg/notifier"
+	"goInsight/pkg/utils"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 提及格式：@username，前面不能是字母数字，避免匹配邮箱地址
+var mentionRegexp = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@])@([A-Za-z0-9_.\-]{1,32})`)
+
+// 解析评论中提及的用户，按首次出现的顺序去重，去掉句末的标点，例如"@bob."
+func parseMentions(content string) []string {
+	var users []string
+	for _, m := range mentionRegexp.FindAllStringSubmatch(content, -1) {
+		user := strings.TrimRight(m[1], ".-")
+		if user != "" && !utils.IsContain(users, user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// 解析工单的审核人、复核人，兼容执行人和抄送人的用户名数组格式
+func jsonUsers(data []byte) (users []string) {
+	var entries []interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		switch v := entry.(type) {
+		case string:
+			users = append(users, v)
+		case map[string]interface{}:
+			if user, ok := v["user"].(string); ok {
+				users = append(users, user)
+			}
+		}
+	}
+	return users
+}
+
+// 工单相关人员：申请人、审核人、执行人、复核人和抄送人
+func orderParticipants(record ordersModels.InsightOrderRecords) []string {
+	users := []string{record.Applicant}
+	for _, data := range [][]byte{record.Approver, record.Executor, record.Reviewer, record.CC} {
+		users = append(users, jsonUsers(data)...)
+	}
+	return utils.RemoveDuplicate(users)
+}
+
+// 限制访问的工单仅相关人员和管理员可以查看评论和版本
+func getAccessibleOrder(orderID, username string) (record ordersModels.InsightOrderRecords, err error) {
+	tx := global.App.DB.Model(&ordersModels.InsightOrderRecords{}).Where("order_id=?", orderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return record, fmt.Errorf("记录`%s`不存在", orderID)
+	}
+	if record.IsRestrictAccess && !utils.IsContain(orderParticipants(record), username) {
+		var isSuperuser bool
+		global.App.DB.Table("insight_users").Select("is_superuser").Where("username=?", username).Scan(&isSuperuser)
+		if !isSuperuser {
+			return record, errors.New("您没有权限查看当前工单")
+		}
+	}
+	return record, nil
+}
+
+type GetOrderCommentsService struct {
+	*forms.GetOrderCommentsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetOrderCommentsService) Run() (responseData interface{}, err error) {
+	if _, err := getAccessibleOrder(s.OrderID, s.Username); err != nil {
+		return nil, err
+	}
+	type comment struct {
+		ordersModels.InsightOrderComments
+		NickName string `json:"nick_name"`
+		SQL      string `json:"sql"`
+	}
+	var comments []comment
+	tx := global.App.DB.Table("`insight_order_comments` a").
+		Select("a.*, b.nick_name, c.`sql`").
+		Joins("left join insight_users b on a.username=b.username").
+		Joins("left join insight_order_tasks c on a.task_id=c.task_id and c.task_id<>?", uuid.Nil).
+		Where("a.order_id=?", s.OrderID).
+		Order("a.created_at asc, a.id asc")
+	if s.TaskID != "" {
+		tx = tx.Where("a.task_id=?", s.TaskID)
+	}
+	tx.Scan(&comments)
+	return comments, nil
+}
+
+type CreateOrderCommentService struct {
+	*forms.CreateOrderCommentForm
+	C        *gin.Context
+	Username string
+}
+
+// 检查提及的用户，限制访问的工单只能提及工单相关人员
+func (s *CreateOrderCommentService) checkMentions(record ordersModels.InsightOrderRecords, mentions []string) ([]string, error) {
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+	var users []string
+	global.App.DB.Table("insight_users").Where("username in ? and is_active=1", mentions).Pluck("username", &users)
+	if record.IsRestrictAccess {
+		participants := orderParticipants(record)
+		for _, user := range users {
+			if !utils.IsContain(participants, user) {
+				return nil, fmt.Errorf("当前工单限制访问，不能提及非工单相关人员`%s`", user)
+			}
+		}
+	}
+	return users, nil
+}
+
+func (s *CreateOrderCommentService) Run() error {
+	record, err := getAccessibleOrder(s.OrderID, s.Username)
+	if err != nil {
+		return err
+	}
+	comment := ordersModels.InsightOrderComments{
+		OrderID:  record.OrderID,
+		ParentID: s.ParentID,
+		Username: s.Username,
+		Content:  s.Content,
+	}
+	// 关联到具体的语句
+	var task ordersModels.InsightOrderTasks
+	if s.TaskID != "" {
+		tx := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).Where("task_id=? and order_id=?", s.TaskID, s.OrderID).Take(&task)
+		if tx.RowsAffected == 0 {
+			return fmt.Errorf("任务`%s`不属于当前工单", s.TaskID)
+		}
+		comment.TaskID = task.TaskID
+	}
+	// 回复评论，发送给被回复的评论人
+	var receivers []string
+	if s.ParentID > 0 {
+		var parent ordersModels.InsightOrderComments
+		tx := global.App.DB.Model(&ordersModels.InsightOrderComments{}).Where("id=? and order_id=?", s.ParentID, s.OrderID).Take(&parent)
+		if tx.RowsAffected == 0 {
+			return fmt.Errorf("回复的评论`%d`不存在", s.ParentID)
+		}
+		receivers = append(receivers, parent.Username)
+	}
+	mentions, err := s.checkMentions(record, parseMentions(s.Content))
+	if err != nil {
+		return err
+	}
+	if len(mentions) > 0 {
+		data, _ := json.Marshal(mentions)
+		comment.Mentions = data
+	}
+	// 评论记录到工单的操作日志，不记录评论内容
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		logMsg := fmt.Sprintf("用户%s发表了评论#%d", s.Username, comment.ID)
+		if s.ParentID > 0 {
+			logMsg = fmt.Sprintf("用户%s回复了评论#%d，评论#%d", s.Username, s.ParentID, comment.ID)
+		}
+		if task.SQL != "" {
+			logMsg += fmt.Sprintf("，关联任务%s", task.TaskID.String())
+		}
+		return CreateOpLogs(tx, record.OrderID, s.Username, logMsg)
+	}); err != nil {
+		return err
+	}
+	// 发送消息，不发送给自己
+	receivers = utils.RemoveElements(utils.RemoveDuplicate(append(receivers, mentions...)), []string{s.Username})
+	if len(receivers) == 0 {
+		return nil
+	}
+	msg := fmt.Sprintf("您好，用户%s在工单中提到了您\n>工单标题：%s", s.Username, record.Title)
+	if task.SQL != "" {
+		sql := []rune(task.SQL)
+		if len(sql) > 256 {
+			sql = append(sql[:256], []rune("...")...)
+		}
+		msg += fmt.Sprintf("\n>关联语句：%s", string(sql))
+	}
+	msg += fmt.Sprintf("\n>评论内容：%s", s.Content)
+	notifier.SendMessage(record.Title, record.OrderID.String(), receivers, msg)
+	return nil
+}
+
+type UpdateOrderCommentService struct {
+	*forms.UpdateOrderCommentForm
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *UpdateOrderCommentService) Run() error {
+	// 仅评论人可以修改，修改时不重新发送提及消息
+	var comment ordersModels.InsightOrderComments
+	tx := global.App.DB.Model(&ordersModels.InsightOrderComments{}).Where("id=? and username=?", s.ID, s.Username).Take(&comment)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("评论`%d`不存在或不是当前用户发表的", s.ID)
+	}
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ordersModels.InsightOrderComments{}).Where("id=?", s.ID).Update("content", s.Content).Error; err != nil {
+			return err
+		}
+		return CreateOpLogs(tx, comment.OrderID, s.Username, fmt.Sprintf("用户%s修改了评论#%d", s.Username, s.ID))
+	})
+}
+
+type DeleteOrderCommentService struct {
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *DeleteOrderCommentService) Run() error {
+	var comment ordersModels.InsightOrderComments
+	tx := global.App.DB.Model(&ordersModels.InsightOrderComments{}).Where("id=? and username=?", s.ID, s.Username).Take(&comment)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("评论`%d`不存在或不是当前用户发表的", s.ID)
+	}
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id=?", s.ID).Delete(&ordersModels.InsightOrderComments{}).Error; err != nil {
+			return err
+		}
+		// 回复保留在讨论中
+		if err := tx.Model(&ordersModels.InsightOrderComments{}).Where("parent_id=?", s.ID).Update("parent_id", 0).Error; err != nil {
+			return err
+		}
+		return CreateOpLogs(tx, comment.OrderID, s.Username, fmt.Sprintf("用户%s删除了评论#%d", s.Username, s.ID))
+	})
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"@alice 请确认", []string{"alice"}},
+		{"请@bob.看一下，@carol-", []string{"bob", "carol"}},
+		{"@dan.wu 和 @dan.wu 重复提及", []string{"dan.wu"}},
+		{"邮箱alice@example.com不是提及", nil},
+		{"只有@.", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseMentions(tt.content), tt.content)
+	}
+}
+
+func TestJSONUsers(t *testing.T) {
+	// 审核人和复核人为对象数组，执行人和抄送人为用户名数组
+	assert.Equal(t, []string{"alice", "bob"}, jsonUsers([]byte(`[{"user":"alice","status":"pending"},{"user":"bob"}]`)))
+	assert.Equal(t, []string{"carol", "dan"}, jsonUsers([]byte(`["carol","dan"]`)))
+	assert.Nil(t, jsonUsers(nil))
+}
@@ -18,9 +18,9 @@ import (
 	"goInsight/pkg/notifier"
 	"goInsight/pkg/pagination"
 	"goInsight/pkg/parser"
-	"goInsight/pkg/utils"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-sql-driver/mysql"
@@ -51,17 +51,7 @@ type GetInstancesService struct {
 
 func (s *GetInstancesService) Run() (responseData interface{}, total int64, err error) {
 	// 获取当前用户当前绑定组织和所有上级组织
-	var organization usersModels.InsightOrganizations
-	global.App.DB.Table("`insight_organizations` a").
-		Joins("join insight_organizations_users b on a.key = b.organization_key").
-		Joins("join insight_users c on c.uid = b.uid").Where("c.username=?", s.Username).Scan(&organization)
-
-	// 将path json数据转换为数组
-	var pathJsonArray []string
-	if len(organization.Path) != 0 {
-		_ = json.Unmarshal([]byte(organization.Path), &pathJsonArray)
-	}
-	pathJsonArray = append(pathJsonArray, organization.Key)
+	_, pathJsonArray := getUserOrganizationKeys(s.Username)
 
 	// 获取当前用户当前绑定组织和所有上级组织绑定的实例
 	var instances []commonModels.InsightDBConfig
@@ -100,9 +90,13 @@ func (s *GetUsersService) Run() (responseData interface{}, total int64, err erro
 // 提交工单
 type CreateOrdersService struct {
 	*forms.CreateOrderForm
-	C        *gin.Context
-	Username string
-	Audit    *parser.TiStmt
+	C               *gin.Context
+	Username        string
+	Audit           *parser.TiStmt
+	RollbackOrderID uuid.UUID // 回滚工单关联的源工单ID
+	OrderID         uuid.UUID // 提交成功后生成的工单ID
+	// 在创建工单的事务中执行，用于写入工单关联的记录
+	AfterCreate func(tx *gorm.DB, record models.InsightOrderRecords) error
 }
 
 // 转json
@@ -122,7 +116,7 @@ func (s *CreateOrdersService) toJson(values []string) (datatypes.JSON, error) {
 }
 
 // 审核SQL
-func (s *CreateOrdersService) inspectSQL(config commonModels.InsightDBConfig) ([]checker.ReturnData, error) {
+func (s *CreateOrdersService) inspectSQL(config commonModels.InsightDBConfig, schema string) ([]checker.ReturnData, error) {
 	inspect := checker.SyntaxInspectService{
 		C:          s.C,
 		DbUser:     config.UserName,
@@ -130,13 +124,57 @@ func (s *CreateOrdersService) inspectSQL(config commonModels.InsightDBConfig) ([
 		DbHost:     config.Hostname,
 		DbPort:     config.Port,
 		DBParams:   config.InspectParams,
-		DBSchema:   s.Schema,
+		DBSchema:   schema,
 		Username:   s.Username,
 		SqlText:    s.Content,
 	}
 	return inspect.Run()
 }
 
+// 判断SQL类型是否匹配，DML工单仅允许提交DML语句，DDL工单仅允许提交DDL语句
+func (s *CreateOrdersService) checkSQLType() error {
+	if s.DBType == "ClickHouse" {
+		// tidb parser无法解析ClickHouse语法，同时检查了SQL条数
+		return parser.CheckClickHouseSqlType(s.Content, string(s.SQLType))
+	}
+	if err := parser.CheckSqlType(s.Content, string(s.SQLType)); err != nil {
+		return err
+	}
+	// 判断SQL条数
+	return parser.CheckMaxAllowedSQLNums(s.Content)
+}
+
+// 检查DDL/DML工单语法检查是否通过，多目标工单对每个目标分别检查
+// 不对EXPORT工单进行语法检查，CheckSqlType已经要求EXPORT工单只能为SELECT语句
+// clickhouse不审核
+func (s *CreateOrdersService) inspectTargets(targets []models.OrderTarget, configs map[uuid.UUID]commonModels.InsightDBConfig) error {
+	if s.SQLType == "EXPORT" || s.DBType == "ClickHouse" {
+		return nil
+	}
+	for _, target := range targets {
+		config := configs[target.InstanceID]
+		returnData, err := s.inspectSQL(config, target.Schema)
+		if err != nil {
+			return err
+		}
+		// status: 0表示语法检查通过，1表示语法检查不通过
+		status := 0
+		for _, row := range returnData {
+			if row.Level != "INFO" {
+				status = 1
+				break
+			}
+		}
+		if status == 1 {
+			if len(targets) > 1 {
+				return fmt.Errorf("目标%s:%d/%s的SQL语法检查不通过，请先执行【语法检查】", config.Hostname, config.Port, target.Schema)
+			}
+			return fmt.Errorf("SQL语法检查不通过，请先执行【语法检查】")
+		}
+	}
+	return nil
+}
+
 // 获取用户组织，没有返回空值
 func (s *CreateOrdersService) getUserOrg() (organization string) {
 	type org struct {
@@ -181,45 +219,56 @@ func (s *CreateOrdersService) getUserOrg() (organization string) {
 }
 
 func (s *CreateOrdersService) Run() error {
-	// 判断SQL类型是否匹配，DML工单仅允许提交DML语句，DDL工单仅允许提交DDL语句
-	err := parser.CheckSqlType(s.Content, string(s.SQLType))
-	if err != nil {
-		return err
-	}
-	// 判断SQL条数
-	err = parser.CheckMaxAllowedSQLNums(s.Content)
-	if err != nil {
-		return err
-	}
-	// 获取实例配置
-	var config commonModels.InsightDBConfig
-	global.App.DB.Table("`insight_db_config`").
-		Where("instance_id=?", s.InstanceID).
-		First(&config)
-	// 检查DDL/DML工单语法检查是否通过
-	// 不对EXPORT工单进行语法检查，CheckSqlType已经要求EXPORT工单只能为SELECT语句
-	if s.SQLType != "EXPORT" {
-		returnData, err := s.inspectSQL(config)
+	// 使用SQL片段时，校验参数并替换占位符
+	if s.SnippetID != 0 {
+		content, err := contentFromSnippet(s.Username, s.SnippetID, s.SnippetParams, s.DBType, s.SQLType)
 		if err != nil {
 			return err
 		}
-		// status: 0表示语法检查通过，1表示语法检查不通过
-		status := 0
-		for _, row := range returnData {
-			if row.Level != "INFO" {
-				status = 1
-				break
-			}
+		s.Content = content
+	}
+	if err := s.checkSQLType(); err != nil {
+		return err
+	}
+	// CSV分隔符只能为单个字符
+	if s.CSVDelimiter != "" && utf8.RuneCountInString(s.CSVDelimiter) != 1 {
+		return fmt.Errorf("CSV分隔符只能为单个字符")
+	}
+	csvEncoding := s.CSVEncoding
+	if csvEncoding == "" {
+		csvEncoding = "UTF8"
+	}
+	// 事务模式仅支持MySQL DML工单，且不能和分批执行同时开启
+	if s.IsTransaction {
+		if s.SQLType != "DML" || s.DBType != "MySQL" {
+			return fmt.Errorf("事务模式仅支持MySQL DML工单")
 		}
-		if status == 1 {
-			return fmt.Errorf("SQL语法检查不通过，请先执行【语法检查】")
+		if s.IsBatchExecute {
+			return fmt.Errorf("事务模式不支持分批执行")
 		}
 	}
-	// 解析UUID
-	instance_id, err := utils.ParserUUID(s.InstanceID)
+	// 解析执行目标
+	targets, configs, err := s.resolveTargets()
 	if err != nil {
 		return err
 	}
+	if s.IsTransaction && len(targets) > 1 {
+		return fmt.Errorf("事务模式不支持多目标工单")
+	}
+	if err := s.inspectTargets(targets, configs); err != nil {
+		return err
+	}
+	// 多目标工单记录展开后的目标，工单的实例和库使用第一个目标
+	var targetsData datatypes.JSON
+	if len(s.Targets) > 0 {
+		data, err := json.Marshal(targets)
+		if err != nil {
+			return err
+		}
+		targetsData = datatypes.JSON(data)
+	}
+	instance_id := targets[0].InstanceID
+	schema := targets[0].Schema
 	// 解析为json格式
 	approver, err := s.toJson(s.Approver)
 	if err != nil {
@@ -250,11 +299,13 @@ func (s *CreateOrdersService) Run() error {
 	}
 	// 生成工单ID
 	orderID := uuid.New()
+	s.OrderID = orderID
 	// Title加上时间
 	// timeStr := time.Now().Format("2006-01-02 15:04:05")
 	title := s.Title
 	record := models.InsightOrderRecords{
 		Title:            title,
+		Progress:         "待审核",
 		OrderID:          orderID,
 		Remark:           s.Remark,
 		IsRestrictAccess: *s.IsRestrictAccess,
@@ -262,7 +313,7 @@ func (s *CreateOrdersService) Run() error {
 		DBType:           s.DBType,
 		Environment:      s.Environment,
 		InstanceID:       instance_id,
-		Schema:           s.Schema,
+		Schema:           schema,
 		Applicant:        s.Username,
 		Organization:     s.getUserOrg(),
 		Approver:         approver,
@@ -272,8 +323,20 @@ func (s *CreateOrdersService) Run() error {
 		Content:          s.Content,
 		ScheduleTime:     scheduleTime,
 		ExportFileFormat: s.ExportFileFormat,
+		CSVDelimiter:     s.CSVDelimiter,
+		CSVEncoding:      csvEncoding,
+		CSVWithBOM:       s.CSVWithBOM,
+		IsBatchExecute:   s.IsBatchExecute,
+		IsTransaction:    s.IsTransaction,
+		RollbackOrderID:  s.RollbackOrderID,
+		Targets:          targetsData,
+	}
+	// 执行完成后运行的校验查询
+	verifications, err := newVerifications(record, s.Verifications)
+	if err != nil {
+		return err
 	}
-	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.InsightOrderRecords{}).Create(&record).Error; err != nil {
 			mysqlErr := err.(*mysql.MySQLError)
 			switch mysqlErr.Number {
@@ -293,6 +356,22 @@ func (s *CreateOrdersService) Run() error {
 			global.App.Log.Error(err)
 			return err
 		}
+		if len(verifications) > 0 {
+			if err := tx.CreateInBatches(&verifications, 500).Error; err != nil {
+				return err
+			}
+		}
+		if s.AfterCreate != nil {
+			if err := s.AfterCreate(tx, record); err != nil {
+				return err
+			}
+		}
+		// 源工单记录回滚工单
+		if s.RollbackOrderID != uuid.Nil {
+			if err := CreateOpLogs(tx, s.RollbackOrderID, s.Username, fmt.Sprintf("用户%s创建了回滚工单：%s", s.Username, title)); err != nil {
+				return err
+			}
+		}
 		// 获取提交的环境
 		var env commonModels.InsightDBEnvironments
 		global.App.DB.Table("`insight_db_environments` a").
@@ -323,5 +402,9 @@ func (s *CreateOrdersService) Run() error {
 
 		notifier.SendMessage(title, record.OrderID.String(), receiver, msg)
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+	dispatchOrderEvent(record, EventCreated, s.Username, s.Remark)
+	return nil
 }
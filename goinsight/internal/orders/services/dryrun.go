@@ -0,0 +1,180 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/mysql"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/notifier"
+	"goInsight/pkg/parser"
+	"goInsight/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 发起试运行
+type StartDryRunService struct {
+	*forms.StartDryRunForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *StartDryRunService) Run() error {
+	var record models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	if record.DBType == "ClickHouse" || record.SQLType == "EXPORT" {
+		return errors.New("试运行仅支持MySQL和TiDB的DML、DDL工单")
+	}
+	if !utils.IsContain([]string{"待审核", "已驳回", "已批准"}, string(record.Progress)) {
+		return fmt.Errorf("工单当前状态为%s，仅执行前的工单可以试运行", record.Progress)
+	}
+	if !utils.IsContain(orderParticipants(record), s.Username) {
+		return errors.New("您没有当前工单的试运行权限")
+	}
+	// 同一个工单同时只允许一个试运行，超时的记录视为已结束
+	var count int64
+	global.App.DB.Model(&models.InsightOrderDryRuns{}).
+		Where("order_id=? and status='执行中' and created_at>?", record.OrderID, time.Now().Add(-15*time.Minute)).
+		Count(&count)
+	if count > 0 {
+		return errors.New("当前工单有正在执行的试运行，请等待执行完成")
+	}
+	sqls, err := parser.SplitSQLText(record.Content)
+	if err != nil {
+		return err
+	}
+	targets, err := getOrderTargets(record)
+	if err != nil {
+		return err
+	}
+	var dryRuns []models.InsightOrderDryRuns
+	for _, target := range targets {
+		dryRuns = append(dryRuns, models.InsightOrderDryRuns{
+			OrderID:    record.OrderID,
+			Revision:   record.Revision,
+			InstanceID: target.InstanceID,
+			Schema:     target.Schema,
+			Username:   s.Username,
+			Status:     "执行中",
+		})
+	}
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&dryRuns).Error; err != nil {
+			return err
+		}
+		return CreateOpLogs(tx, record.OrderID, s.Username, fmt.Sprintf("用户%s发起了试运行，版本：%d", s.Username, record.Revision))
+	}); err != nil {
+		return err
+	}
+	go runDryRuns(record, dryRuns, sqls, s.Username)
+	return nil
+}
+
+// 依次试运行每个目标，完成后通知申请人和审核人
+func runDryRuns(record models.InsightOrderRecords, dryRuns []models.InsightOrderDryRuns, sqls []string, username string) {
+	var failCount int
+	for _, dryRun := range dryRuns {
+		status, err := runDryRun(record, dryRun, sqls)
+		if err != nil {
+			global.App.Log.Error(err)
+		}
+		if status != "成功" {
+			failCount++
+		}
+	}
+	result := fmt.Sprintf("试运行完成，成功：%d，失败：%d", len(dryRuns)-failCount, failCount)
+	if err := CreateOpLogs(global.App.DB, record.OrderID, username, result); err != nil {
+		global.App.Log.Error(err)
+	}
+	receiver := []string{record.Applicant, username}
+	receiver = append(receiver, jsonUsers(record.Approver)...)
+	msg := fmt.Sprintf("您好，用户%s发起的%s\n>工单标题：%s\n>版本：%d", username, result, record.Title, record.Revision)
+	notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
+}
+
+func runDryRun(record models.InsightOrderRecords, dryRun models.InsightOrderDryRuns, sqls []string) (status string, err error) {
+	update := func(values map[string]interface{}) error {
+		return global.App.DB.Model(&models.InsightOrderDryRuns{}).Where("id=?", dryRun.ID).Updates(values).Error
+	}
+	var config struct {
+		Hostname string
+		Port     uint16
+		UserName string
+		Password string
+	}
+	tx := global.App.DB.Table("`insight_db_config`").
+		Select("hostname, port, user_name, password").
+		Where("instance_id=?", dryRun.InstanceID).
+		Take(&config)
+	if tx.RowsAffected == 0 {
+		return "失败", update(map[string]interface{}{"status": "失败", "error": fmt.Sprintf("实例`%s`不存在", dryRun.InstanceID)})
+	}
+	executor := mysql.ExecuteMySQLDryRun{
+		DBConfig: &base.DBConfig{
+			Hostname: config.Hostname,
+			Port:     config.Port,
+			UserName: config.UserName,
+			Password: config.Password,
+			Schema:   dryRun.Schema,
+			DBType:   string(record.DBType),
+			SQLType:  string(record.SQLType),
+			OrderID:  record.OrderID.String(),
+		},
+		Statements: sqls,
+	}
+	results, executeLog, runErr := executor.Run()
+	status = "成功"
+	var errMsg string
+	if runErr != nil {
+		status, errMsg = "失败", runErr.Error()
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			status = "失败"
+		}
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return status, err
+	}
+	return status, update(map[string]interface{}{
+		"status":      status,
+		"results":     string(data),
+		"execute_log": executeLog,
+		"error":       errMsg,
+	})
+}
+
+// 获取工单的试运行记录
+type GetDryRunsService struct {
+	*forms.GetDryRunsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetDryRunsService) Run() (responseData interface{}, err error) {
+	if _, err := getAccessibleOrder(s.OrderID, s.Username); err != nil {
+		return nil, err
+	}
+	type dryRun struct {
+		models.InsightOrderDryRuns
+		Instance string `json:"instance"`
+	}
+	var dryRuns []dryRun
+	global.App.DB.Table("`insight_order_dry_runs` a").
+		Select("a.*, concat(b.hostname, ':', b.port) as instance").
+		Joins("left join insight_db_config b on a.instance_id=b.instance_id").
+		Where("a.order_id=?", s.OrderID).
+		Order("a.id desc").
+		Scan(&dryRuns)
+	return dryRuns, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	ordersModels "goInsight/internal/orders/models"
+	"goInsight/pkg/storage"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 将执行器生成的导出文件保存到配置的存储中，并记录文件的过期时间和下载次数限制
+func storeExportFile(config *base.DBConfig, data *base.ReturnData) error {
+	cfg := global.App.Config.Export
+	store, err := storage.New(cfg)
+	if err != nil {
+		return err
+	}
+	key := data.FileName
+	// 本地存储目录和执行器的输出目录相同时不需要复制
+	local, isLocal := store.(*storage.LocalStorage)
+	if !isLocal || filepath.Clean(filepath.Join(local.Dir, key)) != filepath.Clean(data.FilePath) {
+		f, err := os.Open(data.FilePath)
+		if err != nil {
+			return err
+		}
+		err = store.Put(context.Background(), key, f, data.FileSize)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("保存导出文件到%s存储失败：%s", store.Name(), err.Error())
+		}
+		os.Remove(data.FilePath)
+		data.FilePath = ""
+		if isLocal {
+			data.FilePath = filepath.Join(local.Dir, key)
+		}
+	}
+
+	record := ordersModels.InsightExportFiles{
+		FileName:     data.FileName,
+		FileSize:     data.FileSize,
+		Storage:      store.Name(),
+		ObjectKey:    key,
+		MaxDownloads: cfg.MaxDownloads,
+		Status:       "有效",
+	}
+	record.OrderID, _ = uuid.Parse(config.OrderID)
+	record.TaskID, _ = uuid.Parse(config.TaskID)
+	if cfg.RetentionDays > 0 {
+		expireAt := time.Now().AddDate(0, 0, cfg.RetentionDays)
+		record.ExpireAt = &expireAt
+		data.ExpireAt = expireAt.Format("2006-01-02 15:04:05")
+	}
+	// 重新执行任务时覆盖之前的记录
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id=?", record.TaskID).Delete(&ordersModels.InsightExportFiles{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+}
+
+// ExportDownload 下载的导出文件
+type ExportDownload struct {
+	Reader   io.ReadCloser
+	Size     int64
+	FileName string
+}
+
+// 下载导出文件，每次下载都会记录审计日志
+type DownloadExportFileService struct {
+	TaskID   string
+	C        *gin.Context
+	Username string
+}
+
+func (s *DownloadExportFileService) audit(taskID uuid.UUID, err error) {
+	log := ordersModels.InsightExportDownloadLogs{
+		TaskID:    taskID,
+		Username:  s.Username,
+		ClientIP:  s.C.ClientIP(),
+		UserAgent: s.C.Request.UserAgent(),
+		IsSuccess: err == nil,
+	}
+	if err != nil {
+		log.Msg = err.Error()
+	}
+	if len(log.UserAgent) > 512 {
+		log.UserAgent = log.UserAgent[:512]
+	}
+	if err := global.App.DB.Create(&log).Error; err != nil {
+		global.App.Log.Error(err)
+	}
+}
+
+// Run 返回下载的文件，失败时返回对应的HTTP状态码
+func (s *DownloadExportFileService) Run() (file *ExportDownload, status int, err error) {
+	var task ordersModels.InsightOrderTasks
+	tx := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).Where("task_id=?", s.TaskID).Scan(&task)
+	if tx.RowsAffected == 0 {
+		return nil, http.StatusNotFound, fmt.Errorf("下载记录关联的任务不存在，任务ID：%s", s.TaskID)
+	}
+	defer func() { s.audit(task.TaskID, err) }()
+
+	// 判断用户是否有权限下载
+	var record ordersModels.InsightOrderRecords
+	global.App.DB.Model(&ordersModels.InsightOrderRecords{}).Where("order_id=?", task.OrderID).Scan(&record)
+	if record.Applicant != s.Username {
+		return nil, http.StatusForbidden, fmt.Errorf("用户%s无权限下载任务%s的导出文件", s.Username, s.TaskID)
+	}
+
+	var exportFile ordersModels.InsightExportFiles
+	tx = global.App.DB.Model(&ordersModels.InsightExportFiles{}).Where("task_id=?", task.TaskID).Take(&exportFile)
+	if tx.RowsAffected == 0 {
+		return s.legacyFile(task)
+	}
+	if exportFile.Status != "有效" || (exportFile.ExpireAt != nil && exportFile.ExpireAt.Before(time.Now())) {
+		return nil, http.StatusGone, errors.New("导出文件已过期")
+	}
+	if exportFile.MaxDownloads > 0 && exportFile.Downloads >= exportFile.MaxDownloads {
+		return nil, http.StatusForbidden, fmt.Errorf("导出文件已达到最大下载次数%d", exportFile.MaxDownloads)
+	}
+
+	// 使用文件保存时的存储类型读取
+	store, err := storage.Open(exportFile.Storage, global.App.Config.Export)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	reader, size, err := store.Get(s.C.Request.Context(), exportFile.ObjectKey)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("读取导出文件%s失败：%s", exportFile.FileName, err.Error())
+	}
+	// 并发下载时通过条件更新保证下载次数不超过限制
+	tx = global.App.DB.Model(&ordersModels.InsightExportFiles{}).
+		Where("task_id=? and (max_downloads=0 or downloads<max_downloads)", exportFile.TaskID).
+		Update("downloads", gorm.Expr("downloads+1"))
+	if tx.RowsAffected == 0 {
+		reader.Close()
+		return nil, http.StatusForbidden, fmt.Errorf("导出文件已达到最大下载次数%d", exportFile.MaxDownloads)
+	}
+	return &ExportDownload{Reader: reader, Size: size, FileName: exportFile.FileName}, http.StatusOK, nil
+}
+
+// 没有导出文件记录的历史任务，直接读取任务结果中的本地文件
+func (s *DownloadExportFileService) legacyFile(task ordersModels.InsightOrderTasks) (*ExportDownload, int, error) {
+	var data base.ExportFile
+	if err := json.Unmarshal([]byte(task.Result), &data); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("解析下载文件信息异常，错误：%s", err.Error())
+	}
+	f, err := os.Open(data.FilePath)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("下载的文件%s不存在", data.FilePath)
+	}
+	return &ExportDownload{Reader: f, Size: data.FileSize, FileName: data.FileName}, http.StatusOK, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	commonModels "goInsight/internal/common/models"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/mysql"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/pagination"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// 单个闪回任务最多生成的闪回SQL条数
+const maxFlashbackStatements = 1000000
+
+// 创建闪回任务，后台解析binlog生成闪回SQL
+type CreateFlashbackService struct {
+	*forms.CreateFlashbackForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *CreateFlashbackService) parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("时间格式错误: %v", err)
+	}
+	return &t, nil
+}
+
+func (s *CreateFlashbackService) Run() (responseData interface{}, err error) {
+	startTime, err := s.parseTime(s.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := s.parseTime(s.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if startTime == nil && endTime == nil && s.StartFile == "" && s.EndFile == "" {
+		return nil, errors.New("请指定时间范围或binlog范围")
+	}
+	if startTime != nil && endTime != nil && !startTime.Before(*endTime) {
+		return nil, errors.New("开始时间必须小于结束时间")
+	}
+	var config commonModels.InsightDBConfig
+	tx := global.App.DB.Table("`insight_db_config`").Where("instance_id=?", s.InstanceID).Take(&config)
+	if tx.RowsAffected == 0 {
+		return nil, fmt.Errorf("实例`%s`不存在", s.InstanceID)
+	}
+	if config.DbType != "MySQL" {
+		return nil, errors.New("闪回仅支持MySQL实例")
+	}
+	toJSON := func(v []string) datatypes.JSON {
+		data, _ := json.Marshal(v)
+		return datatypes.JSON(data)
+	}
+	record := models.InsightFlashbackRecords{
+		FlashbackID:   uuid.New(),
+		Username:      s.Username,
+		InstanceID:    config.InstanceID,
+		Schemas:       toJSON(s.Schemas),
+		Tables:        toJSON(s.Tables),
+		Operations:    toJSON(s.Operations),
+		StartTime:     startTime,
+		EndTime:       endTime,
+		StartFile:     s.StartFile,
+		StartPosition: s.StartPosition,
+		EndFile:       s.EndFile,
+		EndPosition:   s.EndPosition,
+		Progress:      "执行中",
+	}
+	if err := global.App.DB.Model(&models.InsightFlashbackRecords{}).Create(&record).Error; err != nil {
+		global.App.Log.Error(err)
+		return nil, err
+	}
+	flashback := mysql.Flashback{
+		DBConfig: &base.DBConfig{
+			Hostname: config.Hostname,
+			Port:     uint16(config.Port),
+			UserName: config.UserName,
+			Password: config.Password,
+			DBType:   string(config.DbType),
+		},
+		FlashbackID:   record.FlashbackID.String(),
+		StartTime:     startTime,
+		EndTime:       endTime,
+		StartFile:     s.StartFile,
+		StartPosition: s.StartPosition,
+		EndFile:       s.EndFile,
+		EndPosition:   s.EndPosition,
+		Schemas:       s.Schemas,
+		Tables:        s.Tables,
+		Operations:    s.Operations,
+		MaxStatements: maxFlashbackStatements,
+	}
+	// 解析binlog耗时较长，后台执行
+	go runFlashback(flashback, record.FlashbackID)
+	return map[string]string{"flashback_id": record.FlashbackID.String()}, nil
+}
+
+func runFlashback(flashback mysql.Flashback, flashbackID uuid.UUID) {
+	updates := map[string]interface{}{"progress": "已完成"}
+	file, statements, err := flashback.Run()
+	if err != nil {
+		global.App.Log.Error(fmt.Sprintf("闪回任务%s执行失败：%s", flashbackID, err.Error()))
+		updates = map[string]interface{}{"progress": "已失败", "error": err.Error()}
+	} else {
+		updates["file_path"] = file
+		updates["statements"] = statements
+		if info, err := os.Stat(file); err == nil {
+			updates["file_size"] = info.Size()
+		}
+	}
+	global.App.DB.Model(&models.InsightFlashbackRecords{}).Where("flashback_id=?", flashbackID).Updates(updates)
+}
+
+// 获取闪回任务列表
+type GetFlashbackListService struct {
+	*forms.GetFlashbackListForm
+	C *gin.Context
+}
+
+func (s *GetFlashbackListService) Run() (responseData interface{}, total int64, err error) {
+	var records []models.InsightFlashbackRecords
+	tx := global.App.DB.Table("`insight_flashback_records`").Order("created_at desc")
+	if s.InstanceID != "" {
+		tx = tx.Where("instance_id=?", s.InstanceID)
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &records)
+	return &records, total, nil
+}
+
+// 获取已完成的闪回任务
+func getCompletedFlashback(flashbackID string) (*models.InsightFlashbackRecords, error) {
+	var record models.InsightFlashbackRecords
+	tx := global.App.DB.Table("`insight_flashback_records`").Where("flashback_id=?", flashbackID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return nil, fmt.Errorf("闪回任务`%s`不存在", flashbackID)
+	}
+	if record.Progress != "已完成" {
+		return nil, fmt.Errorf("闪回任务的状态为%s，仅已完成的任务可以操作", record.Progress)
+	}
+	if record.Statements == 0 {
+		return nil, errors.New("闪回任务没有生成闪回SQL")
+	}
+	return &record, nil
+}
+
+// 基于闪回SQL创建DML工单
+type CreateFlashbackOrderService struct {
+	*forms.CreateFlashbackOrderForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *CreateFlashbackOrderService) Run() error {
+	record, err := getCompletedFlashback(s.FlashbackID)
+	if err != nil {
+		return err
+	}
+	if record.OrderID != uuid.Nil {
+		return fmt.Errorf("闪回任务已创建工单`%s`", record.OrderID)
+	}
+	content, err := os.ReadFile(record.FilePath)
+	if err != nil {
+		return fmt.Errorf("读取闪回SQL文件失败：%s", err.Error())
+	}
+	var config commonModels.InsightDBConfig
+	tx := global.App.DB.Table("`insight_db_config`").Where("instance_id=?", record.InstanceID).Take(&config)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("实例`%s`不存在", record.InstanceID)
+	}
+	isRestrictAccess := true
+	// 走正常的提交流程，包括语法审核和SQL条数限制
+	service := CreateOrdersService{
+		CreateOrderForm: &forms.CreateOrderForm{
+			Title:            s.Title,
+			Remark:           s.Remark,
+			IsRestrictAccess: &isRestrictAccess,
+			DBType:           "MySQL",
+			SQLType:          "DML",
+			Environment:      config.Environment,
+			InstanceID:       record.InstanceID.String(),
+			Schema:           s.Schema,
+			Approver:         s.Approver,
+			Executor:         s.Executor,
+			Reviewer:         s.Reviewer,
+			CC:               s.CC,
+			Content:          string(content),
+			ExportFileFormat: "XLSX",
+		},
+		C:        s.C,
+		Username: s.Username,
+	}
+	if err := service.Run(); err != nil {
+		return err
+	}
+	return global.App.DB.Model(&models.InsightFlashbackRecords{}).
+		Where("flashback_id=?", record.FlashbackID).
+		Update("order_id", service.OrderID).Error
+}
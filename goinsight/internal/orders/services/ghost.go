@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/api/mysql"
+	"goInsight/internal/orders/forms"
+	ordersModels "goInsight/internal/orders/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 向执行中的gh-ost发送交互命令
+type GhostControlService struct {
+	*forms.GhostControlForm
+	C        *gin.Context
+	Username string
+}
+
+// 确认cut-over需要审核人操作，其他命令需要执行人操作
+func (s *GhostControlService) checkPermission() error {
+	if s.Action != mysql.GhostUnpostpone {
+		return checkOrderStatus(s.OrderID, s.Username)
+	}
+	var record ordersModels.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("工单记录`%s`不存在", s.OrderID)
+	}
+	var approverList []map[string]interface{}
+	if err := json.Unmarshal([]byte(record.Approver), &approverList); err != nil {
+		return err
+	}
+	for _, approver := range approverList {
+		if approver["user"] == s.Username {
+			return nil
+		}
+	}
+	return errors.New("您没有确认cut-over的权限，仅审核人可以操作")
+}
+
+func (s *GhostControlService) Run() (responseData interface{}, err error) {
+	if err := s.checkPermission(); err != nil {
+		return nil, err
+	}
+	command, err := mysql.GhostCommand(s.Action, s.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	var task ordersModels.InsightOrderTasks
+	tx := global.App.DB.Table("`insight_order_tasks`").
+		Where("order_id=? and task_id=? and progress=?", s.OrderID, s.TaskID, "执行中").
+		Take(&task)
+	if tx.RowsAffected == 0 {
+		return nil, errors.New("任务不存在或不是执行中的状态")
+	}
+	// 终止gh-ost前先暂停剩余的任务，避免批量执行继续执行下一个任务
+	if s.Action == mysql.GhostPanic {
+		if err := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
+			Where("order_id=? and progress=?", s.OrderID, "未执行").
+			Update("progress", "已暂停").Error; err != nil {
+			global.App.Log.Error(err)
+			return nil, err
+		}
+	}
+	response, err := base.ControlRunningTask(s.TaskID, command)
+	if err != nil {
+		return nil, fmt.Errorf("发送gh-ost命令失败，错误：%s", err.Error())
+	}
+	// 操作日志
+	logMsg := fmt.Sprintf("用户%s向任务%s发送了gh-ost命令%s，附加消息：%s", s.Username, s.TaskID, command, s.Msg)
+	if err := CreateOpLogs(global.App.DB, task.OrderID, s.Username, logMsg); err != nil {
+		return nil, err
+	}
+	base.PublishMessageToChannel(s.OrderID, logMsg+"\n", "ghost")
+	return response, nil
+}
+
+// 获取执行中任务的gh-ost进度
+type GetGhostProgressService struct {
+	*forms.GetGhostProgressForm
+	C *gin.Context
+}
+
+func (s *GetGhostProgressService) Run() (responseData interface{}, err error) {
+	if progress, ok := base.GetRunningTaskProgress(s.TaskID); ok {
+		return progress, nil
+	}
+	// 任务已结束时返回执行结果中保存的最后进度
+	var task ordersModels.InsightOrderTasks
+	tx := global.App.DB.Table("`insight_order_tasks`").Where("task_id=?", s.TaskID).Take(&task)
+	if tx.RowsAffected == 0 {
+		return nil, fmt.Errorf("任务`%s`不存在", s.TaskID)
+	}
+	var result struct {
+		Progress json.RawMessage `json:"progress"`
+	}
+	if len(task.Result) > 0 {
+		if err := json.Unmarshal(task.Result, &result); err != nil {
+			return nil, err
+		}
+	}
+	if len(result.Progress) == 0 || string(result.Progress) == "null" {
+		return nil, errors.New("任务没有gh-ost执行进度")
+	}
+	return result.Progress, nil
+}
@@ -31,7 +31,7 @@ type HookOrdersService struct {
 }
 
 // 重置工单审批人/复核人
-func (s *HookOrdersService) resetStatus(users datatypes.JSON) (datatypes.JSON, error) {
+func resetStatus(users datatypes.JSON) (datatypes.JSON, error) {
 	var tmpData []map[string]interface{}
 	err := json.Unmarshal(users, &tmpData)
 	if err != nil {
@@ -67,13 +67,13 @@ func (s *HookOrdersService) Run() error {
 	// 重置审核状态
 	if s.Progress == "待审核" {
 		var err error
-		approver, err = s.resetStatus(record.Approver)
+		approver, err = resetStatus(record.Approver)
 		if err != nil {
 			return err
 		}
 	}
 	// 重置复核状态
-	reviewer, err := s.resetStatus(record.Reviewer)
+	reviewer, err := resetStatus(record.Reviewer)
 	if err != nil {
 		return err
 	}
@@ -110,10 +110,16 @@ func (s *HookOrdersService) Run() error {
 			Reviewer:         reviewer,
 			CC:               record.CC,
 			Content:          record.Content,
+			ExportFileFormat: record.ExportFileFormat,
+			CSVDelimiter:     record.CSVDelimiter,
+			CSVEncoding:      record.CSVEncoding,
+			CSVWithBOM:       record.CSVWithBOM,
+			IsBatchExecute:   record.IsBatchExecute,
+			IsTransaction:    record.IsTransaction,
 		})
 	}
 	// 批量插入
-	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.InsightOrderRecords{}).CreateInBatches(&hookRecords, 100).Error; err != nil {
 			mysqlErr := err.(*mysql.MySQLError)
 			switch mysqlErr.Number {
@@ -129,5 +135,11 @@ func (s *HookOrdersService) Run() error {
 			return err
 		}
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+	for _, hookRecord := range hookRecords {
+		dispatchOrderEvent(hookRecord, EventCreated, s.Username, fmt.Sprintf("由工单%s触发HOOK生成", record.OrderID.String()))
+	}
+	return nil
 }
@@ -13,6 +13,7 @@ import (
 	"goInsight/internal/orders/forms"
 
 	"goInsight/global"
+	"goInsight/internal/orders/api/base"
 	ordersModels "goInsight/internal/orders/models"
 	"goInsight/pkg/pagination"
 	"goInsight/pkg/utils"
@@ -111,11 +112,33 @@ func (s *GetDetailServices) convertToList(data datatypes.JSON) (users []string)
 	return
 }
 
+func (s *GetDetailServices) getMaskedColumns(orderID string) []string {
+	var results []string
+	global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
+		Where("order_id=? and result is not null", orderID).
+		Pluck("result", &results)
+	var maskedColumns []string
+	for _, r := range results {
+		var file base.ExportFile
+		if err := json.Unmarshal([]byte(r), &file); err != nil {
+			continue
+		}
+		for _, c := range file.MaskedColumns {
+			if !utils.IsContain(maskedColumns, c) {
+				maskedColumns = append(maskedColumns, c)
+			}
+		}
+	}
+	return maskedColumns
+}
+
 func (s *GetDetailServices) Run() (responseData interface{}, err error) {
 	type record struct {
 		ordersModels.InsightOrderRecords
-		Environment string `json:"environment"`
-		Instance    string `json:"instance"`
+		Environment   string                                 `json:"environment"`
+		Instance      string                                 `json:"instance"`
+		MaskedColumns []string                               `gorm:"-" json:"masked_columns"`
+		Migrations    []ordersModels.InsightMigrationHistory `gorm:"-" json:"migrations"`
 	}
 	var result record
 	// 返回记录
@@ -136,8 +159,19 @@ func (s *GetDetailServices) Run() (responseData interface{}, err error) {
 		users = append(users, s.convertToList(result.CC)...)
 		if !utils.IsContain(users, s.Username) {
 			result.Content = "您没有权限查看当前工单内容"
+			// 脱敏的列和迁移脚本同样属于工单内容，不返回
+			return result, nil
 		}
 	}
+	// 导出工单返回任务执行时生效的脱敏规则
+	if result.SQLType == "EXPORT" {
+		result.MaskedColumns = s.getMaskedColumns(result.OrderID.String())
+	}
+	// 迁移工单返回脚本的版本和提交
+	global.App.DB.Model(&ordersModels.InsightMigrationHistory{}).
+		Where("order_id=?", result.OrderID).
+		Order("id asc").
+		Scan(&result.Migrations)
 	return result, nil
 }
 
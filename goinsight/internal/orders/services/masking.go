@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/forms"
+	ordersModels "goInsight/internal/orders/models"
+	"goInsight/pkg/pagination"
+	"regexp"
+
+	commonModels "goInsight/internal/common/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 获取实例生效的脱敏规则，包括对所有实例生效的规则
+// HASH脱敏使用的密钥，没有配置时使用应用密钥
+func maskingSecret() string {
+	if secret := global.App.Config.Masking.HashSecret; secret != "" {
+		return secret
+	}
+	return global.App.Config.App.SECRET_KEY
+}
+
+func getMaskingRules(instanceID uuid.UUID) []base.MaskingRule {
+	var records []ordersModels.InsightDataMaskingRules
+	global.App.DB.Model(&ordersModels.InsightDataMaskingRules{}).
+		Where("is_enable=1 and (instance_id=? or instance_id=?)", instanceID, uuid.Nil).
+		Order("id asc").
+		Scan(&records)
+	var rules []base.MaskingRule
+	for _, r := range records {
+		rules = append(rules, base.MaskingRule{
+			Schema:  r.Schema,
+			Table:   r.Table,
+			Column:  r.Column,
+			Pattern: r.Pattern,
+			Method:  string(r.Method),
+		})
+	}
+	return rules
+}
+
+func newMaskingRule(form *forms.CreateMaskingRuleForm) (*ordersModels.InsightDataMaskingRules, error) {
+	if form.Pattern != "" {
+		if _, err := regexp.Compile(form.Pattern); err != nil {
+			return nil, fmt.Errorf("正则表达式`%s`错误：%s", form.Pattern, err.Error())
+		}
+	}
+	rule := ordersModels.InsightDataMaskingRules{
+		Schema:   form.Schema,
+		Table:    form.Table,
+		Column:   form.Column,
+		Pattern:  form.Pattern,
+		Method:   commonModels.EnumType(form.Method),
+		IsEnable: &form.IsEnable,
+		Remark:   form.Remark,
+	}
+	if form.InstanceID != "" {
+		rule.InstanceID, _ = uuid.Parse(form.InstanceID)
+	}
+	return &rule, nil
+}
+
+type GetMaskingRulesService struct {
+	*forms.GetMaskingRulesForm
+	C *gin.Context
+}
+
+func (s *GetMaskingRulesService) Run() (responseData interface{}, total int64, err error) {
+	var rules []ordersModels.InsightDataMaskingRules
+	tx := global.App.DB.Table("`insight_data_masking_rules`").Order("id asc")
+	if s.InstanceID != "" {
+		tx = tx.Where("instance_id=?", s.InstanceID)
+	}
+	// 搜索
+	if s.Search != "" {
+		tx = tx.Where("`schema` like ? or `table` like ? or `column` like ? or `pattern` like ?",
+			"%"+s.Search+"%", "%"+s.Search+"%", "%"+s.Search+"%", "%"+s.Search+"%")
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &rules)
+	return &rules, total, nil
+}
+
+type CreateMaskingRuleService struct {
+	*forms.CreateMaskingRuleForm
+	C *gin.Context
+}
+
+func (s *CreateMaskingRuleService) Run() error {
+	rule, err := newMaskingRule(s.CreateMaskingRuleForm)
+	if err != nil {
+		return err
+	}
+	return global.App.DB.Create(rule).Error
+}
+
+type UpdateMaskingRuleService struct {
+	*forms.UpdateMaskingRuleForm
+	C  *gin.Context
+	ID uint64
+}
+
+func (s *UpdateMaskingRuleService) Run() error {
+	rule, err := newMaskingRule(&s.CreateMaskingRuleForm)
+	if err != nil {
+		return err
+	}
+	tx := global.App.DB.Model(&ordersModels.InsightDataMaskingRules{}).Where("id=?", s.ID).Updates(map[string]interface{}{
+		"instance_id": rule.InstanceID,
+		"schema":      rule.Schema,
+		"table":       rule.Table,
+		"column":      rule.Column,
+		"pattern":     rule.Pattern,
+		"method":      rule.Method,
+		"is_enable":   *rule.IsEnable,
+		"remark":      rule.Remark,
+	})
+	return tx.Error
+}
+
+type DeleteMaskingRuleService struct {
+	C  *gin.Context
+	ID uint64
+}
+
+func (s *DeleteMaskingRuleService) Run() error {
+	return global.App.DB.Where("id=?", s.ID).Delete(&ordersModels.InsightDataMaskingRules{}).Error
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/migration"
+	"goInsight/pkg/parser"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 统计版本对应任务的进度
+func migrationTaskProgress(tx *gorm.DB, h models.InsightMigrationHistory) (map[string]int, int, error) {
+	var taskIDs []string
+	if len(h.TaskIDs) == 0 {
+		return nil, 0, nil
+	}
+	if err := json.Unmarshal(h.TaskIDs, &taskIDs); err != nil {
+		return nil, 0, err
+	}
+	var counts []struct {
+		Progress string
+		Count    int
+	}
+	if len(taskIDs) > 0 {
+		tx.Model(&models.InsightOrderTasks{}).
+			Select("progress, count(*) as count").
+			Where("task_id in ?", taskIDs).
+			Group("progress").
+			Scan(&counts)
+	}
+	progress := make(map[string]int)
+	for _, c := range counts {
+		progress[c.Progress] = c.Count
+	}
+	return progress, len(taskIDs), nil
+}
+
+// 工单驳回或关闭时释放占用的版本，已经开始执行的版本不释放，失败的版本释放后可以重新提交
+func releaseMigrationVersions(tx *gorm.DB, orderID uuid.UUID) error {
+	var history []models.InsightMigrationHistory
+	tx.Model(&models.InsightMigrationHistory{}).Where("order_id=? and status in ('待执行', '失败')", orderID).Find(&history)
+	var ids []uint64
+	for _, h := range history {
+		if h.Status == "失败" {
+			ids = append(ids, h.ID)
+			continue
+		}
+		progress, _, err := migrationTaskProgress(tx, h)
+		if err != nil {
+			return err
+		}
+		if progress["执行中"]+progress["已完成"]+progress["已失败"] > 0 {
+			continue
+		}
+		ids = append(ids, h.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Where("id in ?", ids).Delete(&models.InsightMigrationHistory{}).Error
+}
+
+// 生成任务后，按目标和语句顺序将任务关联到每个版本
+func bindMigrationTasks(tx *gorm.DB, orderID uuid.UUID, tasks []map[string]interface{}) error {
+	var history []models.InsightMigrationHistory
+	tx.Model(&models.InsightMigrationHistory{}).Where("order_id=?", orderID).Order("id asc").Find(&history)
+	if len(history) == 0 {
+		return nil
+	}
+	targetTasks := make(map[string][]string)
+	for _, task := range tasks {
+		key := fmt.Sprintf("%v/%v", task["InstanceID"], task["Schema"])
+		targetTasks[key] = append(targetTasks[key], fmt.Sprintf("%v", task["TaskID"]))
+	}
+	for _, h := range history {
+		key := fmt.Sprintf("%v/%v", h.InstanceID, h.Schema)
+		remaining := targetTasks[key]
+		if h.Statements == 0 || len(remaining) < h.Statements {
+			continue
+		}
+		taskIDs, err := json.Marshal(remaining[:h.Statements])
+		if err != nil {
+			return err
+		}
+		targetTasks[key] = remaining[h.Statements:]
+		if err := tx.Model(&models.InsightMigrationHistory{}).Where("id=?", h.ID).Update("task_ids", datatypes.JSON(taskIDs)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 任务执行后更新版本状态，版本的任务全部完成时为已执行，有任务失败时为失败；
+// 失败的版本重新执行任务后同样重新计算状态
+func updateMigrationVersions(orderID string) {
+	var history []models.InsightMigrationHistory
+	global.App.DB.Model(&models.InsightMigrationHistory{}).Where("order_id=? and status in ('待执行', '失败')", orderID).Find(&history)
+	for _, h := range history {
+		progress, total, err := migrationTaskProgress(global.App.DB, h)
+		if err != nil {
+			global.App.Log.Error(err)
+			continue
+		}
+		status := "待执行"
+		switch {
+		case progress["已失败"] > 0:
+			status = "失败"
+		case total > 0 && progress["已完成"] == total:
+			status = "已执行"
+		}
+		if string(h.Status) == status {
+			continue
+		}
+		if err := global.App.DB.Model(&models.InsightMigrationHistory{}).Where("id=?", h.ID).Update("status", status).Error; err != nil {
+			global.App.Log.Error(err)
+		}
+	}
+}
+
+// 获取目标已提交的最新版本，包括待执行的版本，失败的版本可以重新提交，不参与比较
+func latestMigrationVersion(tx *gorm.DB, target models.OrderTarget) (latest models.InsightMigrationHistory, history []models.InsightMigrationHistory) {
+	tx.Model(&models.InsightMigrationHistory{}).
+		Where("instance_id=? and `schema`=?", target.InstanceID, target.Schema).
+		Scan(&history)
+	for _, h := range history {
+		if h.Status == "失败" {
+			continue
+		}
+		if latest.Version == "" || migration.CompareVersions(h.Version, latest.Version) > 0 {
+			latest = h
+		}
+	}
+	return
+}
+
+// 检查目标的版本，拒绝已提交的版本和早于最新版本的乱序版本；
+// 重新提交失败的版本时，删除失败的记录
+func checkMigrationVersions(tx *gorm.DB, target models.OrderTarget, scripts []migration.Script) error {
+	latest, history := latestMigrationVersion(tx.Clauses(clause.Locking{Strength: "UPDATE"}), target)
+	var failed []uint64
+	for _, h := range history {
+		for _, script := range scripts {
+			if migration.CompareVersions(h.Version, script.Version) != 0 {
+				continue
+			}
+			if h.Status == "失败" {
+				failed = append(failed, h.ID)
+				continue
+			}
+			return fmt.Errorf("目标%s/%s的版本%s已经由工单%s提交，状态：%s", target.InstanceID, target.Schema, h.Version, h.OrderID, h.Status)
+		}
+	}
+	if latest.Version != "" && migration.CompareVersions(scripts[0].Version, latest.Version) < 0 {
+		return fmt.Errorf("目标%s/%s的版本%s早于已提交的最新版本%s，拒绝乱序执行", target.InstanceID, target.Schema, scripts[0].Version, latest.Version)
+	}
+	if len(failed) > 0 {
+		return tx.Where("id in ?", failed).Delete(&models.InsightMigrationHistory{}).Error
+	}
+	return nil
+}
+
+// 从git仓库的迁移脚本创建工单
+type CreateMigrationOrderService struct {
+	*forms.CreateMigrationOrderForm
+	C        *gin.Context
+	Username string
+}
+
+// 没有指定起始版本时，从目标已提交的最新版本之后开始，多个目标的最新版本必须一致
+func (s *CreateMigrationOrderService) fromVersion(targets []models.OrderTarget) (from string, exclusive bool, err error) {
+	if s.FromVersion != "" {
+		return s.FromVersion, false, nil
+	}
+	for i, target := range targets {
+		latest, _ := latestMigrationVersion(global.App.DB, target)
+		if i > 0 && migration.CompareVersions(latest.Version, from) != 0 {
+			return "", false, errors.New("多个目标已提交的最新版本不一致，请指定起始版本")
+		}
+		from = latest.Version
+	}
+	return from, from != "", nil
+}
+
+func (s *CreateMigrationOrderService) Run() error {
+	cfg := global.App.Config.Migration
+	repo, err := migration.OpenRepository(cfg.RepoRoot, s.Repository, cfg.GitPath)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.ResolveCommit(s.Ref)
+	if err != nil {
+		return err
+	}
+
+	service := CreateOrdersService{
+		CreateOrderForm: &forms.CreateOrderForm{
+			Title:            s.Title,
+			Remark:           s.Remark,
+			IsRestrictAccess: s.IsRestrictAccess,
+			DBType:           s.DBType,
+			SQLType:          s.SQLType,
+			Environment:      s.Environment,
+			InstanceID:       s.InstanceID,
+			Schema:           s.Schema,
+			Approver:         s.Approver,
+			Executor:         s.Executor,
+			Reviewer:         s.Reviewer,
+			CC:               s.CC,
+			ScheduleTime:     s.ScheduleTime,
+			ExportFileFormat: "XLSX",
+			Targets:          s.Targets,
+		},
+		C:        s.C,
+		Username: s.Username,
+	}
+	targets, _, err := service.resolveTargets()
+	if err != nil {
+		return err
+	}
+	from, exclusive, err := s.fromVersion(targets)
+	if err != nil {
+		return err
+	}
+	scripts, err := repo.Scripts(commit, s.Path, from, s.ToVersion)
+	if err != nil {
+		return err
+	}
+	if exclusive && len(scripts) > 0 && migration.CompareVersions(scripts[0].Version, from) == 0 {
+		scripts = scripts[1:]
+	}
+	if len(scripts) == 0 {
+		return fmt.Errorf("仓库%s的提交%s中没有待执行的迁移脚本", s.Repository, commit[:8])
+	}
+
+	// 按版本顺序拼接脚本，每个脚本以分号结束，记录每个脚本的语句数量用于关联任务
+	var content []string
+	statements := make([]int, len(scripts))
+	for i, script := range scripts {
+		text := strings.TrimSpace(script.Content)
+		if !strings.HasSuffix(text, ";") {
+			text += ";"
+		}
+		content = append(content, text)
+		sqls, err := parser.SplitSQLText(text)
+		if err != nil {
+			return fmt.Errorf("迁移脚本%s解析失败：%s", script.File, err.Error())
+		}
+		statements[i] = len(sqls)
+	}
+	service.Content = strings.Join(content, "\n")
+
+	// 在创建工单的事务中检查并占用每个目标的版本
+	service.AfterCreate = func(tx *gorm.DB, record models.InsightOrderRecords) error {
+		targets, err := getOrderTargets(record)
+		if err != nil {
+			return err
+		}
+		var history []models.InsightMigrationHistory
+		for _, target := range targets {
+			if err := checkMigrationVersions(tx, target, scripts); err != nil {
+				return err
+			}
+			for i, script := range scripts {
+				history = append(history, models.InsightMigrationHistory{
+					InstanceID:  target.InstanceID,
+					Schema:      target.Schema,
+					Version:     script.Version,
+					Description: script.Description,
+					Repository:  s.Repository,
+					Script:      script.File,
+					Checksum:    script.Checksum,
+					CommitSHA:   commit,
+					OrderID:     record.OrderID,
+					Statements:  statements[i],
+					Status:      "待执行",
+				})
+			}
+		}
+		if err := tx.CreateInBatches(&history, 500).Error; err != nil {
+			return fmt.Errorf("记录迁移版本失败：%s", err.Error())
+		}
+		return CreateOpLogs(tx, record.OrderID, s.Username,
+			fmt.Sprintf("工单由仓库%s的提交%s创建，迁移版本%s~%s", s.Repository, commit, scripts[0].Version, scripts[len(scripts)-1].Version))
+	}
+	return service.Run()
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"goInsight/global"
 	"goInsight/internal/orders/forms"
@@ -34,18 +35,23 @@ func (s *ApproveService) updateProgress(tx *gorm.DB, progress string) error {
 	return nil
 }
 
+// 按审批时看到的版本更新，审批期间工单内容被修改时拒绝审批
 func (s *ApproveService) updateApprover(tx *gorm.DB, users []map[string]interface{}) error {
 	usersJson, err := json.Marshal(users)
 	if err != nil {
 		return err
 	}
-	if err := tx.Model(&models.InsightOrderRecords{}).
-		Where("order_id=?", s.OrderID).
+	result := tx.Model(&models.InsightOrderRecords{}).
+		Where("order_id=? and revision=? and progress=?", s.OrderID, s.Revision, "待审核").
 		Updates(map[string]interface{}{
 			"approver":   string(usersJson),
 			"updated_at": time.Now().Format("2006-01-02 15:04:05"),
-		}).Error; err != nil {
-		return err
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("工单内容已被修改，请刷新查看最新版本后重新审批")
 	}
 	return nil
 }
@@ -61,6 +67,10 @@ func (s *ApproveService) Run() (err error) {
 	if !utils.IsContain([]string{"待审核"}, string(record.Progress)) {
 		return fmt.Errorf("非可操作状态，禁止操作")
 	}
+	// 判断审批的是否为最新的工单内容
+	if record.Revision != s.Revision {
+		return fmt.Errorf("工单内容已修改为版本%d，请刷新查看后重新审批", record.Revision)
+	}
 	// 获取允许审核的用户
 	var approverList []map[string]interface{}
 	err = json.Unmarshal([]byte(record.Approver), &approverList)
@@ -87,7 +97,7 @@ func (s *ApproveService) Run() (err error) {
 	if M == 0 {
 		return fmt.Errorf("您没有当前工单的审核权限")
 	}
-	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
 		// 更新审批人信息
 		if err := s.updateApprover(tx, approverList); err != nil {
 			return err
@@ -111,6 +121,9 @@ func (s *ApproveService) Run() (err error) {
 			if err := s.updateProgress(tx, "已驳回"); err != nil {
 				return err
 			}
+			if err := releaseMigrationVersions(tx, record.OrderID); err != nil {
+				return err
+			}
 		}
 		// 操作日志
 		logMsg := fmt.Sprintf("用户%s审核通过了工单", s.Username)
@@ -125,7 +138,16 @@ func (s *ApproveService) Run() (err error) {
 		msg := fmt.Sprintf("您好，%s\n>工单标题：%s\n>附加消息：%s", logMsg, record.Title, s.Msg)
 		notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+	// 驳回或全部审核通过时推送事件
+	if s.Status == "reject" {
+		dispatchOrderEvent(record, EventRejected, s.Username, s.Msg)
+	} else if len(approverList) == passCount {
+		dispatchOrderEvent(record, EventApproved, s.Username, s.Msg)
+	}
+	return nil
 }
 
 // 更新计划时间
@@ -202,7 +224,7 @@ func (s *FeedbackService) Run() (err error) {
 		return fmt.Errorf("非可操作状态，禁止操作")
 	}
 	// 用户点击反馈按钮
-	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.InsightOrderRecords{}).
 			Where("order_id=?", s.OrderID).
 			Updates(map[string]interface{}{"progress": s.Progress, "updated_at": time.Now().Format("2006-01-02 15:04:05")}).Error; err != nil {
@@ -219,7 +241,15 @@ func (s *FeedbackService) Run() (err error) {
 		msg := fmt.Sprintf("您好，用户%s更新工单状态为：%s\n>工单标题：%s\n>附加消息：%s", s.Username, s.Progress, record.Title, s.Msg)
 		notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+	if s.Progress == "已完成" {
+		dispatchOrderEvent(record, EventCompleted, s.Username, s.Msg)
+	} else if record.Progress != "执行中" {
+		dispatchOrderEvent(record, EventExecuting, s.Username, s.Msg)
+	}
+	return nil
 }
 
 // 复核
@@ -307,6 +337,10 @@ func (s *ReviewService) Run() (err error) {
 		}
 		// 操作日志
 		logMsg := fmt.Sprintf("用户%s复核了工单，附加消息：%s", s.Username, s.Msg)
+		// 记录复核时的校验结果
+		if summary := verificationSummary(record.OrderID.String()); summary != "" {
+			logMsg = fmt.Sprintf("%s，校验结果：%s", logMsg, summary)
+		}
 		if err := CreateOpLogs(tx, record.OrderID, s.Username, logMsg); err != nil {
 			return err
 		}
@@ -349,11 +383,14 @@ func (s *CloseService) Run() (err error) {
 	if !utils.IsContain([]string{"待审核", "已批准", "执行中"}, string(record.Progress)) {
 		return fmt.Errorf("非可操作状态，禁止操作")
 	}
-	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+	if err := global.App.DB.Transaction(func(tx *gorm.DB) error {
 		// 更新状态为已关闭
 		if err := s.updateProgress(tx, "已关闭"); err != nil {
 			return err
 		}
+		if err := releaseMigrationVersions(tx, record.OrderID); err != nil {
+			return err
+		}
 		// 操作日志
 		logMsg := fmt.Sprintf("用户%s关闭了工单，附加消息：%s", s.Username, s.Msg)
 		if err := CreateOpLogs(tx, record.OrderID, s.Username, logMsg); err != nil {
@@ -364,5 +401,9 @@ func (s *CloseService) Run() (err error) {
 		msg := fmt.Sprintf("您好，用户%s关闭了工单\n>工单标题：%s\n>附加消息：%s", s.Username, record.Title, s.Msg)
 		notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
 		return nil
-	})
+	}); err != nil {
+		return err
+	}
+	dispatchOrderEvent(record, EventClosed, s.Username, s.Msg)
+	return nil
 }
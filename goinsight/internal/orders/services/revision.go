@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"goInsight/global"
+	commonModels "goInsight/internal/common/models"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/notifier"
+	"goInsight/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+// 获取工单的所有版本，没有修改过的工单只有当前版本
+func getOrderRevisions(record models.InsightOrderRecords) []models.InsightOrderRevisions {
+	var revisions []models.InsightOrderRevisions
+	global.App.DB.Model(&models.InsightOrderRevisions{}).
+		Where("order_id=?", record.OrderID).
+		Order("revision asc").
+		Scan(&revisions)
+	if len(revisions) == 0 {
+		revisions = append(revisions, models.InsightOrderRevisions{
+			OrderID:  record.OrderID,
+			Revision: record.Revision,
+			Username: record.Applicant,
+			Content:  record.Content,
+		})
+	}
+	return revisions
+}
+
+// 修改工单内容，生成新的版本并重新审核
+type ReviseOrderService struct {
+	*forms.ReviseOrderForm
+	C        *gin.Context
+	Username string
+}
+
+// 按工单当前的类型和目标重新检查SQL
+func (s *ReviseOrderService) inspect(record models.InsightOrderRecords) error {
+	service := CreateOrdersService{
+		CreateOrderForm: &forms.CreateOrderForm{
+			DBType:  record.DBType,
+			SQLType: record.SQLType,
+			Content: s.Content,
+		},
+		C:        s.C,
+		Username: s.Username,
+	}
+	if err := service.checkSQLType(); err != nil {
+		return err
+	}
+	targets, err := getOrderTargets(record)
+	if err != nil {
+		return err
+	}
+	var instanceIDs []uuid.UUID
+	for _, target := range targets {
+		instanceIDs = append(instanceIDs, target.InstanceID)
+	}
+	var list []commonModels.InsightDBConfig
+	global.App.DB.Table("`insight_db_config`").Where("instance_id in ?", instanceIDs).Find(&list)
+	configs := make(map[uuid.UUID]commonModels.InsightDBConfig)
+	for _, config := range list {
+		configs[config.InstanceID] = config
+	}
+	return service.inspectTargets(targets, configs)
+}
+
+func (s *ReviseOrderService) Run() error {
+	var record models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	if record.Applicant != s.Username {
+		return errors.New("仅工单申请人可以修改工单")
+	}
+	if !utils.IsContain([]string{"待审核", "已驳回"}, string(record.Progress)) {
+		return fmt.Errorf("工单当前状态为%s，仅待审核和已驳回的工单可以修改", record.Progress)
+	}
+	if record.Content == s.Content {
+		return errors.New("工单内容没有变化")
+	}
+	// 迁移工单的内容和仓库中的版本对应
+	var count int64
+	global.App.DB.Model(&models.InsightMigrationHistory{}).Where("order_id=?", record.OrderID).Count(&count)
+	if count > 0 {
+		return errors.New("迁移工单的内容来自仓库，请在仓库中修改后重新提交")
+	}
+	if err := s.inspect(record); err != nil {
+		return err
+	}
+	// 重置审核人和复核人的状态
+	approver, err := resetStatus(record.Approver)
+	if err != nil {
+		return err
+	}
+	reviewer, err := resetStatus(record.Reviewer)
+	if err != nil {
+		return err
+	}
+	revision := record.Revision + 1
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		// 首次修改时记录原始版本
+		var revisions []models.InsightOrderRevisions
+		var exists int64
+		tx.Model(&models.InsightOrderRevisions{}).Where("order_id=?", record.OrderID).Count(&exists)
+		if exists == 0 {
+			revisions = append(revisions, models.InsightOrderRevisions{
+				OrderID:  record.OrderID,
+				Revision: record.Revision,
+				Username: record.Applicant,
+				Content:  record.Content,
+			})
+		}
+		revisions = append(revisions, models.InsightOrderRevisions{
+			OrderID:  record.OrderID,
+			Revision: revision,
+			Username: s.Username,
+			Content:  s.Content,
+			Msg:      s.Msg,
+		})
+		if err := tx.Create(&revisions).Error; err != nil {
+			return err
+		}
+		// 按版本号更新，避免并发修改和审批覆盖
+		result := tx.Model(&models.InsightOrderRecords{}).
+			Where("order_id=? and revision=? and progress in ?", record.OrderID, record.Revision, []string{"待审核", "已驳回"}).
+			Updates(map[string]interface{}{
+				"content":    s.Content,
+				"revision":   revision,
+				"progress":   "待审核",
+				"approver":   approver,
+				"reviewer":   reviewer,
+				"updated_at": time.Now().Format("2006-01-02 15:04:05"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("工单已被其他操作修改，请刷新后重试")
+		}
+		logMsg := fmt.Sprintf("用户%s修改了工单内容，版本：%d", s.Username, revision)
+		if err := CreateOpLogs(tx, record.OrderID, s.Username, fmt.Sprintf("%s，修改说明：%s", logMsg, s.Msg)); err != nil {
+			return err
+		}
+		// 发送消息，发送给审核人、复核人和抄送人
+		receiver := orderParticipants(record)
+		msg := fmt.Sprintf("您好，%s，请重新审核\n>工单标题：%s\n>修改说明：%s", logMsg, record.Title, s.Msg)
+		notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
+		return nil
+	})
+}
+
+type GetOrderRevisionsService struct {
+	*forms.GetOrderRevisionsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetOrderRevisionsService) Run() (responseData interface{}, err error) {
+	record, err := getAccessibleOrder(s.OrderID, s.Username)
+	if err != nil {
+		return nil, err
+	}
+	return getOrderRevisions(record), nil
+}
+
+type GetOrderRevisionDiffService struct {
+	*forms.GetOrderRevisionDiffForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetOrderRevisionDiffService) Run() (responseData interface{}, err error) {
+	record, err := getAccessibleOrder(s.OrderID, s.Username)
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[int]string)
+	for _, r := range getOrderRevisions(record) {
+		contents[r.Revision] = r.Content
+	}
+	from, ok := contents[s.From]
+	if !ok {
+		return nil, fmt.Errorf("版本`%d`不存在", s.From)
+	}
+	to, ok := contents[s.To]
+	if !ok {
+		return nil, fmt.Errorf("版本`%d`不存在", s.To)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fmt.Sprintf("v%d", s.From),
+		ToFile:   fmt.Sprintf("v%d", s.To),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"from": s.From,
+		"to":   s.To,
+		"diff": diff,
+	}, nil
+}
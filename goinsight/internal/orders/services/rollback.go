@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/base"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/pagination"
+	"goInsight/pkg/parser"
+	"goInsight/pkg/utils"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// 基于已完成任务的回滚SQL创建回滚工单
+type CreateRollbackOrderService struct {
+	*forms.CreateRollbackOrderForm
+	C        *gin.Context
+	Username string
+}
+
+// 提取审核人/复核人中的用户名
+func (s *CreateRollbackOrderService) extractUsers(users datatypes.JSON) ([]string, error) {
+	var tmpData []map[string]interface{}
+	if err := json.Unmarshal(users, &tmpData); err != nil {
+		return nil, err
+	}
+	var data []string
+	for _, u := range tmpData {
+		if user, ok := u["user"].(string); ok {
+			data = append(data, user)
+		}
+	}
+	return data, nil
+}
+
+// 按照任务倒序收集回滚SQL，单个任务内的回滚SQL生成时已经是回滚的执行顺序，保持不变
+func (s *CreateRollbackOrderService) collectRollbackSQL(tasks []models.InsightOrderTasks) (string, error) {
+	var sqls []string
+	for i := len(tasks) - 1; i >= 0; i-- {
+		var data base.ReturnData
+		if err := json.Unmarshal([]byte(tasks[i].Result), &data); err != nil {
+			continue
+		}
+		// 回滚SQL较大时仅保存在文件中
+		if strings.TrimSpace(data.RollbackSQL) == "" && data.RollbackSQLFile != "" {
+			content, err := os.ReadFile(data.RollbackSQLFile)
+			if err != nil {
+				return "", fmt.Errorf("读取任务`%s`的回滚SQL文件失败：%s", tasks[i].TaskID, err.Error())
+			}
+			data.RollbackSQL = string(content)
+		}
+		if strings.TrimSpace(data.RollbackSQL) == "" {
+			continue
+		}
+		stmts, err := parser.SplitSQLText(data.RollbackSQL)
+		if err != nil {
+			return "", fmt.Errorf("解析任务`%s`的回滚SQL失败：%s", tasks[i].TaskID, err.Error())
+		}
+		for _, stmt := range stmts {
+			sqls = append(sqls, strings.TrimSuffix(strings.TrimSpace(stmt), ";")+";")
+		}
+	}
+	if len(sqls) == 0 {
+		return "", errors.New("选择的任务没有可用的回滚SQL")
+	}
+	return strings.Join(sqls, "\n"), nil
+}
+
+func (s *CreateRollbackOrderService) Run() error {
+	// 判断工单是否存在
+	var record models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	if record.SQLType == "EXPORT" {
+		return errors.New("导出工单不支持创建回滚工单")
+	}
+	// 申请人和执行人可以创建回滚工单
+	var executorList []string
+	if err := json.Unmarshal([]byte(record.Executor), &executorList); err != nil {
+		return err
+	}
+	if record.Applicant != s.Username && !utils.IsContain(executorList, s.Username) {
+		return errors.New("您没有创建回滚工单的权限，仅申请人和执行人可以操作")
+	}
+	// 获取选择的已完成任务
+	var tasks []models.InsightOrderTasks
+	global.App.DB.Table("`insight_order_tasks`").
+		Where("order_id=? and task_id in ?", s.OrderID, s.TaskIDs).
+		Order("id asc").
+		Scan(&tasks)
+	if len(tasks) != len(s.TaskIDs) {
+		return errors.New("选择的任务不存在或不属于当前工单")
+	}
+	for _, task := range tasks {
+		if task.Progress != "已完成" {
+			return fmt.Errorf("任务`%s`的状态为%s，仅已完成的任务可以回滚", task.TaskID, task.Progress)
+		}
+	}
+	// 多目标工单的回滚工单只能回滚同一个目标的任务
+	instanceID, schema := record.InstanceID, record.Schema
+	if len(tasks) > 0 && tasks[0].InstanceID != uuid.Nil {
+		instanceID, schema = tasks[0].InstanceID, tasks[0].Schema
+	}
+	for _, task := range tasks {
+		if task.InstanceID != uuid.Nil && (task.InstanceID != instanceID || task.Schema != schema) {
+			return errors.New("选择的任务属于不同的目标，请按目标分别创建回滚工单")
+		}
+	}
+	content, err := s.collectRollbackSQL(tasks)
+	if err != nil {
+		return err
+	}
+	// 沿用源工单的审核人/复核人/执行人/抄送人
+	approver, err := s.extractUsers(record.Approver)
+	if err != nil {
+		return err
+	}
+	reviewer, err := s.extractUsers(record.Reviewer)
+	if err != nil {
+		return err
+	}
+	var cc []string
+	if len(record.CC) > 0 {
+		if err := json.Unmarshal([]byte(record.CC), &cc); err != nil {
+			return err
+		}
+	}
+	title := s.Title
+	if title == "" {
+		title = fmt.Sprintf("[回滚]%s", record.Title)
+		if runes := []rune(title); len(runes) > 96 {
+			title = string(runes[:96])
+		}
+	}
+	remark := s.Remark
+	if remark == "" {
+		remark = fmt.Sprintf("回滚工单，源工单：%s", record.OrderID)
+	}
+	isRestrictAccess := record.IsRestrictAccess
+	// 走正常的提交流程，包括语法审核
+	service := CreateOrdersService{
+		CreateOrderForm: &forms.CreateOrderForm{
+			Title:            title,
+			Remark:           remark,
+			IsRestrictAccess: &isRestrictAccess,
+			DBType:           record.DBType,
+			SQLType:          record.SQLType,
+			Environment:      record.Environment,
+			InstanceID:       instanceID.String(),
+			Schema:           schema,
+			Approver:         approver,
+			Executor:         executorList,
+			Reviewer:         reviewer,
+			CC:               cc,
+			Content:          content,
+			ExportFileFormat: record.ExportFileFormat,
+		},
+		C:               s.C,
+		Username:        s.Username,
+		RollbackOrderID: record.OrderID,
+	}
+	return service.Run()
+}
+
+// 获取工单关联的回滚工单
+type GetRollbackOrdersService struct {
+	*forms.GetRollbackOrdersForm
+	C       *gin.Context
+	OrderID string
+}
+
+func (s *GetRollbackOrdersService) Run() (responseData interface{}, total int64, err error) {
+	orderID, err := uuid.Parse(s.OrderID)
+	if err != nil {
+		return nil, 0, err
+	}
+	var records []models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").
+		Select("id, title, order_id, rollback_order_id, progress, applicant, created_at, updated_at").
+		Where("rollback_order_id=?", orderID).
+		Order("created_at desc")
+	total = pagination.Pager(&s.PaginationQ, tx, &records)
+	return &records, total, nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/forms"
+	"goInsight/pkg/parser"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 统计结果，导出CSV时按Columns的顺序输出
+type StatisticsTable struct {
+	Columns []string                 `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+}
+
+// 执行完成的工单进度
+var finishedProgress = []string{"已完成", "已复核"}
+
+// 按时间范围和环境过滤工单，结束日期包含当天
+func statisticsScope(tx *gorm.DB, form *forms.StatisticsForm) (*gorm.DB, error) {
+	start, err := time.ParseInLocation("2006-01-02", form.StartDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.ParseInLocation("2006-01-02", form.EndDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束日期%s早于开始日期%s", form.EndDate, form.StartDate)
+	}
+	tx = tx.Where("a.created_at>=? and a.created_at<?", start, end.AddDate(0, 0, 1))
+	if form.Environment > 0 {
+		tx = tx.Where("a.environment=?", form.Environment)
+	}
+	return tx, nil
+}
+
+// 统计周期的格式，默认按天
+func periodExpr(interval string) string {
+	switch interval {
+	case "week":
+		return "DATE_FORMAT(a.created_at, '%x-W%v')"
+	case "month":
+		return "DATE_FORMAT(a.created_at, '%Y-%m')"
+	}
+	return "DATE_FORMAT(a.created_at, '%Y-%m-%d')"
+}
+
+// 保留小数位数
+func round(f float64, n int) float64 {
+	p := math.Pow(10, float64(n))
+	return math.Round(f*p) / p
+}
+
+// 计算有序数据的百分位数，使用最近秩法
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// 按环境、组织或申请人统计工单数量
+type OrderStatisticsService struct {
+	*forms.OrderStatisticsForm
+	C *gin.Context
+}
+
+func (s *OrderStatisticsService) Run() (*StatisticsTable, error) {
+	keyExpr := map[string]string{
+		"environment":  "IFNULL(b.name, '')",
+		"organization": "a.organization",
+		"applicant":    "a.applicant",
+	}[s.Dimension]
+	tx, err := statisticsScope(global.App.DB.Table("`insight_order_records` a"), &s.StatisticsForm)
+	if err != nil {
+		return nil, err
+	}
+	type row struct {
+		Period   string
+		Key      string
+		Orders   int64
+		Finished int64
+		Rejected int64
+	}
+	var rows []row
+	period := periodExpr(s.Interval)
+	if err := tx.Select(fmt.Sprintf(`%s as period, %s as `+"`key`"+`, count(*) as orders,
+			sum(a.progress in ?) as finished, sum(a.progress='已驳回') as rejected`, period, keyExpr), finishedProgress).
+		Joins("left join insight_db_environments b on a.environment=b.id").
+		Group("period, `key`").
+		Order("period asc, orders desc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	table := &StatisticsTable{Columns: []string{"period", s.Dimension, "orders", "finished", "rejected"}}
+	for _, r := range rows {
+		table.Rows = append(table.Rows, map[string]interface{}{
+			"period":    r.Period,
+			s.Dimension: r.Key,
+			"orders":    r.Orders,
+			"finished":  r.Finished,
+			"rejected":  r.Rejected,
+		})
+	}
+	return table, nil
+}
+
+// 审批耗时：从提交工单到最后一个审核人通过的时间，按环境统计百分位数
+type ApprovalLatencyStatisticsService struct {
+	*forms.StatisticsForm
+	C *gin.Context
+}
+
+func (s *ApprovalLatencyStatisticsService) Run() (*StatisticsTable, error) {
+	tx, err := statisticsScope(global.App.DB.Table("`insight_order_records` a"), s.StatisticsForm)
+	if err != nil {
+		return nil, err
+	}
+	type row struct {
+		Environment string
+		Seconds     float64
+	}
+	var rows []row
+	if err := tx.Select("MAX(IFNULL(b.name, '')) as environment, TIMESTAMPDIFF(SECOND, MIN(a.created_at), MAX(c.created_at)) as seconds").
+		Joins("left join insight_db_environments b on a.environment=b.id").
+		Joins("join insight_order_oplogs c on c.order_id=a.order_id and c.msg like ?", "%审核通过了工单%").
+		Where("a.progress in ?", []string{"已批准", "执行中", "已完成", "已复核"}).
+		Group("a.order_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	// 按环境分组，并汇总所有环境
+	groups := map[string][]float64{}
+	var environments []string
+	for _, r := range rows {
+		if _, ok := groups[r.Environment]; !ok {
+			environments = append(environments, r.Environment)
+		}
+		groups[r.Environment] = append(groups[r.Environment], r.Seconds)
+		groups["全部"] = append(groups["全部"], r.Seconds)
+	}
+	sort.Strings(environments)
+	environments = append(environments, "全部")
+	table := &StatisticsTable{Columns: []string{"environment", "orders", "avg_seconds", "p50_seconds", "p90_seconds", "p99_seconds", "max_seconds"}}
+	for _, env := range environments {
+		values := groups[env]
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		table.Rows = append(table.Rows, map[string]interface{}{
+			"environment": env,
+			"orders":      len(values),
+			"avg_seconds": round(sum/float64(len(values)), 2),
+			"p50_seconds": percentile(values, 50),
+			"p90_seconds": percentile(values, 90),
+			"p99_seconds": percentile(values, 99),
+			"max_seconds": values[len(values)-1],
+		})
+	}
+	return table, nil
+}
+
+// 任务的执行成功率和平均执行耗时，按DB类型和SQL类型统计
+type ExecutionStatisticsService struct {
+	*forms.StatisticsForm
+	C *gin.Context
+}
+
+func (s *ExecutionStatisticsService) Run() (*StatisticsTable, error) {
+	tx, err := statisticsScope(global.App.DB.Table("`insight_order_tasks` t"), s.StatisticsForm)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Select("t.db_type, t.sql_type, t.progress, IFNULL(JSON_UNQUOTE(JSON_EXTRACT(t.result, '$.execute_cost_time')), '') as cost").
+		Joins("join insight_order_records a on a.order_id=t.order_id").
+		Where("t.progress in ?", []string{"已完成", "已失败"}).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type stat struct {
+		dbType, sqlType string
+		success, failed int64
+		costCount       int64
+		costSum         time.Duration
+	}
+	stats := map[string]*stat{}
+	var keys []string
+	for rows.Next() {
+		var dbType, sqlType, progress, cost string
+		if err := rows.Scan(&dbType, &sqlType, &progress, &cost); err != nil {
+			return nil, err
+		}
+		key := dbType + "/" + sqlType
+		st, ok := stats[key]
+		if !ok {
+			st = &stat{dbType: dbType, sqlType: sqlType}
+			stats[key] = st
+			keys = append(keys, key)
+		}
+		if progress == "已失败" {
+			st.failed++
+			continue
+		}
+		st.success++
+		// 执行耗时记录为time.Duration的字符串格式
+		if d, err := time.ParseDuration(cost); err == nil {
+			st.costCount++
+			st.costSum += d
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	table := &StatisticsTable{Columns: []string{"db_type", "sql_type", "tasks", "success", "failed", "success_rate", "failure_rate", "avg_execute_seconds"}}
+	for _, key := range keys {
+		st := stats[key]
+		total := st.success + st.failed
+		var avg float64
+		if st.costCount > 0 {
+			avg = round(st.costSum.Seconds()/float64(st.costCount), 3)
+		}
+		table.Rows = append(table.Rows, map[string]interface{}{
+			"db_type":             st.dbType,
+			"sql_type":            st.sqlType,
+			"tasks":               total,
+			"success":             st.success,
+			"failed":              st.failed,
+			"success_rate":        round(float64(st.success)/float64(total), 4),
+			"failure_rate":        round(float64(st.failed)/float64(total), 4),
+			"avg_execute_seconds": avg,
+		})
+	}
+	return table, nil
+}
+
+// 修改次数最多的表，按执行成功的DML和DDL任务统计
+type TableStatisticsService struct {
+	*forms.TableStatisticsForm
+	C *gin.Context
+}
+
+func (s *TableStatisticsService) Run() (*StatisticsTable, error) {
+	limit := s.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	tx, err := statisticsScope(global.App.DB.Table("`insight_order_tasks` t"), &s.StatisticsForm)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Select("IF(IFNULL(t.`schema`, '')='', a.`schema`, t.`schema`) as `schema`, t.`sql`, IFNULL(JSON_EXTRACT(t.result, '$.affected_rows'), 0) as affected_rows").
+		Joins("join insight_order_records a on a.order_id=t.order_id").
+		Where("t.progress='已完成' and t.db_type<>'ClickHouse' and t.sql_type in ?", []string{"DML", "DDL"}).
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type stat struct {
+		table        string
+		tasks        int64
+		affectedRows int64
+	}
+	stats := map[string]*stat{}
+	for rows.Next() {
+		var schema, sql string
+		var affectedRows int64
+		if err := rows.Scan(&schema, &sql, &affectedRows); err != nil {
+			return nil, err
+		}
+		tables, err := parser.ExtractModifiedTables(sql, schema)
+		if err != nil {
+			continue
+		}
+		for _, t := range tables {
+			st, ok := stats[t]
+			if !ok {
+				st = &stat{table: t}
+				stats[t] = st
+			}
+			st.tasks++
+			st.affectedRows += affectedRows
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	list := make([]*stat, 0, len(stats))
+	for _, st := range stats {
+		list = append(list, st)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].tasks != list[j].tasks {
+			return list[i].tasks > list[j].tasks
+		}
+		return list[i].table < list[j].table
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	table := &StatisticsTable{Columns: []string{"table", "tasks", "affected_rows"}}
+	for _, st := range list {
+		table.Rows = append(table.Rows, map[string]interface{}{
+			"table":         st.table,
+			"tasks":         st.tasks,
+			"affected_rows": st.affectedRows,
+		})
+	}
+	return table, nil
+}
+
+// 回滚频率：每个周期执行完成的DML/DDL工单数和回滚工单数
+type RollbackStatisticsService struct {
+	*forms.RollbackStatisticsForm
+	C *gin.Context
+}
+
+func (s *RollbackStatisticsService) Run() (*StatisticsTable, error) {
+	tx, err := statisticsScope(global.App.DB.Table("`insight_order_records` a"), &s.StatisticsForm)
+	if err != nil {
+		return nil, err
+	}
+	type row struct {
+		Period    string
+		Orders    int64
+		Rollbacks int64
+	}
+	var rows []row
+	isRollback := "IFNULL(a.rollback_order_id, '') not in ('', ?)"
+	if err := tx.Select(fmt.Sprintf("%s as period, sum(not %s and a.progress in ?) as orders, sum(%s) as rollbacks", periodExpr(s.Interval), isRollback, isRollback),
+		uuid.Nil.String(), finishedProgress, uuid.Nil.String()).
+		Where("a.sql_type in ?", []string{"DML", "DDL"}).
+		Group("period").
+		Order("period asc").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	table := &StatisticsTable{Columns: []string{"period", "orders", "rollbacks", "rollback_rate"}}
+	for _, r := range rows {
+		var rate float64
+		if r.Orders > 0 {
+			rate = round(float64(r.Rollbacks)/float64(r.Orders), 4)
+		}
+		table.Rows = append(table.Rows, map[string]interface{}{
+			"period":        r.Period,
+			"orders":        r.Orders,
+			"rollbacks":     r.Rollbacks,
+			"rollback_rate": rate,
+		})
+	}
+	return table, nil
+}
@@ -52,9 +52,20 @@ func (s *SyntaxInspectService) inspectSQL(config commonModels.InsightDBConfig) (
 }
 
 func (s *SyntaxInspectService) Run() (interface{}, error) {
+	// 使用SQL片段时，校验参数并替换占位符后再审核
+	if s.SnippetID != 0 {
+		content, err := contentFromSnippet(s.Username, s.SnippetID, s.SnippetParams, s.DBType, s.SQLType)
+		if err != nil {
+			return nil, err
+		}
+		s.Content = content
+	}
 	// 判断SQL类型是否匹配，DML工单仅允许提交DML语句，DDL工单仅允许提交DDL语句
-	err := parser.CheckSqlType(s.Content, string(s.SQLType))
-	if err != nil {
+	checkSqlType := parser.CheckSqlType
+	if s.DBType == "ClickHouse" {
+		checkSqlType = parser.CheckClickHouseSqlType
+	}
+	if err := checkSqlType(s.Content, string(s.SQLType)); err != nil {
 		return nil, err
 	}
 	if s.SQLType == "EXPORT" {
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	commonModels "goInsight/internal/common/models"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/utils"
+
+	"github.com/google/uuid"
+)
+
+// 单个工单展开后允许的最大目标数
+const maxOrderTargets = 256
+
+// 解析工单的执行目标，展开库名匹配模式，返回目标和对应的实例配置
+// 没有指定Targets时，使用工单的InstanceID和Schema作为唯一的目标
+func (s *CreateOrdersService) resolveTargets() ([]models.OrderTarget, map[uuid.UUID]commonModels.InsightDBConfig, error) {
+	configs := make(map[uuid.UUID]commonModels.InsightDBConfig)
+	getConfig := func(instanceID uuid.UUID) (commonModels.InsightDBConfig, error) {
+		if config, ok := configs[instanceID]; ok {
+			return config, nil
+		}
+		var config commonModels.InsightDBConfig
+		tx := global.App.DB.Table("`insight_db_config`").
+			Where("instance_id=? and db_type=? and environment=? and use_type='工单'", instanceID, s.DBType, s.Environment).
+			Take(&config)
+		if tx.RowsAffected == 0 {
+			return config, fmt.Errorf("实例`%s`不存在或不是当前环境的%s工单实例", instanceID, s.DBType)
+		}
+		configs[instanceID] = config
+		return config, nil
+	}
+
+	if len(s.Targets) == 0 {
+		instanceID, err := utils.ParserUUID(s.InstanceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 单目标工单沿用原有逻辑，不限制实例的环境
+		var config commonModels.InsightDBConfig
+		global.App.DB.Table("`insight_db_config`").Where("instance_id=?", instanceID).First(&config)
+		configs[instanceID] = config
+		return []models.OrderTarget{{InstanceID: instanceID, Schema: s.Schema}}, configs, nil
+	}
+
+	var targets []models.OrderTarget
+	seen := make(map[models.OrderTarget]bool)
+	add := func(target models.OrderTarget) {
+		if !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	for _, t := range s.Targets {
+		instanceID, err := utils.ParserUUID(t.InstanceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		config, err := getConfig(instanceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.SchemaPattern == "" {
+			add(models.OrderTarget{InstanceID: instanceID, Schema: t.Schema})
+			continue
+		}
+		// 按模式匹配实例已采集的库
+		var schemas []string
+		global.App.DB.Table("`insight_db_schemas`").
+			Where("instance_id=? and is_deleted=0 and `schema` like ?", instanceID, t.SchemaPattern).
+			Order("`schema` asc").
+			Pluck("schema", &schemas)
+		if len(schemas) == 0 {
+			return nil, nil, fmt.Errorf("实例%s:%d没有匹配`%s`的库", config.Hostname, config.Port, t.SchemaPattern)
+		}
+		for _, schema := range schemas {
+			add(models.OrderTarget{InstanceID: instanceID, Schema: schema})
+		}
+	}
+	if len(targets) > maxOrderTargets {
+		return nil, nil, fmt.Errorf("工单的目标数%d超过了最大限制%d", len(targets), maxOrderTargets)
+	}
+	return targets, configs, nil
+}
+
+// 获取工单的执行目标，单目标工单返回工单的实例和库
+func getOrderTargets(record models.InsightOrderRecords) ([]models.OrderTarget, error) {
+	if len(record.Targets) == 0 {
+		return []models.OrderTarget{{InstanceID: record.InstanceID, Schema: record.Schema}}, nil
+	}
+	var targets []models.OrderTarget
+	if err := json.Unmarshal(record.Targets, &targets); err != nil {
+		return nil, fmt.Errorf("解析工单的目标失败：%s", err.Error())
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("工单没有执行目标")
+	}
+	return targets, nil
+}
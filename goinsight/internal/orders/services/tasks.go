@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"goInsight/global"
+	inspectModels "goInsight/internal/inspect/models"
 	"goInsight/internal/orders/api/base"
 	"goInsight/internal/orders/api/execute"
 	"goInsight/internal/orders/forms"
@@ -13,6 +14,7 @@ import (
 	"goInsight/pkg/pagination"
 	"goInsight/pkg/parser"
 	"goInsight/pkg/utils"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -42,30 +44,42 @@ func GenerateTasks(tx *gorm.DB, record ordersModels.InsightOrderRecords) error {
 	}
 
 	// Split SQL
-	sqls, err := parser.SplitSQLText(record.Content)
+	splitSQLText := parser.SplitSQLText
+	if record.DBType == "ClickHouse" {
+		splitSQLText = parser.SplitClickHouseSQLText
+	}
+	sqls, err := splitSQLText(record.Content)
 	if err != nil {
 		return err
 	}
 
-	// Create tasks
+	// Create tasks, multi-target orders run every statement on each target
+	targets, err := getOrderTargets(record)
+	if err != nil {
+		return err
+	}
 	var tasks []map[string]interface{}
-	for _, sql := range sqls {
-		tasks = append(tasks, map[string]interface{}{
-			"OrderID":    record.OrderID,
-			"TaskID":     uuid.New(),
-			"DBType":     record.DBType,
-			"SQLType":    record.SQLType,
-			"SQL":        sql,
-			"created_at": time.Now().Format("2006-01-02 15:04:05"),
-			"updated_at": time.Now().Format("2006-01-02 15:04:05"),
-		})
+	for _, target := range targets {
+		for _, sql := range sqls {
+			tasks = append(tasks, map[string]interface{}{
+				"OrderID":    record.OrderID,
+				"TaskID":     uuid.New(),
+				"InstanceID": target.InstanceID,
+				"Schema":     target.Schema,
+				"DBType":     record.DBType,
+				"SQLType":    record.SQLType,
+				"SQL":        sql,
+				"created_at": time.Now().Format("2006-01-02 15:04:05"),
+				"updated_at": time.Now().Format("2006-01-02 15:04:05"),
+			})
+		}
 	}
 
-	if err := tx.Model(&ordersModels.InsightOrderTasks{}).Create(tasks).Error; err != nil {
+	if err := tx.Model(&ordersModels.InsightOrderTasks{}).CreateInBatches(tasks, 500).Error; err != nil {
 		global.App.Log.Error(err)
 		return err
 	}
-	return nil
+	return bindMigrationTasks(tx, record.OrderID, tasks)
 }
 
 func (s *GenerateTasksService) Run() (err error) {
@@ -92,7 +106,7 @@ func (s *GenerateTasksService) Run() (err error) {
 	if !s.subTasksExist() {
 		return nil
 	}
-	
+
 	return global.App.DB.Transaction(func(tx *gorm.DB) error {
 		return GenerateTasks(tx, record)
 	})
@@ -119,6 +133,13 @@ func (s *GetTasksServices) Run() (responseData interface{}, total int64, err err
 	if s.Progress != "" {
 		tx = tx.Where("progress=?", s.Progress)
 	}
+	// 多目标工单按目标过滤
+	if s.InstanceID != "" {
+		tx = tx.Where("instance_id=?", s.InstanceID)
+	}
+	if s.Schema != "" {
+		tx = tx.Where("`schema`=?", s.Schema)
+	}
 	total = pagination.Pager(&s.PaginationQ, tx, &records)
 	return &records, total, nil
 }
@@ -147,8 +168,44 @@ func (s *PreviewTasksServices) Run() (responseData interface{}, err error) {
 	return records, nil
 }
 
+// 获取每个目标的任务进度
+type GetTaskTargetsServices struct {
+	*forms.GetTaskTargetsForm
+	C *gin.Context
+}
+
+func (s *GetTaskTargetsServices) Run() (responseData interface{}, err error) {
+	type record struct {
+		InstanceID string `json:"instance_id"`
+		Instance   string `json:"instance"`
+		Schema     string `json:"schema"`
+		Total      int    `json:"total"`
+		Unexecuted int    `json:"unexecuted"`
+		Processing int    `json:"processing"`
+		Completed  int    `json:"completed"`
+		Failed     int    `json:"failed"`
+		Paused     int    `json:"paused"`
+	}
+	var order ordersModels.InsightOrderRecords
+	if global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&order).RowsAffected == 0 {
+		return nil, fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	// 历史任务没有记录目标，使用工单的实例和库
+	var records []record
+	global.App.DB.Table("`insight_order_tasks` a").
+		Select("IF(IFNULL(a.instance_id,'')='', ?, a.instance_id) as instance_id, concat(b.hostname, ':', b.port) as instance, IF(IFNULL(a.instance_id,'')='', ?, a.`schema`) as `schema`, COUNT(*) as total, SUM(if(a.progress='未执行',1,0)) as unexecuted, SUM(if(a.progress='执行中',1,0)) as processing, SUM(if(a.progress='已完成',1,0)) as completed, SUM(if(a.progress='已失败',1,0)) as failed, SUM(if(a.progress='已暂停',1,0)) as paused", order.InstanceID, order.Schema).
+		Joins("left join insight_db_config b on b.instance_id=IF(IFNULL(a.instance_id,'')='', ?, a.instance_id)", order.InstanceID).
+		Where("a.order_id=?", s.OrderID).
+		Group("1, 2, 3").
+		Order("MIN(a.id) asc").
+		Scan(&records)
+	return records, nil
+}
+
 // 检查工单所有任务是否完成，如果所有子任务已完成，更新工单状态为已完成
 func updateOrderStatusToFinish(order_id string) {
+	// 迁移工单按脚本更新版本状态
+	updateMigrationVersions(order_id)
 	// 判断所有任务是否都完成
 	type TaskCount struct {
 		Count int64
@@ -163,6 +220,8 @@ func updateOrderStatusToFinish(order_id string) {
 		global.App.DB.Model(&ordersModels.InsightOrderRecords{}).
 			Where("order_id=?", order_id).
 			Update("progress", "已完成")
+		// 执行校验查询
+		go runOrderVerifications(order_id, "")
 
 		// 发送通知消息
 		var record ordersModels.InsightOrderRecords
@@ -175,6 +234,7 @@ func updateOrderStatusToFinish(order_id string) {
 			record.Title,
 		)
 		notifier.SendMessage(record.Title, order_id, receiver, msg)
+		dispatchOrderEvent(record, EventCompleted, "", "")
 	}
 }
 
@@ -241,6 +301,14 @@ func sendExportFileInfoToApplicant(task_id uuid.UUID) {
 	var file base.ExportFile
 	_ = json.Unmarshal([]byte(task.Result), &file)
 
+	expireAt := file.ExpireAt
+	if expireAt == "" {
+		expireAt = "永不过期"
+	}
+	maskedColumns := "无"
+	if len(file.MaskedColumns) > 0 {
+		maskedColumns = strings.Join(file.MaskedColumns, "; ")
+	}
 	receiver := []string{record.Applicant}
 	msg := fmt.Sprintf(
 		"您好，导出文件信息如下，请查收\n"+
@@ -251,6 +319,8 @@ func sendExportFileInfoToApplicant(task_id uuid.UUID) {
 			">数据行数：%d\n"+
 			">文件解密密码：%s\n"+
 			">文件格式：%s\n"+
+			">文件过期时间：%s\n"+
+			">数据脱敏：%s\n"+
 			">文件下载路径：%s",
 		record.Title, task_id.String(),
 		file.FileName,
@@ -258,15 +328,40 @@ func sendExportFileInfoToApplicant(task_id uuid.UUID) {
 		file.ExportRows,
 		file.EncryptionKey,
 		file.ContentType,
+		expireAt,
+		maskedColumns,
 		file.DownloadUrl,
 	)
 	notifier.SendMessage(record.Title, record.OrderID.String(), receiver, msg)
 }
 
-// 执行任务
-func executeTask(task ordersModels.InsightOrderTasks) (string, error) {
-	// 获取DB配置信息
+// 获取生效的MAX_AFFECTED_ROWS审核参数，实例的审核参数优先于全局参数
+func getMaxAffectedRows(instanceParams string) int {
+	var rows []inspectModels.InsightInspectParams
+	global.App.DB.Model(&inspectModels.InsightInspectParams{}).Scan(&rows)
+	params := make([][]byte, 0, len(rows)+1)
+	for _, row := range rows {
+		params = append(params, row.Params)
+	}
+	if instanceParams != "" {
+		params = append(params, []byte(instanceParams))
+	}
+	var maxAffectedRows int
+	for _, p := range params {
+		var data struct {
+			MaxAffectedRows *int `json:"MAX_AFFECTED_ROWS"`
+		}
+		if err := json.Unmarshal(p, &data); err == nil && data.MaxAffectedRows != nil {
+			maxAffectedRows = *data.MaxAffectedRows
+		}
+	}
+	return maxAffectedRows
+}
+
+// 获取任务关联的DB配置信息
+func getTaskDBConfig(task ordersModels.InsightOrderTasks) (*base.DBConfig, error) {
 	type Record struct {
+		InstanceID       uuid.UUID
 		Hostname         string
 		Port             uint16
 		UserName         string
@@ -275,18 +370,41 @@ func executeTask(task ordersModels.InsightOrderTasks) (string, error) {
 		DBType           string
 		SQLType          string
 		ExportFileFormat string
+		CSVDelimiter     string
+		CSVEncoding      string
+		CSVWithBOM       bool
+		IsBatchExecute   bool
+		Replicas         string
+		InspectParams    string
+		OSCEngine        string
 	}
 	var record Record
 	tx := global.App.DB.Table("`insight_order_records` a").
-		Select("a.db_type,a.sql_type,a.schema,a.export_file_format,b.hostname,b.port,b.user_name,b.password").
-		Joins("join `insight_db_config` b on a.instance_id=b.instance_id").
-		Where("a.order_id=?", task.OrderID).Take(&record)
+		Select("a.instance_id,a.db_type,a.sql_type,a.schema,a.export_file_format,a.csv_delimiter,a.csv_encoding,a.csv_with_bom,a.is_batch_execute,b.hostname,b.port,b.user_name,b.password,IFNULL(b.replicas,'') as replicas,IFNULL(b.inspect_params,'') as inspect_params,b.osc_engine")
+	// 任务记录了目标实例时使用任务的实例和库，兼容没有记录目标的历史任务
+	if task.InstanceID != uuid.Nil {
+		tx = tx.Select("b.instance_id,a.db_type,a.sql_type,? as `schema`,a.export_file_format,a.csv_delimiter,a.csv_encoding,a.csv_with_bom,a.is_batch_execute,b.hostname,b.port,b.user_name,b.password,IFNULL(b.replicas,'') as replicas,IFNULL(b.inspect_params,'') as inspect_params,b.osc_engine", task.Schema).
+			Joins("join `insight_db_config` b on b.instance_id=?", task.InstanceID)
+	} else {
+		tx = tx.Joins("join `insight_db_config` b on a.instance_id=b.instance_id")
+	}
+	tx = tx.Where("a.order_id=?", task.OrderID).Take(&record)
 	if tx.RowsAffected == 0 {
-		returnData := base.ReturnData{Error: "执行失败，没有发现工单关联的数据库信息"}
-		data, _ := json.Marshal(returnData)
-		return string(data), errors.New("执行失败，没有发现工单关联的数据库信息")
+		return nil, errors.New("执行失败，没有发现工单关联的数据库信息")
 	}
-	config := base.DBConfig{
+	// 实例登记的从库，用于执行前后检查复制延迟
+	var replicas []string
+	if record.Replicas != "" {
+		if err := json.Unmarshal([]byte(record.Replicas), &replicas); err != nil {
+			global.App.Log.Error(err)
+		}
+	}
+	// 导出工单使用实例的脱敏规则
+	var maskingRules []base.MaskingRule
+	if record.SQLType == "EXPORT" {
+		maskingRules = getMaskingRules(record.InstanceID)
+	}
+	return &base.DBConfig{
 		Hostname:         record.Hostname,
 		Port:             record.Port,
 		UserName:         record.UserName,
@@ -295,15 +413,39 @@ func executeTask(task ordersModels.InsightOrderTasks) (string, error) {
 		DBType:           record.DBType,
 		SQLType:          record.SQLType,
 		ExportFileFormat: record.ExportFileFormat,
+		CSVDelimiter:     record.CSVDelimiter,
+		CSVEncoding:      record.CSVEncoding,
+		CSVWithBOM:       record.CSVWithBOM,
+		BatchExecute:     record.IsBatchExecute,
+		Replicas:         replicas,
+		OSCEngine:        record.OSCEngine,
+		MaskingRules:     maskingRules,
+		MaskingSecret:    maskingSecret(),
+		MaxAffectedRows:  getMaxAffectedRows(record.InspectParams),
 		SQL:              task.SQL,
 		OrderID:          task.OrderID.String(),
 		TaskID:           task.TaskID.String(),
+	}, nil
+}
+
+// 执行任务
+func executeTask(task ordersModels.InsightOrderTasks) (string, error) {
+	config, err := getTaskDBConfig(task)
+	if err != nil {
+		data, _ := json.Marshal(base.ReturnData{Error: err.Error()})
+		return string(data), err
 	}
 	// 执行工单
-	executor := execute.NewExecuteSQLAPI(&config)
+	executor := execute.NewExecuteSQLAPI(config)
 	returnData, err := executor.Run()
 	if err != nil {
 		base.PublishMessageToChannel(task.OrderID.String(), err.Error(), "")
+	} else if config.SQLType == "EXPORT" {
+		// 导出文件保存到配置的存储中
+		if err = storeExportFile(config, &returnData); err != nil {
+			returnData.Error = err.Error()
+			base.PublishMessageToChannel(task.OrderID.String(), err.Error(), "")
+		}
 	}
 	// 转换为json
 	data, _ := json.Marshal(returnData)
@@ -328,6 +470,12 @@ func (s *ExecuteSingleTaskService) Run() (err error) {
 	if tx.RowsAffected == 0 {
 		return fmt.Errorf("任务ID为`%d`的记录不存在", s.ID)
 	}
+	// 事务模式的工单需要所有任务在同一个事务中执行
+	var order ordersModels.InsightOrderRecords
+	global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&order)
+	if order.IsTransaction {
+		return errors.New("当前工单为事务模式，请使用批量执行")
+	}
 	// 跳过已完成的任务
 	if task.Progress == "已完成" {
 		return errors.New("当前任务已完成，请勿重复执行")
@@ -360,6 +508,9 @@ func (s *ExecuteSingleTaskService) Run() (err error) {
 	}(); err != nil {
 		return err
 	}
+	if order.Progress == "已批准" {
+		dispatchOrderEvent(order, EventExecuting, s.Username, "")
+	}
 
 	// 执行任务
 	data, err := executeTask(task)
@@ -379,6 +530,9 @@ func (s *ExecuteSingleTaskService) Run() (err error) {
 		global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
 			Where("id=? and order_id=?", s.ID, s.OrderID).
 			Updates(map[string]interface{}{"progress": taskProgress, "result": data})
+		if taskProgress == "已失败" {
+			dispatchOrderEvent(order, EventFailed, s.Username, fmt.Sprintf("任务%s执行失败：%s", task.TaskID.String(), err.Error()))
+		}
 		return err
 	}
 
@@ -413,8 +567,10 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 	}
 	//  判断当前工单的所有任务中是否存在已暂停的任务，如果存在，不执行；可手动执行单个任务
 	if !checkTasksProgressIsPause(s.OrderID) {
-		return "", "", errors.New("当前有任务正在执行中，请先等待执行完成")
+		return "", "", errors.New("当前有已暂停的任务，请先恢复执行")
 	}
+	var order ordersModels.InsightOrderRecords
+	global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&order)
 	// 更新当前工单进度为执行中
 	if err := global.App.DB.Model(&ordersModels.InsightOrderRecords{}).
 		Where("order_id=?", s.OrderID).
@@ -428,8 +584,16 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 	if tx.RowsAffected == 0 {
 		return "", "", errors.New("任务记录不存在")
 	}
+	if order.Progress == "已批准" {
+		dispatchOrderEvent(order, EventExecuting, s.Username, "")
+	}
+	// 事务模式下所有任务在同一个事务中执行
+	if order.IsTransaction {
+		return s.executeInTransaction(order, tasks)
+	}
 
-	var executedCount, successCount, failCount int
+	var executedCount, successCount, failCount, pausedCount int
+	var failedTasks []string
 
 	// 执行任务
 	for _, task := range tasks {
@@ -438,6 +602,14 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 			continue
 		}
 
+		// 执行过程中任务可能被暂停，执行前重新获取任务进度
+		var current ordersModels.InsightOrderTasks
+		global.App.DB.Table("`insight_order_tasks`").Where("task_id=?", task.TaskID).Take(&current)
+		if current.Progress == "已暂停" {
+			pausedCount++
+			continue
+		}
+
 		executedCount++
 
 		// 更新当前任务进度为执行中
@@ -467,6 +639,9 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 			global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
 				Where("task_id=?", task.TaskID).
 				Updates(map[string]interface{}{"progress": taskProgress, "result": data})
+			if taskProgress == "已失败" {
+				failedTasks = append(failedTasks, task.TaskID.String())
+			}
 		} else {
 			successCount++
 			global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
@@ -479,10 +654,16 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 	}
 	// 更新工单状态为已完成
 	updateOrderStatusToFinish(s.OrderID)
+	if len(failedTasks) > 0 {
+		dispatchOrderEvent(order, EventFailed, s.Username, fmt.Sprintf("%d个任务执行失败：%s", len(failedTasks), strings.Join(failedTasks, ",")))
+	}
 
 	var msgResult, typeResult string
 
-	if executedCount == 0 {
+	if pausedCount > 0 {
+		msgResult = fmt.Sprintf("任务已暂停，剩余%d个任务未执行", pausedCount)
+		typeResult = "warning"
+	} else if executedCount == 0 {
 		msgResult = "没有需要执行的任务"
 		typeResult = "warning"
 	} else if successCount == executedCount {
@@ -503,3 +684,217 @@ func (s *ExecuteAllTaskService) Run() (msg string, msgType string, err error) {
 
 	return msgResult, typeResult, nil
 }
+
+// 暂停任务，当前执行中的任务执行完成后，不再执行剩余的任务
+type PauseTasksService struct {
+	*forms.PauseTasksForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *PauseTasksService) Run() (err error) {
+	if err = checkOrderStatus(s.OrderID, s.Username); err != nil {
+		return err
+	}
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ordersModels.InsightOrderTasks{}).
+			Where("order_id=? and progress=?", s.OrderID, "未执行").
+			Update("progress", "已暂停")
+		if result.Error != nil {
+			global.App.Log.Error(result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("没有可暂停的任务")
+		}
+		// 操作日志
+		orderID, err := utils.ParserUUID(s.OrderID)
+		if err != nil {
+			return err
+		}
+		logMsg := fmt.Sprintf("用户%s暂停了工单的%d个任务，附加消息：%s", s.Username, result.RowsAffected, s.Msg)
+		if err := CreateOpLogs(tx, orderID, s.Username, logMsg); err != nil {
+			return err
+		}
+		base.PublishMessageToChannel(s.OrderID, logMsg, "")
+		return nil
+	})
+}
+
+// 恢复执行已暂停的任务
+type ResumeTasksService struct {
+	*forms.ResumeTasksForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *ResumeTasksService) Run() (err error) {
+	if err = checkOrderStatus(s.OrderID, s.Username); err != nil {
+		return err
+	}
+	if !checkTasksProgressIsDoing(s.OrderID) {
+		return errors.New("当前有任务正在执行中，请先等待执行完成")
+	}
+	err = global.App.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&ordersModels.InsightOrderTasks{}).
+			Where("order_id=? and progress=?", s.OrderID, "已暂停").
+			Update("progress", "未执行")
+		if result.Error != nil {
+			global.App.Log.Error(result.Error)
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("没有已暂停的任务")
+		}
+		// 操作日志
+		orderID, err := utils.ParserUUID(s.OrderID)
+		if err != nil {
+			return err
+		}
+		logMsg := fmt.Sprintf("用户%s恢复执行工单的%d个任务，附加消息：%s", s.Username, result.RowsAffected, s.Msg)
+		if err := CreateOpLogs(tx, orderID, s.Username, logMsg); err != nil {
+			return err
+		}
+		base.PublishMessageToChannel(s.OrderID, logMsg, "")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 后台继续执行剩余的任务
+	go func() {
+		service := ExecuteAllTaskService{
+			ExecuteAllTaskForm: &forms.ExecuteAllTaskForm{OrderID: s.OrderID},
+			Username:           s.Username,
+		}
+		msg, _, err := service.Run()
+		if err != nil {
+			msg = err.Error()
+		}
+		base.PublishMessageToChannel(s.OrderID, fmt.Sprintf("恢复执行结束：%s", msg), "")
+	}()
+	return nil
+}
+
+// 终止执行中的任务，同时暂停剩余的任务
+type CancelTaskService struct {
+	*forms.CancelTaskForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *CancelTaskService) Run() (err error) {
+	if err = checkOrderStatus(s.OrderID, s.Username); err != nil {
+		return err
+	}
+	// 获取执行中的任务
+	var task ordersModels.InsightOrderTasks
+	tx := global.App.DB.Table("`insight_order_tasks`").Where("order_id=? and progress=?", s.OrderID, "执行中")
+	if s.TaskID != "" {
+		tx = tx.Where("task_id=?", s.TaskID)
+	}
+	if tx.Take(&task).RowsAffected == 0 {
+		if s.TaskID != "" {
+			return fmt.Errorf("任务`%s`不是执行中的状态", s.TaskID)
+		}
+		return errors.New("当前没有执行中的任务")
+	}
+	// 终止执行中的语句
+	if err := base.CancelRunningTask(task.TaskID.String()); err != nil {
+		return fmt.Errorf("终止任务失败，错误：%s", err.Error())
+	}
+	// 终止成功后暂停剩余的任务，避免批量执行继续执行下一个任务
+	if err := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
+		Where("order_id=? and progress=?", s.OrderID, "未执行").
+		Update("progress", "已暂停").Error; err != nil {
+		global.App.Log.Error(err)
+		return err
+	}
+	// 操作日志
+	logMsg := fmt.Sprintf("用户%s终止了执行中的任务%s，附加消息：%s", s.Username, task.TaskID.String(), s.Msg)
+	if err := CreateOpLogs(global.App.DB, task.OrderID, s.Username, logMsg); err != nil {
+		return err
+	}
+	base.PublishMessageToChannel(s.OrderID, logMsg, "")
+	return nil
+}
+
+// 事务模式待执行的任务，已暂停的任务需要用户恢复后再执行，不加入事务
+func transactionPendingTasks(tasks []ordersModels.InsightOrderTasks) (pending []ordersModels.InsightOrderTasks) {
+	for _, task := range tasks {
+		if task.Progress == "未执行" {
+			pending = append(pending, task)
+		}
+	}
+	return pending
+}
+
+// 事务执行后任务的进度，事务已提交时（包括生成回滚SQL失败）为已完成；
+// 事务回滚时没有提交任何修改，任务恢复为未执行，修正后可以重新执行
+func transactionTaskProgress(err error) string {
+	if err != nil {
+		if _, ok := err.(base.RollbackSQLError); !ok {
+			return "未执行"
+		}
+	}
+	return "已完成"
+}
+
+// 事务模式执行所有未执行的任务，任一任务失败时所有任务均回滚
+func (s *ExecuteAllTaskService) executeInTransaction(order ordersModels.InsightOrderRecords, tasks []ordersModels.InsightOrderTasks) (msg string, msgType string, err error) {
+	pending := transactionPendingTasks(tasks)
+	var transactionTasks []base.TransactionTask
+	for _, task := range pending {
+		transactionTasks = append(transactionTasks, base.TransactionTask{TaskID: task.TaskID.String(), SQL: task.SQL})
+	}
+	if len(pending) == 0 {
+		updateOrderStatusToFinish(s.OrderID)
+		return "没有需要执行的任务", "warning", nil
+	}
+	config, err := getTaskDBConfig(pending[0])
+	if err != nil {
+		return "", "", err
+	}
+	// 更新任务进度为执行中
+	taskIDs := make([]uuid.UUID, 0, len(pending))
+	for _, task := range pending {
+		taskIDs = append(taskIDs, task.TaskID)
+	}
+	if err := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
+		Where("task_id in ?", taskIDs).
+		Update("progress", "执行中").Error; err != nil {
+		global.App.Log.Error(err)
+		return "", "", err
+	}
+
+	// 执行事务
+	results, err := execute.ExecuteInTransaction(config, transactionTasks)
+	taskProgress := transactionTaskProgress(err)
+	for i, task := range pending {
+		var returnData base.ReturnData
+		if i < len(results) {
+			returnData = results[i]
+		} else if err != nil {
+			returnData.Error = err.Error()
+		}
+		data, _ := json.Marshal(returnData)
+		global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
+			Where("task_id=?", task.TaskID).
+			Updates(map[string]interface{}{"progress": taskProgress, "result": string(data)})
+	}
+	// 更新工单状态为已完成
+	updateOrderStatusToFinish(s.OrderID)
+
+	msg, msgType = "执行成功", "success"
+	if taskProgress == "未执行" {
+		msg, msgType = "执行失败，事务已回滚，任务已恢复为未执行，修正后可重新执行", "error"
+		dispatchOrderEvent(order, EventFailed, s.Username, fmt.Sprintf("事务执行失败，已回滚：%s", err.Error()))
+	}
+
+	// 更新执行结果
+	global.App.DB.Model(&ordersModels.InsightOrderRecords{}).
+		Where("order_id=?", s.OrderID).
+		Update("execute_result", msgType)
+
+	return msg, msgType, nil
+}
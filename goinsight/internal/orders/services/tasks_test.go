@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"goInsight/internal/common/models"
+	"goInsight/internal/orders/api/base"
+	ordersModels "goInsight/internal/orders/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionRetry(t *testing.T) {
+	tasks := []ordersModels.InsightOrderTasks{{Progress: "未执行"}, {Progress: "未执行"}, {Progress: "已暂停"}}
+	pending := transactionPendingTasks(tasks)
+	assert.Len(t, pending, 2)
+
+	// 事务回滚后任务恢复为未执行，再次批量执行时重新加入事务
+	progress := transactionTaskProgress(base.SQLExecuteError{Err: errors.New("Duplicate entry")})
+	assert.Equal(t, "未执行", progress)
+	for i := range pending {
+		pending[i].Progress = models.EnumType(progress)
+	}
+	assert.Len(t, transactionPendingTasks(pending), 2)
+
+	// 重新执行提交成功，生成回滚SQL失败不影响任务状态
+	assert.Equal(t, "已完成", transactionTaskProgress(nil))
+	progress = transactionTaskProgress(base.RollbackSQLError{Err: errors.New("binlog")})
+	assert.Equal(t, "已完成", progress)
+	for i := range pending {
+		pending[i].Progress = models.EnumType(progress)
+	}
+	assert.Empty(t, transactionPendingTasks(pending))
+}
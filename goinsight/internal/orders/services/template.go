@@ -0,0 +1,302 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	commonModels "goInsight/internal/common/models"
+	"goInsight/internal/orders/forms"
+	ordersModels "goInsight/internal/orders/models"
+	usersModels "goInsight/internal/users/models"
+	"goInsight/pkg/pagination"
+	"goInsight/pkg/snippet"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// 获取用户当前绑定的组织和所有上级组织
+func getUserOrganizationKeys(username string) (current string, keys []string) {
+	var organization usersModels.InsightOrganizations
+	global.App.DB.Table("`insight_organizations` a").
+		Joins("join insight_organizations_users b on a.key = b.organization_key").
+		Joins("join insight_users c on c.uid = b.uid").Where("c.username=?", username).Scan(&organization)
+
+	// 将path json数据转换为数组
+	if len(organization.Path) != 0 {
+		_ = json.Unmarshal([]byte(organization.Path), &keys)
+	}
+	keys = append(keys, organization.Key)
+	return organization.Key, keys
+}
+
+// 用户可见的模板和片段：自己创建的个人模板，以及所属组织和上级组织的组织模板
+func visibleScope(tx *gorm.DB, username string) *gorm.DB {
+	_, keys := getUserOrganizationKeys(username)
+	return tx.Where("((scope='个人' and username=?) or (scope='组织' and organization_key in ?))", username, keys)
+}
+
+// 组织范围的记录关联创建人当前的组织
+func scopeOrganization(scope, username string) (string, error) {
+	if scope != "组织" {
+		return "", nil
+	}
+	current, _ := getUserOrganizationKeys(username)
+	if current == "" {
+		return "", errors.New("当前用户没有绑定组织，无法创建组织范围的记录")
+	}
+	return current, nil
+}
+
+type GetOrderTemplatesService struct {
+	*forms.GetOrderTemplatesForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetOrderTemplatesService) Run() (responseData interface{}, total int64, err error) {
+	var templates []ordersModels.InsightOrderTemplates
+	tx := visibleScope(global.App.DB.Table("`insight_order_templates`"), s.Username).Order("updated_at desc")
+	// 搜索
+	if s.Search != "" {
+		tx = tx.Where("`name` like ?", "%"+s.Search+"%")
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &templates)
+	return &templates, total, nil
+}
+
+func newOrderTemplate(form *forms.CreateOrderTemplateForm, username string) (*ordersModels.InsightOrderTemplates, error) {
+	organizationKey, err := scopeOrganization(form.Scope, username)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := json.Marshal(form.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return &ordersModels.InsightOrderTemplates{
+		Name:            form.Name,
+		Scope:           commonModels.EnumType(form.Scope),
+		Username:        username,
+		OrganizationKey: organizationKey,
+		Fields:          datatypes.JSON(fields),
+		Remark:          form.Remark,
+	}, nil
+}
+
+type CreateOrderTemplateService struct {
+	*forms.CreateOrderTemplateForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *CreateOrderTemplateService) Run() error {
+	template, err := newOrderTemplate(s.CreateOrderTemplateForm, s.Username)
+	if err != nil {
+		return err
+	}
+	return global.App.DB.Create(template).Error
+}
+
+type UpdateOrderTemplateService struct {
+	*forms.UpdateOrderTemplateForm
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *UpdateOrderTemplateService) Run() error {
+	template, err := newOrderTemplate(&s.CreateOrderTemplateForm, s.Username)
+	if err != nil {
+		return err
+	}
+	// 仅创建人可以修改
+	var count int64
+	global.App.DB.Model(&ordersModels.InsightOrderTemplates{}).Where("id=? and username=?", s.ID, s.Username).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("模板`%d`不存在或不是当前用户创建的", s.ID)
+	}
+	tx := global.App.DB.Model(&ordersModels.InsightOrderTemplates{}).Where("id=?", s.ID).Updates(map[string]interface{}{
+		"name":             template.Name,
+		"scope":            template.Scope,
+		"organization_key": template.OrganizationKey,
+		"fields":           template.Fields,
+		"remark":           template.Remark,
+	})
+	return tx.Error
+}
+
+type DeleteOrderTemplateService struct {
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *DeleteOrderTemplateService) Run() error {
+	tx := global.App.DB.Where("id=? and username=?", s.ID, s.Username).Delete(&ordersModels.InsightOrderTemplates{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("模板`%d`不存在或不是当前用户创建的", s.ID)
+	}
+	return nil
+}
+
+type GetSQLSnippetsService struct {
+	*forms.GetSQLSnippetsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetSQLSnippetsService) Run() (responseData interface{}, total int64, err error) {
+	var snippets []ordersModels.InsightSQLSnippets
+	tx := visibleScope(global.App.DB.Table("`insight_sql_snippets`"), s.Username).Order("updated_at desc")
+	if s.DBType != "" {
+		tx = tx.Where("db_type=?", s.DBType)
+	}
+	if s.SQLType != "" {
+		tx = tx.Where("sql_type=?", s.SQLType)
+	}
+	// 搜索
+	if s.Search != "" {
+		tx = tx.Where("`name` like ? or `content` like ?", "%"+s.Search+"%", "%"+s.Search+"%")
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &snippets)
+	return &snippets, total, nil
+}
+
+func newSQLSnippet(form *forms.CreateSQLSnippetForm, username string) (*ordersModels.InsightSQLSnippets, error) {
+	if err := snippet.Validate(form.Content, form.Params); err != nil {
+		return nil, err
+	}
+	organizationKey, err := scopeOrganization(form.Scope, username)
+	if err != nil {
+		return nil, err
+	}
+	params, err := json.Marshal(form.Params)
+	if err != nil {
+		return nil, err
+	}
+	return &ordersModels.InsightSQLSnippets{
+		Name:            form.Name,
+		Scope:           commonModels.EnumType(form.Scope),
+		Username:        username,
+		OrganizationKey: organizationKey,
+		DBType:          form.DBType,
+		SQLType:         form.SQLType,
+		Content:         form.Content,
+		Params:          datatypes.JSON(params),
+		Remark:          form.Remark,
+	}, nil
+}
+
+type CreateSQLSnippetService struct {
+	*forms.CreateSQLSnippetForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *CreateSQLSnippetService) Run() error {
+	record, err := newSQLSnippet(s.CreateSQLSnippetForm, s.Username)
+	if err != nil {
+		return err
+	}
+	return global.App.DB.Create(record).Error
+}
+
+type UpdateSQLSnippetService struct {
+	*forms.UpdateSQLSnippetForm
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *UpdateSQLSnippetService) Run() error {
+	record, err := newSQLSnippet(&s.CreateSQLSnippetForm, s.Username)
+	if err != nil {
+		return err
+	}
+	// 仅创建人可以修改
+	var count int64
+	global.App.DB.Model(&ordersModels.InsightSQLSnippets{}).Where("id=? and username=?", s.ID, s.Username).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("SQL片段`%d`不存在或不是当前用户创建的", s.ID)
+	}
+	tx := global.App.DB.Model(&ordersModels.InsightSQLSnippets{}).Where("id=?", s.ID).Updates(map[string]interface{}{
+		"name":             record.Name,
+		"scope":            record.Scope,
+		"organization_key": record.OrganizationKey,
+		"db_type":          record.DBType,
+		"sql_type":         record.SQLType,
+		"content":          record.Content,
+		"params":           record.Params,
+		"remark":           record.Remark,
+	})
+	return tx.Error
+}
+
+type DeleteSQLSnippetService struct {
+	C        *gin.Context
+	ID       uint64
+	Username string
+}
+
+func (s *DeleteSQLSnippetService) Run() error {
+	tx := global.App.DB.Where("id=? and username=?", s.ID, s.Username).Delete(&ordersModels.InsightSQLSnippets{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("SQL片段`%d`不存在或不是当前用户创建的", s.ID)
+	}
+	return nil
+}
+
+// 校验参数并替换用户可见的SQL片段中的占位符
+func renderSQLSnippet(username string, id uint64, values map[string]string) (*ordersModels.InsightSQLSnippets, string, error) {
+	var record ordersModels.InsightSQLSnippets
+	tx := visibleScope(global.App.DB.Table("`insight_sql_snippets`"), username).Where("id=?", id).Take(&record)
+	if tx.RowsAffected == 0 {
+		return nil, "", fmt.Errorf("SQL片段`%d`不存在或当前用户无权限使用", id)
+	}
+	var params []snippet.Param
+	if len(record.Params) > 0 {
+		if err := json.Unmarshal(record.Params, &params); err != nil {
+			return nil, "", fmt.Errorf("解析SQL片段的参数定义失败：%s", err.Error())
+		}
+	}
+	sqltext, err := snippet.Render(record.Content, params, values)
+	if err != nil {
+		return nil, "", err
+	}
+	return &record, sqltext, nil
+}
+
+// 使用SQL片段时，校验片段的DB类型和SQL类型，返回替换参数后的SQL
+func contentFromSnippet(username string, id uint64, values map[string]string, dbType, sqlType commonModels.EnumType) (string, error) {
+	record, sqltext, err := renderSQLSnippet(username, id, values)
+	if err != nil {
+		return "", err
+	}
+	if record.DBType != dbType || record.SQLType != sqlType {
+		return "", fmt.Errorf("SQL片段的类型为%s/%s，和工单的类型%s/%s不匹配", record.DBType, record.SQLType, dbType, sqlType)
+	}
+	return sqltext, nil
+}
+
+type RenderSQLSnippetService struct {
+	*forms.RenderSQLSnippetForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *RenderSQLSnippetService) Run() (interface{}, error) {
+	_, sqltext, err := renderSQLSnippet(s.Username, s.SnippetID, s.Params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"content": sqltext}, nil
+}
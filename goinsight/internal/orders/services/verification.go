@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	commonModels "goInsight/internal/common/models"
+	dasForms "goInsight/internal/das/forms"
+	dasModels "goInsight/internal/das/models"
+	dasParser "goInsight/internal/das/parser"
+	dasServices "goInsight/internal/das/services"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/notifier"
+	"goInsight/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 校验查询只允许单条SELECT语句，语法检查和DAS查询保持一致
+func checkVerificationSQL(sql string) error {
+	stmt, warns, err := dasParser.NewParse(sql, "", "")
+	if len(warns) > 0 {
+		return fmt.Errorf("Parse Warning: %s", utils.ErrsJoin("; ", warns))
+	}
+	if err != nil {
+		return fmt.Errorf("校验查询语法解析错误:%s", err.Error())
+	}
+	if len(stmt.Stmts) != 1 {
+		return fmt.Errorf("每个校验仅允许一条查询语句，当前语句数量:%d", len(stmt.Stmts))
+	}
+	st := dasParser.StatementType{}
+	if statementType := st.Extract(stmt.Stmts[0]); statementType != "SELECT" && statementType != "UNION" {
+		return fmt.Errorf("校验仅允许SELECT语句，当前语句类型:%s", statementType)
+	}
+	return nil
+}
+
+// 生成工单每个目标的校验记录
+func newVerifications(record models.InsightOrderRecords, items []forms.Verification) ([]models.InsightOrderVerifications, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if record.DBType == "ClickHouse" || record.SQLType == "EXPORT" {
+		return nil, errors.New("校验查询仅支持MySQL和TiDB的DML、DDL工单")
+	}
+	targets, err := getOrderTargets(record)
+	if err != nil {
+		return nil, err
+	}
+	var verifications []models.InsightOrderVerifications
+	for _, item := range items {
+		if err := checkVerificationSQL(item.SQL); err != nil {
+			return nil, err
+		}
+		if item.Type == "ROWS" {
+			if _, err := strconv.ParseInt(item.Expected, 10, 64); err != nil {
+				return nil, fmt.Errorf("行数断言的期望值`%s`必须为整数", item.Expected)
+			}
+		}
+		for _, target := range targets {
+			verifications = append(verifications, models.InsightOrderVerifications{
+				OrderID:    record.OrderID,
+				InstanceID: target.InstanceID,
+				Schema:     target.Schema,
+				SQL:        item.SQL,
+				Type:       commonModels.EnumType(item.Type),
+				Operator:   item.Operator,
+				Expected:   item.Expected,
+				Status:     "待执行",
+			})
+		}
+	}
+	return verifications, nil
+}
+
+// 比较实际值和期望值，都为数字时按数值比较，否则仅支持=和!=
+func compareVerification(actual, operator, expected string) (bool, error) {
+	a, errA := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+	e, errE := strconv.ParseFloat(strings.TrimSpace(expected), 64)
+	if errA == nil && errE == nil {
+		switch operator {
+		case "=":
+			return a == e, nil
+		case "!=":
+			return a != e, nil
+		case ">":
+			return a > e, nil
+		case ">=":
+			return a >= e, nil
+		case "<":
+			return a < e, nil
+		case "<=":
+			return a <= e, nil
+		}
+	}
+	switch operator {
+	case "=":
+		return actual == expected, nil
+	case "!=":
+		return actual != expected, nil
+	}
+	return false, fmt.Errorf("值`%s`和`%s`不是数字，不支持%s比较", actual, expected, operator)
+}
+
+// 执行单个校验，返回状态、实际值和错误信息
+// 校验查询以申请人的身份走DAS查询，检查库表权限和允许的语句类型，并记录到DAS的查询历史
+func runVerification(v models.InsightOrderVerifications, applicant string) (status, actual, errMsg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	requestID := uuid.New().String()
+	sqlText := v.SQL
+	if v.Type == "ROWS" {
+		// DAS查询会改写LIMIT限制返回的行数，行数断言改为在数据库中统计行数
+		sqlText = fmt.Sprintf("SELECT COUNT(*) AS `cnt` FROM (%s) AS `verification`", strings.TrimRight(strings.TrimSpace(v.SQL), "; \t\r\n"))
+	}
+	service := dasServices.ExecuteMySQLQueryService{
+		ExecuteMySQLQueryForm: &dasForms.ExecuteMySQLQueryForm{
+			InstanceID: v.InstanceID.String(),
+			Schema:     v.Schema,
+			SQLText:    sqlText,
+		},
+		Username: applicant,
+	}
+	result, err := service.Execute(ctx, requestID)
+	// 和DAS查询一样更新执行记录
+	finish := map[string]interface{}{"is_finish": true}
+	if err != nil {
+		finish["error_msg"] = err.Error()
+	}
+	global.App.DB.Model(&dasModels.InsightDASRecords{}).
+		Where("request_id=? and username=?", requestID, applicant).
+		Updates(finish)
+	if err != nil {
+		return "错误", "", err.Error()
+	}
+	// 行数断言和值断言都取第一行第一列
+	columns, data := result.Columns, result.Data
+	if len(*data) == 0 || len(*columns) == 0 {
+		return "失败", "", "查询没有返回数据"
+	}
+	value := (*data)[0][(*columns)[0]]
+	if value == nil {
+		actual = "NULL"
+	} else {
+		actual = fmt.Sprintf("%v", value)
+	}
+	passed, err := compareVerification(actual, v.Operator, v.Expected)
+	if err != nil {
+		return "错误", actual, err.Error()
+	}
+	if !passed {
+		return "失败", actual, ""
+	}
+	return "通过", actual, ""
+}
+
+// 执行工单的所有校验，执行完成后通知申请人和复核人，username为空时表示执行完成后自动校验
+func runOrderVerifications(orderID, username string) {
+	var verifications []models.InsightOrderVerifications
+	global.App.DB.Model(&models.InsightOrderVerifications{}).Where("order_id=?", orderID).Order("id asc").Find(&verifications)
+	if len(verifications) == 0 {
+		return
+	}
+	var record models.InsightOrderRecords
+	global.App.DB.Model(&models.InsightOrderRecords{}).Where("order_id=?", orderID).Take(&record)
+	var passCount int
+	for _, v := range verifications {
+		status, actual, errMsg := runVerification(v, record.Applicant)
+		if status == "通过" {
+			passCount++
+		}
+		now := time.Now()
+		if err := global.App.DB.Model(&models.InsightOrderVerifications{}).Where("id=?", v.ID).Updates(map[string]interface{}{
+			"status":      status,
+			"actual":      actual,
+			"error":       errMsg,
+			"executed_at": &now,
+		}).Error; err != nil {
+			global.App.Log.Error(err)
+		}
+	}
+	result := fmt.Sprintf("校验完成，通过：%d，未通过：%d", passCount, len(verifications)-passCount)
+	logMsg := fmt.Sprintf("工单执行完成后自动执行了校验，%s", result)
+	if username != "" {
+		logMsg = fmt.Sprintf("用户%s执行了校验，%s", username, result)
+	} else {
+		username = record.Applicant
+	}
+	if err := CreateOpLogs(global.App.DB, record.OrderID, username, logMsg); err != nil {
+		global.App.Log.Error(err)
+	}
+	receiver := []string{record.Applicant}
+	receiver = append(receiver, jsonUsers(record.Reviewer)...)
+	msg := fmt.Sprintf("您好，工单执行后的%s\n>工单标题：%s", result, record.Title)
+	notifier.SendMessage(record.Title, orderID, receiver, msg)
+}
+
+// 统计工单各个状态的校验数量，没有校验查询时返回空值
+func verificationSummary(orderID string) string {
+	type statusCount struct {
+		Status string
+		Count  int
+	}
+	var counts []statusCount
+	global.App.DB.Model(&models.InsightOrderVerifications{}).
+		Select("status, count(*) as count").
+		Where("order_id=?", orderID).
+		Group("status").
+		Order("status").
+		Scan(&counts)
+	var items []string
+	for _, c := range counts {
+		items = append(items, fmt.Sprintf("%s%d", c.Status, c.Count))
+	}
+	return strings.Join(items, "，")
+}
+
+type GetVerificationsService struct {
+	*forms.GetVerificationsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *GetVerificationsService) Run() (responseData interface{}, err error) {
+	if _, err := getAccessibleOrder(s.OrderID, s.Username); err != nil {
+		return nil, err
+	}
+	type verification struct {
+		models.InsightOrderVerifications
+		Instance string `json:"instance"`
+	}
+	var verifications []verification
+	global.App.DB.Table("`insight_order_verifications` a").
+		Select("a.*, concat(b.hostname, ':', b.port) as instance").
+		Joins("left join insight_db_config b on a.instance_id=b.instance_id").
+		Where("a.order_id=?", s.OrderID).
+		Order("a.id asc").
+		Scan(&verifications)
+	return verifications, nil
+}
+
+// 修改校验查询，仅申请人可以在工单执行前修改
+type UpdateVerificationsService struct {
+	*forms.UpdateVerificationsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *UpdateVerificationsService) Run() error {
+	var record models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	if record.Applicant != s.Username {
+		return errors.New("仅工单申请人可以修改校验查询")
+	}
+	// 校验查询属于审批的内容，审批通过后不能修改
+	if !utils.IsContain([]string{"待审核", "已驳回"}, string(record.Progress)) {
+		return fmt.Errorf("工单当前状态为%s，审批通过后不能修改校验查询", record.Progress)
+	}
+	verifications, err := newVerifications(record, s.Verifications)
+	if err != nil {
+		return err
+	}
+	return global.App.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id=?", record.OrderID).Delete(&models.InsightOrderVerifications{}).Error; err != nil {
+			return err
+		}
+		if len(verifications) > 0 {
+			if err := tx.CreateInBatches(&verifications, 500).Error; err != nil {
+				return err
+			}
+		}
+		return CreateOpLogs(tx, record.OrderID, s.Username, fmt.Sprintf("用户%s修改了校验查询，数量：%d", s.Username, len(s.Verifications)))
+	})
+}
+
+// 手动重新执行校验，仅申请人和复核人可以在工单完成后执行
+type RunVerificationsService struct {
+	*forms.RunVerificationsForm
+	C        *gin.Context
+	Username string
+}
+
+func (s *RunVerificationsService) Run() error {
+	var record models.InsightOrderRecords
+	tx := global.App.DB.Table("`insight_order_records`").Where("order_id=?", s.OrderID).Take(&record)
+	if tx.RowsAffected == 0 {
+		return fmt.Errorf("记录`%s`不存在", s.OrderID)
+	}
+	if !utils.IsContain(append(jsonUsers(record.Reviewer), record.Applicant), s.Username) {
+		return errors.New("仅工单申请人和复核人可以执行校验")
+	}
+	if !utils.IsContain([]string{"已完成", "已复核"}, string(record.Progress)) {
+		return fmt.Errorf("工单当前状态为%s，执行完成后才能执行校验", record.Progress)
+	}
+	var count int64
+	global.App.DB.Model(&models.InsightOrderVerifications{}).Where("order_id=?", record.OrderID).Count(&count)
+	if count == 0 {
+		return errors.New("当前工单没有校验查询")
+	}
+	go runOrderVerifications(s.OrderID, s.Username)
+	return nil
+}
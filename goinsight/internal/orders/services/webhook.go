@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/pagination"
+	"goInsight/pkg/webhook"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// 工单事件
+const (
+	EventCreated   = "created"
+	EventApproved  = "approved"
+	EventRejected  = "rejected"
+	EventExecuting = "executing"
+	EventCompleted = "completed"
+	EventFailed    = "failed"
+	EventClosed    = "closed"
+)
+
+// 事件发生后工单的状态，created和failed事件使用工单当前的状态
+var eventProgress = map[string]string{
+	EventApproved:  "已批准",
+	EventRejected:  "已驳回",
+	EventExecuting: "执行中",
+	EventCompleted: "已完成",
+	EventClosed:    "已关闭",
+}
+
+// 查询时返回的密钥掩码，更新时密钥为空或为掩码表示不修改
+const webhookSecretMask = "******"
+
+// 推送的工单信息
+type webhookOrder struct {
+	OrderID      string `json:"order_id"`
+	Title        string `json:"title"`
+	Progress     string `json:"progress"`
+	Applicant    string `json:"applicant"`
+	Organization string `json:"organization"`
+	Environment  string `json:"environment"`
+	DBType       string `json:"db_type"`
+	SQLType      string `json:"sql_type"`
+	InstanceID   string `json:"instance_id"`
+	Schema       string `json:"schema"`
+	Revision     int    `json:"revision"`
+	URL          string `json:"url"`
+}
+
+type webhookPayload struct {
+	Event     string       `json:"event"`
+	Timestamp string       `json:"timestamp"`
+	Operator  string       `json:"operator"`
+	Message   string       `json:"message"`
+	Order     webhookOrder `json:"order"`
+}
+
+// 推送工单事件到订阅的Webhook，投递在后台执行，不影响工单操作
+func dispatchOrderEvent(record models.InsightOrderRecords, event, operator, msg string) {
+	var hooks []models.InsightOrderWebhooks
+	global.App.DB.Model(&models.InsightOrderWebhooks{}).
+		Where("is_enable=1 and JSON_CONTAINS(events, JSON_QUOTE(?))", event).
+		Find(&hooks)
+	if len(hooks) == 0 {
+		return
+	}
+	progress, ok := eventProgress[event]
+	if !ok {
+		progress = string(record.Progress)
+	}
+	var environment string
+	global.App.DB.Table("`insight_db_environments`").Select("name").Where("id=?", record.Environment).Scan(&environment)
+	payload, err := json.Marshal(webhookPayload{
+		Event:     event,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+		Operator:  operator,
+		Message:   msg,
+		Order: webhookOrder{
+			OrderID:      record.OrderID.String(),
+			Title:        record.Title,
+			Progress:     progress,
+			Applicant:    record.Applicant,
+			Organization: record.Organization,
+			Environment:  environment,
+			DBType:       string(record.DBType),
+			SQLType:      string(record.SQLType),
+			InstanceID:   record.InstanceID.String(),
+			Schema:       record.Schema,
+			Revision:     record.Revision,
+			URL:          fmt.Sprintf("%s/orders/detail/%s", global.App.Config.Notify.NoticeURL, record.OrderID.String()),
+		},
+	})
+	if err != nil {
+		global.App.Log.Error(err)
+		return
+	}
+	for _, hook := range hooks {
+		delivery := models.InsightOrderWebhookDeliveries{
+			WebhookID:  hook.ID,
+			DeliveryID: uuid.New(),
+			OrderID:    record.OrderID,
+			Event:      event,
+			Payload:    datatypes.JSON(payload),
+			Status:     "发送中",
+		}
+		if err := global.App.DB.Create(&delivery).Error; err != nil {
+			global.App.Log.Error(err)
+			continue
+		}
+		go deliverWebhook(hook, delivery)
+	}
+}
+
+// 投递失败后按指数退避重试，每次尝试都更新投递记录
+func deliverWebhook(hook models.InsightOrderWebhooks, delivery models.InsightOrderWebhookDeliveries) {
+	req := webhook.Request{
+		URL:        hook.URL,
+		Secret:     hook.Secret,
+		Event:      delivery.Event,
+		DeliveryID: delivery.DeliveryID.String(),
+		Body:       delivery.Payload,
+	}
+	var maxRetries int
+	if hook.MaxRetries != nil {
+		maxRetries = *hook.MaxRetries
+	}
+	for attempt := 1; attempt <= maxRetries+1; attempt++ {
+		resp, err := webhook.Send(req)
+		status := "成功"
+		updates := map[string]interface{}{"attempts": attempt, "error": ""}
+		if resp != nil {
+			updates["response_code"] = resp.StatusCode
+			updates["response_body"] = resp.ResponseBody
+		}
+		if err != nil {
+			status = "发送中"
+			if attempt > maxRetries {
+				status = "失败"
+			}
+			updates["error"] = truncateError(err.Error())
+		}
+		updates["status"] = status
+		if err := global.App.DB.Model(&models.InsightOrderWebhookDeliveries{}).Where("id=?", delivery.ID).Updates(updates).Error; err != nil {
+			global.App.Log.Error(err)
+		}
+		if status != "发送中" {
+			return
+		}
+		time.Sleep(webhook.Backoff(attempt))
+	}
+}
+
+// 错误信息最多保留1024个字符
+func truncateError(msg string) string {
+	if r := []rune(msg); len(r) > 1024 {
+		return string(r[:1024])
+	}
+	return msg
+}
+
+func newWebhook(form *forms.CreateWebhookForm) (*models.InsightOrderWebhooks, error) {
+	events, err := json.Marshal(form.Events)
+	if err != nil {
+		return nil, err
+	}
+	return &models.InsightOrderWebhooks{
+		Name:       form.Name,
+		URL:        form.URL,
+		Secret:     form.Secret,
+		Events:     datatypes.JSON(events),
+		MaxRetries: &form.MaxRetries,
+		IsEnable:   &form.IsEnable,
+		Remark:     form.Remark,
+	}, nil
+}
+
+type GetWebhooksService struct {
+	*forms.GetWebhooksForm
+	C *gin.Context
+}
+
+func (s *GetWebhooksService) Run() (responseData interface{}, total int64, err error) {
+	var hooks []models.InsightOrderWebhooks
+	tx := global.App.DB.Table("`insight_order_webhooks`").Order("id asc")
+	// 搜索
+	if s.Search != "" {
+		tx = tx.Where("`name` like ? or `url` like ?", "%"+s.Search+"%", "%"+s.Search+"%")
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &hooks)
+	// 不返回密钥
+	for i := range hooks {
+		if hooks[i].Secret != "" {
+			hooks[i].Secret = webhookSecretMask
+		}
+	}
+	return &hooks, total, nil
+}
+
+type CreateWebhookService struct {
+	*forms.CreateWebhookForm
+	C *gin.Context
+}
+
+func (s *CreateWebhookService) Run() error {
+	hook, err := newWebhook(s.CreateWebhookForm)
+	if err != nil {
+		return err
+	}
+	return global.App.DB.Create(hook).Error
+}
+
+type UpdateWebhookService struct {
+	*forms.UpdateWebhookForm
+	C  *gin.Context
+	ID uint64
+}
+
+func (s *UpdateWebhookService) Run() error {
+	hook, err := newWebhook(&s.CreateWebhookForm)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{
+		"name":        hook.Name,
+		"url":         hook.URL,
+		"events":      hook.Events,
+		"max_retries": *hook.MaxRetries,
+		"is_enable":   *hook.IsEnable,
+		"remark":      hook.Remark,
+	}
+	if hook.Secret != "" && hook.Secret != webhookSecretMask {
+		values["secret"] = hook.Secret
+	}
+	return global.App.DB.Model(&models.InsightOrderWebhooks{}).Where("id=?", s.ID).Updates(values).Error
+}
+
+type DeleteWebhookService struct {
+	C  *gin.Context
+	ID uint64
+}
+
+func (s *DeleteWebhookService) Run() error {
+	return global.App.DB.Where("id=?", s.ID).Delete(&models.InsightOrderWebhooks{}).Error
+}
+
+type GetWebhookDeliveriesService struct {
+	*forms.GetWebhookDeliveriesForm
+	C *gin.Context
+}
+
+func (s *GetWebhookDeliveriesService) Run() (responseData interface{}, total int64, err error) {
+	var deliveries []models.InsightOrderWebhookDeliveries
+	tx := global.App.DB.Table("`insight_order_webhook_deliveries`").Order("id desc")
+	if s.WebhookID > 0 {
+		tx = tx.Where("webhook_id=?", s.WebhookID)
+	}
+	if s.OrderID != "" {
+		tx = tx.Where("order_id=?", s.OrderID)
+	}
+	if s.Event != "" {
+		tx = tx.Where("event=?", s.Event)
+	}
+	if s.Status != "" {
+		tx = tx.Where("status=?", s.Status)
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &deliveries)
+	return &deliveries, total, nil
+}
+
+// 重新投递，使用原来的推送内容生成新的投递记录
+type RedeliverWebhookService struct {
+	C  *gin.Context
+	ID uint64
+}
+
+func (s *RedeliverWebhookService) Run() error {
+	var delivery models.InsightOrderWebhookDeliveries
+	if global.App.DB.Model(&models.InsightOrderWebhookDeliveries{}).Where("id=?", s.ID).Take(&delivery).RowsAffected == 0 {
+		return fmt.Errorf("投递记录`%d`不存在", s.ID)
+	}
+	// 发送中的投递超过重试间隔没有更新时，说明投递已中断（例如服务重启），允许重新投递
+	if delivery.Status == "发送中" {
+		if time.Since(time.Time(delivery.UpdatedAt)) < webhook.Backoff(delivery.Attempts)+time.Minute {
+			return errors.New("当前投递正在发送中，请等待发送完成")
+		}
+		if err := global.App.DB.Model(&models.InsightOrderWebhookDeliveries{}).
+			Where("id=? and status='发送中'", delivery.ID).
+			Updates(map[string]interface{}{"status": "失败", "error": "投递中断"}).Error; err != nil {
+			return err
+		}
+	}
+	var hook models.InsightOrderWebhooks
+	if global.App.DB.Model(&models.InsightOrderWebhooks{}).Where("id=?", delivery.WebhookID).Take(&hook).RowsAffected == 0 {
+		return fmt.Errorf("Webhook`%d`不存在", delivery.WebhookID)
+	}
+	redelivery := models.InsightOrderWebhookDeliveries{
+		WebhookID:  hook.ID,
+		DeliveryID: uuid.New(),
+		OrderID:    delivery.OrderID,
+		Event:      delivery.Event,
+		Payload:    delivery.Payload,
+		Status:     "发送中",
+	}
+	if err := global.App.DB.Create(&redelivery).Error; err != nil {
+		return err
+	}
+	go deliverWebhook(hook, redelivery)
+	return nil
+}
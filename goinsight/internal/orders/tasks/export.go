@@ -0,0 +1,37 @@
+/*
+@Desc    :   清理过期的导出文件
+*/
+
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/models"
+	"goInsight/pkg/storage"
+	"time"
+)
+
+// CleanExpiredExportFiles 删除存储中过期的导出文件，记录保留用于审计
+func CleanExpiredExportFiles() {
+	var files []models.InsightExportFiles
+	global.App.DB.Model(&models.InsightExportFiles{}).
+		Where("status='有效' and expire_at is not null and expire_at<?", time.Now()).
+		Find(&files)
+	for _, file := range files {
+		store, err := storage.Open(file.Storage, global.App.Config.Export)
+		if err != nil {
+			global.App.Log.Error(err)
+			continue
+		}
+		if err := store.Delete(context.Background(), file.ObjectKey); err != nil {
+			global.App.Log.Error(fmt.Sprintf("删除过期的导出文件%s失败：%s", file.FileName, err.Error()))
+			continue
+		}
+		global.App.DB.Model(&models.InsightExportFiles{}).
+			Where("task_id=?", file.TaskID).
+			Update("status", "已过期")
+		global.App.Log.Info(fmt.Sprintf("删除过期的导出文件%s", file.FileName))
+	}
+}
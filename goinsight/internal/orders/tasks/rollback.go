@@ -0,0 +1,45 @@
+/*
+@Desc    :   清理过期的回滚SQL文件
+*/
+
+package tasks
+
+import (
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/api/mysql"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CleanExpiredRollbackFiles 删除超过保留天数的回滚SQL文件，任务结果中仍保留文件路径
+func CleanExpiredRollbackFiles() {
+	retentionDays := global.App.Config.Rollback.RetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(mysql.RollbackSQLDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			global.App.Log.Error(err)
+		}
+		return
+	}
+	expireAt := time.Now().AddDate(0, 0, -retentionDays)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(expireAt) {
+			continue
+		}
+		file := filepath.Join(mysql.RollbackSQLDir, entry.Name())
+		if err := os.Remove(file); err != nil {
+			global.App.Log.Error(fmt.Sprintf("删除过期的回滚SQL文件%s失败：%s", file, err.Error()))
+			continue
+		}
+		global.App.Log.Info(fmt.Sprintf("删除过期的回滚SQL文件%s", file))
+	}
+}
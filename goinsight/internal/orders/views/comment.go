@@ -0,0 +1,94 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"strconv"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 获取工单的评论
+func GetOrderCommentsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetOrderCommentsForm = &forms.GetOrderCommentsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetOrderCommentsService{
+			GetOrderCommentsForm: form,
+			C:                    c,
+			Username:             username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 发表评论
+func CreateOrderCommentView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateOrderCommentForm = &forms.CreateOrderCommentForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateOrderCommentService{
+			CreateOrderCommentForm: form,
+			C:                      c,
+			Username:               username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 修改评论
+func UpdateOrderCommentView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	var form *forms.UpdateOrderCommentForm = &forms.UpdateOrderCommentForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateOrderCommentService{
+			UpdateOrderCommentForm: form,
+			C:                      c,
+			ID:                     uint64(id),
+			Username:               username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 删除评论
+func DeleteOrderCommentView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.DeleteOrderCommentService{
+		C:        c,
+		ID:       uint64(id),
+		Username: username,
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
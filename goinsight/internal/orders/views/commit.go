@@ -105,3 +105,24 @@ func CreateOrdersView(c *gin.Context) {
 		response.ValidateFail(c, err.Error())
 	}
 }
+
+// 从git仓库的迁移脚本创建工单
+func CreateMigrationOrderView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateMigrationOrderForm = &forms.CreateMigrationOrderForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateMigrationOrderService{
+			CreateMigrationOrderForm: form,
+			C:                        c,
+			Username:                 username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
@@ -0,0 +1,53 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 发起试运行
+func StartDryRunView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.StartDryRunForm = &forms.StartDryRunForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.StartDryRunService{
+			StartDryRunForm: form,
+			C:               c,
+			Username:        username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "试运行已开始执行")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 获取工单的试运行记录
+func GetDryRunsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetDryRunsForm = &forms.GetDryRunsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetDryRunsService{
+			GetDryRunsForm: form,
+			C:              c,
+			Username:       username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
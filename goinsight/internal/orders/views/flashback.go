@@ -0,0 +1,104 @@
+package views
+
+import (
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/orders/forms"
+	ordersModels "goInsight/internal/orders/models"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"net/http"
+	"os"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// 创建闪回任务
+func CreateFlashbackView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateFlashbackForm = &forms.CreateFlashbackForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateFlashbackService{
+			CreateFlashbackForm: form,
+			C:                   c,
+			Username:            username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 获取闪回任务列表
+func GetFlashbackListView(c *gin.Context) {
+	var form *forms.GetFlashbackListForm = &forms.GetFlashbackListForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetFlashbackListService{
+			GetFlashbackListForm: form,
+			C:                    c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 基于闪回SQL创建工单
+func CreateFlashbackOrderView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateFlashbackOrderForm = &forms.CreateFlashbackOrderForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateFlashbackOrderService{
+			CreateFlashbackOrderForm: form,
+			C:                        c,
+			Username:                 username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 下载闪回SQL文件
+func DownloadFlashbackFileView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	flashbackID := c.Param("flashback_id")
+	requestID := requestid.Get(c)
+	var record ordersModels.InsightFlashbackRecords
+	tx := global.App.DB.Model(&ordersModels.InsightFlashbackRecords{}).
+		Where("flashback_id=? and progress='已完成'", flashbackID).Scan(&record)
+	if tx.RowsAffected == 0 {
+		global.App.Log.WithField("request_id", requestID).WithField("username", username).Errorf("闪回任务不存在或未完成，任务ID：%s", flashbackID)
+		c.JSON(http.StatusNotFound, map[string]interface{}{})
+		return
+	}
+	// 检查本地文件是否存在
+	if _, err := os.Stat(record.FilePath); os.IsNotExist(err) {
+		global.App.Log.WithField("request_id", requestID).WithField("username", username).Error(fmt.Sprintf("下载的文件%s不存在", record.FilePath))
+		c.JSON(http.StatusInternalServerError, map[string]interface{}{})
+		return
+	}
+	c.Header("Content-Type", "application/sql")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=flashback_%s.sql", flashbackID))
+	c.Header("Accept-Length", fmt.Sprintf("%d", record.FileSize))
+	c.File(record.FilePath)
+}
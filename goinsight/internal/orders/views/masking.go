@@ -0,0 +1,81 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取脱敏规则
+func GetMaskingRulesView(c *gin.Context) {
+	var form *forms.GetMaskingRulesForm = &forms.GetMaskingRulesForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetMaskingRulesService{
+			GetMaskingRulesForm: form,
+			C:                   c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 创建脱敏规则
+func CreateMaskingRuleView(c *gin.Context) {
+	var form *forms.CreateMaskingRuleForm = &forms.CreateMaskingRuleForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateMaskingRuleService{
+			CreateMaskingRuleForm: form,
+			C:                     c,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 更新脱敏规则
+func UpdateMaskingRuleView(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var form *forms.UpdateMaskingRuleForm = &forms.UpdateMaskingRuleForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateMaskingRuleService{
+			UpdateMaskingRuleForm: form,
+			C:                     c,
+			ID:                    uint64(id),
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 删除脱敏规则
+func DeleteMaskingRuleView(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.DeleteMaskingRuleService{
+		C:  c,
+		ID: uint64(id),
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
@@ -0,0 +1,75 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 修改工单内容
+func ReviseOrderView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.ReviseOrderForm = &forms.ReviseOrderForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.ReviseOrderService{
+			ReviseOrderForm: form,
+			C:               c,
+			Username:        username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 获取工单的修改历史
+func GetOrderRevisionsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetOrderRevisionsForm = &forms.GetOrderRevisionsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetOrderRevisionsService{
+			GetOrderRevisionsForm: form,
+			C:                     c,
+			Username:              username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 对比工单的两个版本
+func GetOrderRevisionDiffView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetOrderRevisionDiffForm = &forms.GetOrderRevisionDiffForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetOrderRevisionDiffService{
+			GetOrderRevisionDiffForm: form,
+			C:                        c,
+			Username:                 username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
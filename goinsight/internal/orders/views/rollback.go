@@ -0,0 +1,52 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 创建回滚工单
+func CreateRollbackOrderView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateRollbackOrderForm = &forms.CreateRollbackOrderForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateRollbackOrderService{
+			CreateRollbackOrderForm: form,
+			C:                       c,
+			Username:                username,
+		}
+		err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+		} else {
+			response.Success(c, nil, "success")
+		}
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 获取工单关联的回滚工单
+func GetRollbackOrdersView(c *gin.Context) {
+	var form *forms.GetRollbackOrdersForm = &forms.GetRollbackOrdersForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetRollbackOrdersService{
+			GetRollbackOrdersForm: form,
+			C:                     c,
+			OrderID:               c.Param("order_id"),
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
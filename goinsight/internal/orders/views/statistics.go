@@ -0,0 +1,141 @@
+package views
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 返回统计结果，format为csv时下载CSV文件
+func renderStatistics(c *gin.Context, name string, format string, table *services.StatisticsTable) {
+	if format != "csv" {
+		response.Success(c, table, "success")
+		return
+	}
+	var buf bytes.Buffer
+	// 写入BOM，避免Excel打开中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	if err := w.Write(table.Columns); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	for _, row := range table.Rows {
+		record := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			record[i] = fmt.Sprintf("%v", row[column])
+		}
+		if err := w.Write(record); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=statistics_%s.csv", name))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// 工单数量统计
+func OrderStatisticsView(c *gin.Context) {
+	var form *forms.OrderStatisticsForm = &forms.OrderStatisticsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.OrderStatisticsService{
+			OrderStatisticsForm: form,
+			C:                   c,
+		}
+		table, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		renderStatistics(c, "orders", form.Format, table)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 审批耗时统计
+func ApprovalLatencyStatisticsView(c *gin.Context) {
+	var form *forms.StatisticsForm = &forms.StatisticsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.ApprovalLatencyStatisticsService{
+			StatisticsForm: form,
+			C:              c,
+		}
+		table, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		renderStatistics(c, "approval_latency", form.Format, table)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 执行成功率和执行耗时统计
+func ExecutionStatisticsView(c *gin.Context) {
+	var form *forms.StatisticsForm = &forms.StatisticsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.ExecutionStatisticsService{
+			StatisticsForm: form,
+			C:              c,
+		}
+		table, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		renderStatistics(c, "execution", form.Format, table)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 修改次数最多的表
+func TableStatisticsView(c *gin.Context) {
+	var form *forms.TableStatisticsForm = &forms.TableStatisticsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.TableStatisticsService{
+			TableStatisticsForm: form,
+			C:                   c,
+		}
+		table, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		renderStatistics(c, "tables", form.Format, table)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 回滚频率统计
+func RollbackStatisticsView(c *gin.Context) {
+	var form *forms.RollbackStatisticsForm = &forms.RollbackStatisticsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.RollbackStatisticsService{
+			RollbackStatisticsForm: form,
+			C:                      c,
+		}
+		table, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		renderStatistics(c, "rollbacks", form.Format, table)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
@@ -1,15 +1,12 @@
 package views
 
 import (
-	"encoding/json"
 	"fmt"
 	"goInsight/global"
 	"goInsight/internal/orders/forms"
-	ordersModels "goInsight/internal/orders/models"
 	"goInsight/internal/orders/services"
 	"goInsight/pkg/response"
 	"net/http"
-	"os"
 
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-contrib/requestid"
@@ -80,6 +77,25 @@ func PreviewTasksView(c *gin.Context) {
 	}
 }
 
+// 获取每个目标的任务进度
+func GetTaskTargetsView(c *gin.Context) {
+	var form *forms.GetTaskTargetsForm = &forms.GetTaskTargetsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetTaskTargetsServices{
+			GetTaskTargetsForm: form,
+			C:                  c,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
 // 执行单个任务
 func ExecuteSingleTaskView(c *gin.Context) {
 	claims := jwt.ExtractClaims(c)
@@ -129,66 +145,131 @@ func ExecuteAllTaskView(c *gin.Context) {
 	}
 }
 
+// 暂停任务
+func PauseTasksView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.PauseTasksForm = &forms.PauseTasksForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.PauseTasksService{
+			PauseTasksForm: form,
+			C:              c,
+			Username:       username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 恢复执行任务
+func ResumeTasksView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.ResumeTasksForm = &forms.ResumeTasksForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.ResumeTasksService{
+			ResumeTasksForm: form,
+			C:               c,
+			Username:        username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 终止执行中的任务
+func CancelTaskView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CancelTaskForm = &forms.CancelTaskForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CancelTaskService{
+			CancelTaskForm: form,
+			C:              c,
+			Username:       username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
 // 下载导出文件
 func DownloadExportFileView(c *gin.Context) {
 	claims := jwt.ExtractClaims(c)
 	username := claims["id"].(string)
-	task_id := c.Param("task_id")
 	requestID := requestid.Get(c)
-	// 判断下载记录是否存在
-	var task ordersModels.InsightOrderTasks
-	tx := global.App.DB.Model(&ordersModels.InsightOrderTasks{}).
-		Where("task_id=?", task_id).Scan(&task)
-	if tx.RowsAffected == 0 {
-		global.App.Log.WithField("request_id", requestID).WithField("username", username).Errorf("下载记录关联的任务不存在，任务ID：%s", task_id)
-		c.JSON(http.StatusNotFound, map[string]interface{}{})
-		return
-	}
-	// 判断用户是否有权限下载
-	var record ordersModels.InsightOrderRecords
-	global.App.DB.Model(&ordersModels.InsightOrderRecords{}).
-		Where("order_id=?", task.OrderID).Scan(&record)
-	if record.Applicant != username {
-		global.App.Log.WithField("request_id", requestID).WithField("username", username).Errorf("用户%s无权限下载任务%s的导出文件", username, task_id)
-		c.JSON(http.StatusForbidden, map[string]interface{}{})
-		return
+	service := services.DownloadExportFileService{
+		TaskID:   c.Param("task_id"),
+		C:        c,
+		Username: username,
 	}
-	// 获取下载文件信息
-	type exportFile struct {
-		FileName      string `json:"file_name"`
-		FileSize      int64  `json:"file_size"`
-		FilePath      string `json:"file_path"`
-		ContentType   string `json:"content_type"`
-		EncryptionKey string `json:"encryption_key"`
-		ExportRows    int64  `json:"export_rows"`
-	}
-	var file exportFile
-	err := json.Unmarshal([]byte(task.Result), &file)
+	file, status, err := service.Run()
 	if err != nil {
-		global.App.Log.WithField("request_id", requestID).WithField("username", username).Errorf("解析下载文件信息异常，错误：%s", err.Error())
-		c.JSON(http.StatusInternalServerError, map[string]interface{}{})
-		return
-	}
-	// 检查本地文件是否存在
-	_, err = os.Stat(file.FilePath)
-	if os.IsNotExist(err) {
-		global.App.Log.WithField("request_id", requestID).WithField("username", username).Error(fmt.Sprintf("下载的文件%s不存在", file.FilePath))
-		c.JSON(http.StatusInternalServerError, map[string]interface{}{})
+		global.App.Log.WithField("request_id", requestID).WithField("username", username).Error(err.Error())
+		c.JSON(status, map[string]interface{}{})
 		return
 	}
+	defer file.Reader.Close()
 
-	// Open the file
-	f, err := os.Open(file.FilePath)
-	if err != nil {
-		global.App.Log.WithField("request_id", requestID).WithField("username", username).Error(fmt.Sprintf("打开下载文件%s失败", file.FilePath))
-		c.JSON(http.StatusInternalServerError, map[string]interface{}{})
-		return
+	// Set headers for file download
+	c.DataFromReader(http.StatusOK, file.Size, "application/zip", file.Reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", file.FileName),
+		"Accept-Length":       fmt.Sprintf("%d", file.Size),
+	})
+}
+
+// 向执行中的gh-ost发送交互命令
+func GhostControlView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GhostControlForm = &forms.GhostControlForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GhostControlService{
+			GhostControlForm: form,
+			C:                c,
+			Username:         username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
 	}
-	defer f.Close()
+}
 
-	// Set headers for file download
-	c.Header("Content-Type", "application/zip")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", file.FileName))
-	c.Header("Accept-Length", fmt.Sprintf("%d", file.FileSize))
-	c.File(file.FilePath)
+// 获取执行中任务的gh-ost进度
+func GetGhostProgressView(c *gin.Context) {
+	var form *forms.GetGhostProgressForm = &forms.GetGhostProgressForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetGhostProgressService{
+			GetGhostProgressForm: form,
+			C:                    c,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
 }
@@ -0,0 +1,199 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"strconv"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 获取当前用户可见的工单模板
+func GetOrderTemplatesView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetOrderTemplatesForm = &forms.GetOrderTemplatesForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetOrderTemplatesService{
+			GetOrderTemplatesForm: form,
+			C:                     c,
+			Username:              username,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 创建工单模板
+func CreateOrderTemplateView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateOrderTemplateForm = &forms.CreateOrderTemplateForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateOrderTemplateService{
+			CreateOrderTemplateForm: form,
+			C:                       c,
+			Username:                username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 更新工单模板
+func UpdateOrderTemplateView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	var form *forms.UpdateOrderTemplateForm = &forms.UpdateOrderTemplateForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateOrderTemplateService{
+			UpdateOrderTemplateForm: form,
+			C:                       c,
+			ID:                      uint64(id),
+			Username:                username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 删除工单模板
+func DeleteOrderTemplateView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.DeleteOrderTemplateService{
+		C:        c,
+		ID:       uint64(id),
+		Username: username,
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
+
+// 获取当前用户可见的SQL片段
+func GetSQLSnippetsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetSQLSnippetsForm = &forms.GetSQLSnippetsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetSQLSnippetsService{
+			GetSQLSnippetsForm: form,
+			C:                  c,
+			Username:           username,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 创建SQL片段
+func CreateSQLSnippetView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.CreateSQLSnippetForm = &forms.CreateSQLSnippetForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateSQLSnippetService{
+			CreateSQLSnippetForm: form,
+			C:                    c,
+			Username:             username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 更新SQL片段
+func UpdateSQLSnippetView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	var form *forms.UpdateSQLSnippetForm = &forms.UpdateSQLSnippetForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateSQLSnippetService{
+			UpdateSQLSnippetForm: form,
+			C:                    c,
+			ID:                   uint64(id),
+			Username:             username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 删除SQL片段
+func DeleteSQLSnippetView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.DeleteSQLSnippetService{
+		C:        c,
+		ID:       uint64(id),
+		Username: username,
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
+
+// 替换SQL片段的参数，返回替换后的SQL
+func RenderSQLSnippetView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.RenderSQLSnippetForm = &forms.RenderSQLSnippetForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.RenderSQLSnippetService{
+			RenderSQLSnippetForm: form,
+			C:                    c,
+			Username:             username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
@@ -0,0 +1,74 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 获取工单的校验查询和结果
+func GetVerificationsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.GetVerificationsForm = &forms.GetVerificationsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetVerificationsService{
+			GetVerificationsForm: form,
+			C:                    c,
+			Username:             username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 修改工单的校验查询
+func UpdateVerificationsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.UpdateVerificationsForm = &forms.UpdateVerificationsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateVerificationsService{
+			UpdateVerificationsForm: form,
+			C:                       c,
+			Username:                username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 重新执行工单的校验查询
+func RunVerificationsView(c *gin.Context) {
+	claims := jwt.ExtractClaims(c)
+	username := claims["id"].(string)
+	var form *forms.RunVerificationsForm = &forms.RunVerificationsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.RunVerificationsService{
+			RunVerificationsForm: form,
+			C:                    c,
+			Username:             username,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "校验已开始执行")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
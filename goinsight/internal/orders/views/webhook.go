@@ -0,0 +1,114 @@
+package views
+
+import (
+	"goInsight/internal/orders/forms"
+	"goInsight/internal/orders/services"
+	"goInsight/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取Webhook
+func GetWebhooksView(c *gin.Context) {
+	var form *forms.GetWebhooksForm = &forms.GetWebhooksForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetWebhooksService{
+			GetWebhooksForm: form,
+			C:               c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 创建Webhook
+func CreateWebhookView(c *gin.Context) {
+	var form *forms.CreateWebhookForm = &forms.CreateWebhookForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateWebhookService{
+			CreateWebhookForm: form,
+			C:                 c,
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 更新Webhook
+func UpdateWebhookView(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var form *forms.UpdateWebhookForm = &forms.UpdateWebhookForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.UpdateWebhookService{
+			UpdateWebhookForm: form,
+			C:                 c,
+			ID:                uint64(id),
+		}
+		if err := service.Run(); err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, nil, "success")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 删除Webhook
+func DeleteWebhookView(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.DeleteWebhookService{
+		C:  c,
+		ID: uint64(id),
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
+
+// 获取Webhook的投递记录
+func GetWebhookDeliveriesView(c *gin.Context) {
+	var form *forms.GetWebhookDeliveriesForm = &forms.GetWebhookDeliveriesForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetWebhookDeliveriesService{
+			GetWebhookDeliveriesForm: form,
+			C:                        c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 重新投递
+func RedeliverWebhookView(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.RedeliverWebhookService{
+		C:  c,
+		ID: uint64(id),
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "已重新投递")
+}
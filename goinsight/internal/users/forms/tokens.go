@@ -0,0 +1,26 @@
+package forms
+
+import "goInsight/pkg/pagination"
+
+type GetAccessTokensForm struct {
+	PaginationQ pagination.Pagination
+	Search      string `form:"search"`
+}
+
+// ExpiresDays为0时令牌永不过期
+type CreateAccessTokenForm struct {
+	Name        string   `form:"name" json:"name" binding:"required,max=64"`
+	Scopes      []string `form:"scopes" json:"scopes" binding:"required,min=1,dive,oneof=orders:create orders:read orders:execute"`
+	ExpiresDays int      `form:"expires_days" json:"expires_days" binding:"min=0,max=3650"`
+}
+
+// 管理员为服务账号等用户创建令牌
+type AdminCreateAccessTokenForm struct {
+	CreateAccessTokenForm
+	Username string `form:"username" json:"username" binding:"required,min=2,max=32"`
+}
+
+type GetAccessTokenLogsForm struct {
+	PaginationQ pagination.Pagination
+	TokenID     uint64 `form:"token_id" json:"token_id"`
+}
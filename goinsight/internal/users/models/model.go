@@ -6,7 +6,10 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"goInsight/internal/common/models"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
@@ -18,6 +21,12 @@ func BcryptPW(password string) string {
 	return string(hashedPassword)
 }
 
+// 访问令牌只保存SHA256，认证时按哈希值查找
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // 用户表
 type InsightUsers struct {
 	Uid         uint64           `gorm:"type:bigint;primaryKey;autoIncrement;comment:用户ID" json:"uid"`
@@ -80,3 +89,46 @@ type InsightOrganizationsUsers struct {
 func (InsightOrganizationsUsers) TableName() string {
 	return "insight_organizations_users"
 }
+
+// 访问令牌的权限范围
+const (
+	ScopeCreateOrder  = "orders:create"
+	ScopeReadOrder    = "orders:read"
+	ScopeExecuteOrder = "orders:execute"
+)
+
+// 个人访问令牌，CI等系统通过令牌调用工单API，令牌的权限不超过所属用户的权限
+type InsightAccessTokens struct {
+	*models.Model
+	Name        string         `gorm:"type:varchar(64);not null;default:'';comment:令牌名称" json:"name"`
+	Username    string         `gorm:"type:varchar(32);not null;default:'';index:idx_username;comment:所属用户" json:"username"`
+	Creator     string         `gorm:"type:varchar(32);not null;default:'';comment:创建人" json:"creator"`
+	TokenPrefix string         `gorm:"type:varchar(16);not null;default:'';comment:令牌前缀，用于识别令牌" json:"token_prefix"`
+	TokenHash   string         `gorm:"type:char(64);not null;uniqueIndex:uniq_token_hash;comment:令牌的SHA256" json:"-"`
+	Scopes      datatypes.JSON `gorm:"type:json;null;default:null;comment:权限范围" json:"scopes"`
+	ExpiresAt   *time.Time     `gorm:"type:datetime;null;default:null;comment:过期时间，为空时永不过期" json:"expires_at"`
+	RevokedAt   *time.Time     `gorm:"type:datetime;null;default:null;comment:吊销时间" json:"revoked_at"`
+	LastUsedAt  *time.Time     `gorm:"type:datetime;null;default:null;comment:最后使用时间" json:"last_used_at"`
+	LastUsedIP  string         `gorm:"type:varchar(64);not null;default:'';comment:最后使用的IP" json:"last_used_ip"`
+}
+
+func (InsightAccessTokens) TableName() string {
+	return "insight_access_tokens"
+}
+
+// 访问令牌的使用记录
+type InsightAccessTokenLogs struct {
+	*models.Model
+	TokenID    uint64 `gorm:"type:bigint;not null;index:idx_token_id;comment:关联insight_access_tokens的id" json:"token_id"`
+	Username   string `gorm:"type:varchar(32);not null;default:'';comment:令牌所属用户" json:"username"`
+	Method     string `gorm:"type:varchar(16);not null;default:'';comment:请求方法" json:"method"`
+	Path       string `gorm:"type:varchar(1024);not null;default:'';comment:请求路径" json:"path"`
+	ClientIP   string `gorm:"type:varchar(64);not null;default:'';comment:客户端IP" json:"client_ip"`
+	StatusCode int    `gorm:"type:int;not null;default:0;comment:响应状态码" json:"status_code"`
+	Msg        string `gorm:"type:varchar(1024);not null;default:'';comment:认证失败的原因" json:"msg"`
+	RequestID  string `gorm:"type:varchar(64);not null;default:'';comment:请求ID" json:"request_id"`
+}
+
+func (InsightAccessTokenLogs) TableName() string {
+	return "insight_access_token_logs"
+}
@@ -32,6 +32,11 @@ func AdminRoutes(v1 *gin.RouterGroup) {
 	admin.GET("/organizations/users", views.GetOrganizationsUsersView)
 	admin.POST("/organizations/users", views.BindOrganizationsUsersView)
 	admin.DELETE("/organizations/users", views.DeleteOrganizationsUsersView)
+	// 访问令牌
+	admin.GET("/tokens", views.AdminGetAccessTokensView)
+	admin.POST("/tokens", views.AdminCreateAccessTokenView)
+	admin.DELETE("/tokens/:id", views.AdminRevokeAccessTokenView)
+	admin.GET("/tokens/logs", views.AdminGetAccessTokenLogsView)
 }
 
 func Routers(r *gin.Engine) {
@@ -54,6 +59,11 @@ func Routers(r *gin.Engine) {
 		v1.PUT("/user/:uid", views.UpdateUserInfoView)
 		v1.POST("/user/change/avatar", views.ChangeUserAvatarView)
 		v1.POST("/user/change/password", views.ChangeUserPasswordView)
+		// 访问令牌
+		v1.GET("/user/tokens", views.GetAccessTokensView)
+		v1.POST("/user/tokens", views.CreateAccessTokenView)
+		v1.DELETE("/user/tokens/:id", views.RevokeAccessTokenView)
+		v1.GET("/user/tokens/logs", views.GetAccessTokenLogsView)
 
 		AdminRoutes(v1)
 	}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goInsight/global"
+	"goInsight/internal/users/forms"
+	"goInsight/internal/users/models"
+	"goInsight/pkg/pagination"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/datatypes"
+)
+
+// 生成访问令牌，明文只在创建时返回一次
+func generateAccessToken() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "gi_" + hex.EncodeToString(b), nil
+}
+
+type GetAccessTokensService struct {
+	*forms.GetAccessTokensForm
+	C        *gin.Context
+	Username string // 为空时返回所有用户的令牌
+}
+
+func (s *GetAccessTokensService) Run() (responseData interface{}, total int64, err error) {
+	var tokens []models.InsightAccessTokens
+	tx := global.App.DB.Table("insight_access_tokens").Order("id desc")
+	if s.Username != "" {
+		tx = tx.Where("username=?", s.Username)
+	}
+	// 搜索
+	if s.Search != "" {
+		tx = tx.Where("`name` like ? or `username` like ? or `token_prefix` like ?", "%"+s.Search+"%", "%"+s.Search+"%", "%"+s.Search+"%")
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &tokens)
+	return &tokens, total, nil
+}
+
+type CreateAccessTokenService struct {
+	*forms.CreateAccessTokenForm
+	C        *gin.Context
+	Username string // 令牌所属用户
+	Creator  string
+}
+
+func (s *CreateAccessTokenService) Run() (responseData interface{}, err error) {
+	var user models.InsightUsers
+	tx := global.App.DB.Table("insight_users").Where("username=?", s.Username).Scan(&user)
+	if tx.RowsAffected == 0 {
+		return nil, fmt.Errorf("用户`%s`不存在", s.Username)
+	}
+	if !user.IsActive {
+		return nil, fmt.Errorf("用户`%s`已禁用", s.Username)
+	}
+	plain, err := generateAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := json.Marshal(s.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	token := models.InsightAccessTokens{
+		Name:        s.Name,
+		Username:    s.Username,
+		Creator:     s.Creator,
+		TokenPrefix: plain[:10],
+		TokenHash:   models.HashAccessToken(plain),
+		Scopes:      datatypes.JSON(scopes),
+	}
+	if s.ExpiresDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, s.ExpiresDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := global.App.DB.Create(&token).Error; err != nil {
+		return nil, err
+	}
+	global.App.Log.Infof("用户%s为用户%s创建了访问令牌%s(%s)", s.Creator, s.Username, token.Name, token.TokenPrefix)
+	return map[string]interface{}{
+		"id":         token.ID,
+		"name":       token.Name,
+		"username":   token.Username,
+		"token":      plain,
+		"scopes":     s.Scopes,
+		"expires_at": token.ExpiresAt,
+	}, nil
+}
+
+// 吊销令牌，吊销后不能恢复
+type RevokeAccessTokenService struct {
+	C        *gin.Context
+	ID       uint64
+	Username string // 为空时可以吊销所有用户的令牌
+	Operator string
+}
+
+func (s *RevokeAccessTokenService) Run() error {
+	var token models.InsightAccessTokens
+	tx := global.App.DB.Model(&models.InsightAccessTokens{}).Where("id=?", s.ID)
+	if s.Username != "" {
+		tx = tx.Where("username=?", s.Username)
+	}
+	if tx.Take(&token).RowsAffected == 0 {
+		return fmt.Errorf("访问令牌`%d`不存在", s.ID)
+	}
+	if token.RevokedAt != nil {
+		return errors.New("访问令牌已吊销，请勿重复操作")
+	}
+	if err := global.App.DB.Model(&models.InsightAccessTokens{}).
+		Where("id=?", s.ID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	global.App.Log.Infof("用户%s吊销了用户%s的访问令牌%s(%s)", s.Operator, token.Username, token.Name, token.TokenPrefix)
+	return nil
+}
+
+type GetAccessTokenLogsService struct {
+	*forms.GetAccessTokenLogsForm
+	C        *gin.Context
+	Username string // 为空时返回所有用户的使用记录
+}
+
+func (s *GetAccessTokenLogsService) Run() (responseData interface{}, total int64, err error) {
+	var logs []models.InsightAccessTokenLogs
+	tx := global.App.DB.Table("insight_access_token_logs").Order("id desc")
+	if s.Username != "" {
+		tx = tx.Where("username=?", s.Username)
+	}
+	if s.TokenID > 0 {
+		tx = tx.Where("token_id=?", s.TokenID)
+	}
+	total = pagination.Pager(&s.PaginationQ, tx, &logs)
+	return &logs, total, nil
+}
@@ -0,0 +1,171 @@
+/*
+@Desc    :   访问令牌
+*/
+package views
+
+import (
+	"goInsight/internal/users/forms"
+	"goInsight/internal/users/services"
+	"goInsight/pkg/response"
+	"strconv"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+)
+
+// 获取当前用户的访问令牌
+func GetAccessTokensView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	var form *forms.GetAccessTokensForm = &forms.GetAccessTokensForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetAccessTokensService{
+			GetAccessTokensForm: form,
+			C:                   c,
+			Username:            username,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 为当前用户创建访问令牌
+func CreateAccessTokenView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	var form *forms.CreateAccessTokenForm = &forms.CreateAccessTokenForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateAccessTokenService{
+			CreateAccessTokenForm: form,
+			C:                     c,
+			Username:              username,
+			Creator:               username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "令牌只显示一次，请妥善保存")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 吊销当前用户的访问令牌
+func RevokeAccessTokenView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.RevokeAccessTokenService{
+		C:        c,
+		ID:       uint64(id),
+		Username: username,
+		Operator: username,
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
+
+// 获取当前用户令牌的使用记录
+func GetAccessTokenLogsView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	var form *forms.GetAccessTokenLogsForm = &forms.GetAccessTokenLogsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetAccessTokenLogsService{
+			GetAccessTokenLogsForm: form,
+			C:                      c,
+			Username:               username,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 管理员获取所有访问令牌
+func AdminGetAccessTokensView(c *gin.Context) {
+	var form *forms.GetAccessTokensForm = &forms.GetAccessTokensForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetAccessTokensService{
+			GetAccessTokensForm: form,
+			C:                   c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 管理员为服务账号等用户创建访问令牌
+func AdminCreateAccessTokenView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	var form *forms.AdminCreateAccessTokenForm = &forms.AdminCreateAccessTokenForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.CreateAccessTokenService{
+			CreateAccessTokenForm: &form.CreateAccessTokenForm,
+			C:                     c,
+			Username:              form.Username,
+			Creator:               username,
+		}
+		returnData, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.Success(c, returnData, "令牌只显示一次，请妥善保存")
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
+
+// 管理员吊销访问令牌
+func AdminRevokeAccessTokenView(c *gin.Context) {
+	username := jwt.ExtractClaims(c)["id"].(string)
+	id, _ := strconv.Atoi(c.Param("id"))
+	service := services.RevokeAccessTokenService{
+		C:        c,
+		ID:       uint64(id),
+		Operator: username,
+	}
+	if err := service.Run(); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil, "success")
+}
+
+// 管理员获取所有令牌的使用记录
+func AdminGetAccessTokenLogsView(c *gin.Context) {
+	var form *forms.GetAccessTokenLogsForm = &forms.GetAccessTokenLogsForm{}
+	if err := c.ShouldBind(&form); err == nil {
+		service := services.GetAccessTokenLogsService{
+			GetAccessTokenLogsForm: form,
+			C:                      c,
+		}
+		returnData, total, err := service.Run()
+		if err != nil {
+			response.Fail(c, err.Error())
+			return
+		}
+		response.PaginationSuccess(c, total, returnData)
+	} else {
+		response.ValidateFail(c, err.Error())
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"encoding/json"
+	"goInsight/global"
+	userModels "goInsight/internal/users/models"
+	"goInsight/pkg/utils"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-contrib/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 访问令牌的请求头格式：Authorization: Token <token>
+const accessTokenHeadName = "Token"
+
+func accessTokenFromHeader(c *gin.Context) string {
+	parts := strings.SplitN(strings.TrimSpace(c.GetHeader("Authorization")), " ", 2)
+	if len(parts) != 2 || parts[0] != accessTokenHeadName {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// 检查访问令牌是否有效，返回认证失败的原因
+func checkAccessToken(token userModels.InsightAccessTokens, scope string) string {
+	if token.RevokedAt != nil {
+		return "访问令牌已吊销"
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return "访问令牌已过期"
+	}
+	var scopes []string
+	if err := json.Unmarshal(token.Scopes, &scopes); err != nil || !utils.IsContain(scopes, scope) {
+		return "访问令牌没有" + scope + "权限"
+	}
+	var user userModels.InsightUsers
+	tx := global.App.DB.Table("insight_users u").Where("u.username=?", token.Username).Scan(&user)
+	if tx.RowsAffected == 0 || !user.IsActive {
+		return "访问令牌所属用户不存在或已禁用"
+	}
+	return ""
+}
+
+// AccessTokenMiddleware 使用访问令牌认证，认证后和JWT一样通过claims["id"]获取用户名
+// 每次使用都记录审计日志
+func AccessTokenMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		abort := func(code int, msg string) {
+			c.AbortWithStatusJSON(code, gin.H{"code": code, "msg": msg, "data": nil, "request_id": requestid.Get(c)})
+		}
+		plain := accessTokenFromHeader(c)
+		if plain == "" {
+			abort(401, "缺少访问令牌")
+			return
+		}
+		var token userModels.InsightAccessTokens
+		tx := global.App.DB.Model(&userModels.InsightAccessTokens{}).
+			Where("token_hash=?", userModels.HashAccessToken(plain)).
+			Take(&token)
+		if tx.RowsAffected == 0 {
+			global.App.Log.WithFields(logrus.Fields{"request_id": requestid.Get(c), "client_ip": c.ClientIP()}).Error("无效的访问令牌")
+			abort(401, "无效的访问令牌")
+			return
+		}
+		msg := checkAccessToken(token, scope)
+		if msg != "" {
+			abort(403, msg)
+		} else {
+			c.Set("JWT_PAYLOAD", jwt.MapClaims{identityKey: token.Username, "token_id": token.ID})
+			c.Next()
+		}
+		// 审计日志
+		now := time.Now()
+		path := c.Request.URL.RequestURI()
+		if len(path) > 1024 {
+			path = path[:1024]
+		}
+		if err := global.App.DB.Create(&userModels.InsightAccessTokenLogs{
+			TokenID:    token.ID,
+			Username:   token.Username,
+			Method:     c.Request.Method,
+			Path:       path,
+			ClientIP:   c.ClientIP(),
+			StatusCode: c.Writer.Status(),
+			Msg:        msg,
+			RequestID:  requestid.Get(c),
+		}).Error; err != nil {
+			global.App.Log.Error(err)
+		}
+		global.App.DB.Model(&userModels.InsightAccessTokens{}).
+			Where("id=?", token.ID).
+			Updates(map[string]interface{}{"last_used_at": &now, "last_used_ip": c.ClientIP()})
+	}
+}
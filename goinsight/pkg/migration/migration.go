@@ -0,0 +1,172 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Script 版本化的迁移脚本
+type Script struct {
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	File        string `json:"file"`     // 脚本在仓库中的路径
+	Checksum    string `json:"checksum"` // 脚本内容的SHA256
+	Content     string `json:"-"`
+}
+
+var (
+	// Flyway：V1.2__add_index.sql，V1_2__add_index.sql
+	flywayRegexp = regexp.MustCompile(`^V([0-9]+(?:[._][0-9]+)*)__(.*)\.sql$`)
+	// golang-migrate：000001_add_index.up.sql，20240101120000_add_index.up.sql
+	migrateRegexp = regexp.MustCompile(`^([0-9]+)_(.*)\.up\.sql$`)
+)
+
+// ParseFileName 从脚本文件名中解析版本号和描述，不是迁移脚本时返回false
+// Flyway的可重复执行脚本(R__)、撤销脚本(U)和golang-migrate的down脚本会被忽略
+func ParseFileName(name string) (version, description string, ok bool) {
+	if m := flywayRegexp.FindStringSubmatch(name); m != nil {
+		return strings.ReplaceAll(m[1], "_", "."), strings.ReplaceAll(m[2], "_", " "), true
+	}
+	if m := migrateRegexp.FindStringSubmatch(name); m != nil {
+		return m[1], strings.ReplaceAll(m[2], "_", " "), true
+	}
+	return "", "", false
+}
+
+// CompareVersions 按数字逐段比较版本号，1.10大于1.9，000002等于2
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y uint64
+		if i < len(as) {
+			x, _ = strconv.ParseUint(as[i], 10, 64)
+		}
+		if i < len(bs) {
+			y, _ = strconv.ParseUint(bs[i], 10, 64)
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Repository 本地git仓库，通过git命令读取指定提交中的文件，不依赖工作区的状态
+type Repository struct {
+	Dir     string
+	GitPath string
+}
+
+// OpenRepository 打开根目录下的仓库，拒绝根目录之外的路径
+func OpenRepository(root, name, gitPath string) (*Repository, error) {
+	if root == "" {
+		return nil, errors.New("没有配置迁移脚本仓库的根目录")
+	}
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Join(rootAbs, name))
+	if err != nil {
+		return nil, err
+	}
+	if dir != rootAbs && !strings.HasPrefix(dir, rootAbs+string(filepath.Separator)) {
+		return nil, fmt.Errorf("仓库`%s`不在根目录%s下", name, root)
+	}
+	if gitPath == "" {
+		gitPath = "git"
+	}
+	return &Repository{Dir: dir, GitPath: gitPath}, nil
+}
+
+func (r *Repository) git(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.GitPath, append([]string{"-C", r.Dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("执行git %s失败：%s %s", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// ResolveCommit 解析分支、标签或提交为完整的SHA，为空时使用HEAD
+func (r *Repository) ResolveCommit(ref string) (string, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("版本引用`%s`不合法", ref)
+	}
+	out, err := r.git("rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Scripts 返回提交中目录下版本在[from, to]范围内的迁移脚本，按版本升序排列，from和to为空时不限制
+func (r *Repository) Scripts(commit, dir, from, to string) ([]Script, error) {
+	dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+	args := []string{"ls-tree", "--name-only", commit}
+	if dir != "" {
+		args = append(args, dir+"/")
+	}
+	out, err := r.git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var scripts []Script
+	versions := make(map[string]string)
+	for _, file := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if file == "" {
+			continue
+		}
+		version, description, ok := ParseFileName(path.Base(file))
+		if !ok {
+			continue
+		}
+		if (from != "" && CompareVersions(version, from) < 0) || (to != "" && CompareVersions(version, to) > 0) {
+			continue
+		}
+		for v, f := range versions {
+			if CompareVersions(v, version) == 0 {
+				return nil, fmt.Errorf("脚本%s和%s的版本号重复", f, file)
+			}
+		}
+		versions[version] = file
+		content, err := r.git("show", commit+":"+file)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		scripts = append(scripts, Script{
+			Version:     version,
+			Description: description,
+			File:        file,
+			Checksum:    hex.EncodeToString(sum[:]),
+			Content:     string(content),
+		})
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		return CompareVersions(scripts[i].Version, scripts[j].Version) < 0
+	})
+	return scripts, nil
+}
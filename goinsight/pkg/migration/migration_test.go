@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		description string
+		ok          bool
+	}{
+		{"V1.2__add_index.sql", "1.2", "add index", true},
+		{"V1_10__add_column.sql", "1.10", "add column", true},
+		{"000003_create_users.up.sql", "000003", "create users", true},
+		{"000003_create_users.down.sql", "", "", false},
+		{"R__views.sql", "", "", false},
+		{"U1.2__add_index.sql", "", "", false},
+		{"README.md", "", "", false},
+	}
+	for _, tt := range tests {
+		version, description, ok := ParseFileName(tt.name)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.version, version, tt.name)
+		assert.Equal(t, tt.description, description, tt.name)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 1, CompareVersions("1.10", "1.9"))
+	assert.Equal(t, 0, CompareVersions("000002", "2"))
+	assert.Equal(t, 0, CompareVersions("1.0", "1"))
+	assert.Equal(t, -1, CompareVersions("1", "1.0.1"))
+}
+
+func TestRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, "app")
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "db"), 0755))
+	run("init", "-q")
+	files := map[string]string{
+		"db/V1__init.sql":      "create table t1(id int);",
+		"db/V2__t2.sql":        "create table t2(id int);",
+		"db/V10__t10.sql":      "create table t10(id int);",
+		"db/R__views.sql":      "create view v as select 1;",
+		"other/V3__skip.sql":   "select 1;",
+		"db/V2_1__t2_idx.sql":  "alter table t2 add index idx(id);",
+		"db/notes/V4__sub.sql": "select 1;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+	// 工作区的修改不影响读取的内容
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "db/V1__init.sql"), []byte("dirty"), 0644))
+
+	repo, err := OpenRepository(root, "app", "")
+	assert.NoError(t, err)
+	commit, err := repo.ResolveCommit("")
+	assert.NoError(t, err)
+	assert.Len(t, commit, 40)
+
+	scripts, err := repo.Scripts(commit, "db", "", "")
+	assert.NoError(t, err)
+	var versions []string
+	for _, s := range scripts {
+		versions = append(versions, s.Version)
+	}
+	assert.Equal(t, []string{"1", "2", "2.1", "10"}, versions)
+	assert.Equal(t, "create table t1(id int);", scripts[0].Content)
+
+	scripts, err = repo.Scripts(commit, "/db/", "2", "2.1")
+	assert.NoError(t, err)
+	assert.Len(t, scripts, 2)
+
+	_, err = OpenRepository(root, "../etc", "")
+	assert.Error(t, err)
+	_, err = repo.ResolveCommit("--help")
+	assert.Error(t, err)
+	_, err = repo.ResolveCommit("no-such-branch")
+	assert.Error(t, err)
+}
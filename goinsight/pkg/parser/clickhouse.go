@@ -0,0 +1,266 @@
+/*
+@Desc    :   ClickHouse语句的拆分和分类，tidb parser无法解析ClickHouse语法，使用词法分析实现
+*/
+
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ClickHouseStmt ClickHouse语句的基本信息
+type ClickHouseStmt struct {
+	Text     string
+	Type     string // DML/DDL/EXPORT，无法识别时为空
+	Kind     string // 语句的第一个关键字，例如CREATE/ALTER/INSERT
+	Schema   string
+	Table    string
+	Cluster  string // ON CLUSTER指定的集群
+	Mutation bool   // 是否会产生mutation，例如ALTER TABLE ... UPDATE/DELETE、DELETE FROM
+}
+
+type chToken struct {
+	value  string
+	quoted bool // 反引号、双引号的标识符或者单引号的字符串
+}
+
+// 拆分为语句文本和token，忽略注释
+func scanClickHouse(sqltext string) (texts []string, tokens [][]chToken, err error) {
+	var (
+		runes  = []rune(sqltext)
+		start  = 0
+		cur    []chToken
+		n      = len(runes)
+		finish = func(end int) {
+			text := strings.TrimSpace(string(runes[start:end]))
+			if len(cur) > 0 {
+				texts = append(texts, text)
+				tokens = append(tokens, cur)
+			}
+			cur = nil
+			start = end + 1
+		}
+	)
+	for i := 0; i < n; i++ {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+		case c == '-' && i+1 < n && runes[i+1] == '-', c == '#' && i+1 < n && (runes[i+1] == ' ' || runes[i+1] == '!'):
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && runes[i+1] == '*':
+			for i += 2; i+1 < n && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			if i+1 >= n {
+				return nil, nil, errors.New("SQL解析错误:注释未闭合")
+			}
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			var b strings.Builder
+			closed := false
+			for i++; i < n; i++ {
+				if runes[i] == '\\' && i+1 < n {
+					b.WriteRune(runes[i+1])
+					i++
+					continue
+				}
+				if runes[i] == c {
+					// 两个连续的引号表示引号本身
+					if i+1 < n && runes[i+1] == c {
+						b.WriteRune(c)
+						i++
+						continue
+					}
+					closed = true
+					break
+				}
+				b.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, nil, fmt.Errorf("SQL解析错误:引号%c未闭合", c)
+			}
+			cur = append(cur, chToken{value: b.String(), quoted: true})
+		case c == ';':
+			finish(i)
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '$':
+			j := i
+			for j < n && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$') {
+				j++
+			}
+			cur = append(cur, chToken{value: string(runes[i:j])})
+			i = j - 1
+		default:
+			cur = append(cur, chToken{value: string(c)})
+		}
+	}
+	finish(n)
+	return texts, tokens, nil
+}
+
+// 判断token是否为指定的关键字
+func (t chToken) is(keyword string) bool {
+	return !t.quoted && strings.EqualFold(t.value, keyword)
+}
+
+// SplitClickHouseSQLText 按照分号拆分ClickHouse语句，忽略引号和注释中的分号
+func SplitClickHouseSQLText(sqltext string) ([]string, error) {
+	texts, _, err := scanClickHouse(sqltext)
+	if err != nil {
+		return nil, err
+	}
+	if len(texts) == 0 {
+		return nil, errors.New("SQL解析错误:未解析到SQL语句")
+	}
+	return texts, nil
+}
+
+// ParseClickHouseSQLText 拆分并识别每条ClickHouse语句
+func ParseClickHouseSQLText(sqltext string) ([]ClickHouseStmt, error) {
+	texts, tokens, err := scanClickHouse(sqltext)
+	if err != nil {
+		return nil, err
+	}
+	var stmts []ClickHouseStmt
+	for i := range texts {
+		stmts = append(stmts, classifyClickHouse(texts[i], tokens[i]))
+	}
+	return stmts, nil
+}
+
+// ParseClickHouseStmt 识别一条ClickHouse语句
+func ParseClickHouseStmt(sqltext string) (ClickHouseStmt, error) {
+	stmts, err := ParseClickHouseSQLText(sqltext)
+	if err != nil {
+		return ClickHouseStmt{}, err
+	}
+	if len(stmts) != 1 {
+		return ClickHouseStmt{}, fmt.Errorf("SQL解析错误:期望1条SQL语句，实际为%d条", len(stmts))
+	}
+	return stmts[0], nil
+}
+
+func classifyClickHouse(text string, tokens []chToken) ClickHouseStmt {
+	stmt := ClickHouseStmt{Text: text}
+	// 跳过开头的括号，例如(SELECT ...) UNION ALL (SELECT ...)
+	pos := 0
+	for pos < len(tokens) && tokens[pos].value == "(" && !tokens[pos].quoted {
+		pos++
+	}
+	if pos >= len(tokens) {
+		return stmt
+	}
+	stmt.Kind = strings.ToUpper(tokens[pos].value)
+	rest := tokens[pos+1:]
+	// 读取[db.]table，跳过前面的关键字
+	readTable := func(ts []chToken, skip ...string) {
+		for len(ts) > 0 {
+			matched := false
+			for _, k := range skip {
+				if ts[0].is(k) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				break
+			}
+			ts = ts[1:]
+		}
+		if len(ts) == 0 {
+			return
+		}
+		stmt.Table = ts[0].value
+		if len(ts) >= 3 && ts[1].value == "." && !ts[1].quoted {
+			stmt.Schema, stmt.Table = ts[0].value, ts[2].value
+		}
+	}
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].is("ON") && tokens[i+1].is("CLUSTER") {
+			stmt.Cluster = tokens[i+2].value
+			break
+		}
+	}
+
+	switch stmt.Kind {
+	case "SELECT", "WITH":
+		stmt.Type = "EXPORT"
+	case "INSERT":
+		stmt.Type = "DML"
+		readTable(rest, "INTO", "TABLE")
+	case "DELETE":
+		// 轻量级删除
+		stmt.Type = "DML"
+		stmt.Mutation = true
+		readTable(rest, "FROM")
+	case "UPDATE":
+		// 轻量级更新
+		stmt.Type = "DML"
+		stmt.Mutation = true
+		readTable(rest)
+	case "ALTER":
+		stmt.Type = "DDL"
+		if len(rest) == 0 || !rest[0].is("TABLE") {
+			break
+		}
+		readTable(rest, "TABLE")
+		// ALTER TABLE [db.]table [ON CLUSTER cluster] UPDATE/DELETE ...
+		// 修改列等操作同样可能产生mutation，都需要跟踪
+		stmt.Mutation = true
+		cmd := rest[1:]
+		if len(cmd) >= 2 && cmd[1].value == "." && !cmd[1].quoted {
+			cmd = cmd[3:]
+		} else if len(cmd) > 0 {
+			cmd = cmd[1:]
+		}
+		if len(cmd) >= 3 && cmd[0].is("ON") && cmd[1].is("CLUSTER") {
+			cmd = cmd[3:]
+		}
+		if len(cmd) > 0 && (cmd[0].is("UPDATE") || cmd[0].is("DELETE")) {
+			stmt.Type = "DML"
+		}
+	case "CREATE", "DROP", "TRUNCATE", "RENAME", "EXCHANGE", "OPTIMIZE":
+		stmt.Type = "DDL"
+		readTable(rest, "OR", "REPLACE", "TEMPORARY", "TABLE", "DATABASE", "VIEW", "MATERIALIZED", "DICTIONARY", "IF", "NOT", "EXISTS", "TABLES")
+		if len(rest) > 0 && rest[0].is("DATABASE") {
+			stmt.Schema, stmt.Table = stmt.Table, ""
+		}
+	}
+	return stmt
+}
+
+// CheckClickHouseSqlType 检查ClickHouse语句的类型和数量
+func CheckClickHouseSqlType(sqltext, sqltype string) error {
+	stmts, err := ParseClickHouseSQLText(sqltext)
+	if err != nil {
+		return err
+	}
+	if len(stmts) == 0 {
+		return errors.New("SQL解析错误:未解析到SQL语句")
+	}
+	if len(stmts) > 2048 {
+		return fmt.Errorf("单次最大允许提交2048条SQL，当前SQL语句条数为%d", len(stmts))
+	}
+	for _, stmt := range stmts {
+		if stmt.Type == "" {
+			return fmt.Errorf("不支持的ClickHouse语句：%s", stmt.Kind)
+		}
+		if stmt.Kind == "DROP" && stmt.Table == "" && stmt.Schema != "" {
+			return errors.New("禁止提交DROP DATABASE语句")
+		}
+		if stmt.Type != sqltype {
+			switch sqltype {
+			case "DML":
+				return fmt.Errorf("DML模式下，不允许提交%s语句", stmt.Type)
+			case "DDL":
+				return fmt.Errorf("DDL模式下，不允许提交%s语句", stmt.Type)
+			case "EXPORT":
+				return fmt.Errorf("EXPORT模式下，不允许提交%s语句，仅允许提交SELECT语句", stmt.Type)
+			}
+		}
+	}
+	return nil
+}
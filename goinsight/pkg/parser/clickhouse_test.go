@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitClickHouseSQLText(t *testing.T) {
+	sqls, err := SplitClickHouseSQLText(`INSERT INTO t VALUES ('a;b', 1); -- comment;
+/* x; */ ALTER TABLE t DELETE WHERE s = 'it''s;';
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"INSERT INTO t VALUES ('a;b', 1)",
+		"-- comment;\n/* x; */ ALTER TABLE t DELETE WHERE s = 'it''s;'",
+	}, sqls)
+
+	_, err = SplitClickHouseSQLText("SELECT 'abc")
+	assert.Error(t, err)
+}
+
+func TestParseClickHouseStmt(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want ClickHouseStmt
+	}{
+		{"SELECT * FROM db.t", ClickHouseStmt{Type: "EXPORT", Kind: "SELECT"}},
+		{"WITH 1 AS x SELECT x", ClickHouseStmt{Type: "EXPORT", Kind: "WITH"}},
+		{"INSERT INTO db.t SELECT 1", ClickHouseStmt{Type: "DML", Kind: "INSERT", Schema: "db", Table: "t"}},
+		{"alter table `db`.`t` on cluster 'c1' update a=1 where id=1", ClickHouseStmt{Type: "DML", Kind: "ALTER", Schema: "db", Table: "t", Cluster: "c1", Mutation: true}},
+		{"ALTER TABLE t DELETE WHERE id=1", ClickHouseStmt{Type: "DML", Kind: "ALTER", Table: "t", Mutation: true}},
+		{"ALTER TABLE t ON CLUSTER c1 MODIFY COLUMN a String", ClickHouseStmt{Type: "DDL", Kind: "ALTER", Table: "t", Cluster: "c1", Mutation: true}},
+		{"DELETE FROM db.t WHERE id=1", ClickHouseStmt{Type: "DML", Kind: "DELETE", Schema: "db", Table: "t", Mutation: true}},
+		{"CREATE TABLE IF NOT EXISTS db.t ON CLUSTER c1 (id UInt64) ENGINE=MergeTree ORDER BY id", ClickHouseStmt{Type: "DDL", Kind: "CREATE", Schema: "db", Table: "t", Cluster: "c1"}},
+		{"DROP DATABASE db", ClickHouseStmt{Type: "DDL", Kind: "DROP", Schema: "db"}},
+		{"SYSTEM STOP MERGES", ClickHouseStmt{Kind: "SYSTEM"}},
+	}
+	for _, tt := range tests {
+		stmt, err := ParseClickHouseStmt(tt.sql)
+		assert.NoError(t, err, tt.sql)
+		tt.want.Text = tt.sql
+		assert.Equal(t, tt.want, stmt, tt.sql)
+	}
+}
+
+func TestCheckClickHouseSqlType(t *testing.T) {
+	assert.NoError(t, CheckClickHouseSqlType("ALTER TABLE t UPDATE a=1 WHERE 1;INSERT INTO t VALUES (1)", "DML"))
+	assert.Error(t, CheckClickHouseSqlType("ALTER TABLE t ADD COLUMN a String", "DML"))
+	assert.Error(t, CheckClickHouseSqlType("DROP DATABASE db", "DDL"))
+	assert.Error(t, CheckClickHouseSqlType("SYSTEM STOP MERGES", "DDL"))
+}
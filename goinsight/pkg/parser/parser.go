@@ -165,3 +165,76 @@ func GetTableNameFromAlterStatement(sqltext string) (string, error) {
 	}
 	return "", errors.New("未提取到表名")
 }
+
+// 获取单表查询的表名，多表查询或者非查询语句返回错误
+func GetTableNameFromSelectStatement(sqltext string) (string, error) {
+	stmt, err := NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return "", err
+	}
+	if s, ok := stmt.(*ast.SelectStmt); ok && s.From != nil && s.From.TableRefs != nil && s.From.TableRefs.Right == nil {
+		if source, ok := s.From.TableRefs.Left.(*ast.TableSource); ok {
+			if table, ok := source.Source.(*ast.TableName); ok {
+				return table.Name.String(), nil
+			}
+		}
+	}
+	return "", errors.New("未提取到表名")
+}
+
+// TableNameCollector 收集节点中引用的所有表，包括子查询和CTE引用的表
+type TableNameCollector struct {
+	Tables []*ast.TableName
+}
+
+func (v *TableNameCollector) Enter(in ast.Node) (ast.Node, bool) {
+	if t, ok := in.(*ast.TableName); ok {
+		v.Tables = append(v.Tables, t)
+	}
+	return in, false
+}
+
+func (v *TableNameCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// 获取DML和DDL语句修改的表，格式为schema.table，没有指定库名时使用defaultSchema
+// 多表关联的UPDATE/DELETE返回所有关联的表
+func ExtractModifiedTables(sqltext, defaultSchema string) ([]string, error) {
+	stmt, err := NewParseOneStmt(sqltext, "", "")
+	if err != nil {
+		return nil, err
+	}
+	var node ast.Node
+	switch s := stmt.(type) {
+	case *ast.InsertStmt:
+		node = s.Table
+	case *ast.UpdateStmt:
+		node = s.TableRefs
+	case *ast.DeleteStmt:
+		node = s.TableRefs
+	case *ast.CreateTableStmt:
+		node = s.Table
+	case *ast.AlterTableStmt, *ast.DropTableStmt, *ast.TruncateTableStmt, *ast.RenameTableStmt, *ast.CreateIndexStmt, *ast.DropIndexStmt:
+		node = s
+	default:
+		return nil, nil
+	}
+	if node == nil {
+		return nil, nil
+	}
+	v := &TableNameCollector{}
+	node.Accept(v)
+	var tables []string
+	for _, t := range v.Tables {
+		schema := t.Schema.O
+		if schema == "" {
+			schema = defaultSchema
+		}
+		name := fmt.Sprintf("%s.%s", schema, t.Name.O)
+		if !utils.IsContain(tables, name) {
+			tables = append(tables, name)
+		}
+	}
+	return tables, nil
+}
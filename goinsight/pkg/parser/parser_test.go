@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractModifiedTables(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"insert into t1(id) select id from d2.t2", []string{"d1.t1"}},
+		{"update t1 a join d2.t2 b on a.id=b.id set a.c=1", []string{"d1.t1", "d2.t2"}},
+		{"delete from t1 where id in (select id from t3)", []string{"d1.t1"}},
+		{"alter table d2.t1 add column c int", []string{"d2.t1"}},
+		{"create table t4 like t1", []string{"d1.t4"}},
+		{"rename table t1 to t1_old", []string{"d1.t1", "d1.t1_old"}},
+		{"select * from t1", nil},
+	}
+	for _, tt := range tests {
+		got, err := ExtractModifiedTables(tt.sql, "d1")
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
@@ -0,0 +1,183 @@
+package snippet
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 参数类型
+const (
+	TypeString     = "string"     // 字符串，替换为转义后的'...'
+	TypeInt        = "int"        // 整数
+	TypeFloat      = "float"      // 浮点数
+	TypeIdentifier = "identifier" // 库名、表名、列名，替换为`...`
+	TypeDate       = "date"       // 日期，格式：2006-01-02
+	TypeDatetime   = "datetime"   // 时间，格式：2006-01-02 15:04:05
+)
+
+var types = map[string]bool{
+	TypeString:     true,
+	TypeInt:        true,
+	TypeFloat:      true,
+	TypeIdentifier: true,
+	TypeDate:       true,
+	TypeDatetime:   true,
+}
+
+// Param 片段参数的定义，没有默认值的参数在使用时必须传入
+type Param struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Pattern string `json:"pattern"` // 值需要满足的正则表达式，为空时不检查
+	Remark  string `json:"remark"`
+}
+
+// 占位符格式：{{name}}
+var placeholderRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var (
+	nameRegexp       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	identifierRegexp = regexp.MustCompile(`^[A-Za-z0-9_$\p{Han}]{1,64}$`)
+)
+
+var valueReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\x00", `\0`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x1a", `\Z`,
+)
+
+// Placeholders 返回SQL片段中的占位符，按首次出现的顺序去重
+func Placeholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Validate 检查SQL片段和参数定义：占位符都有定义、参数名不重复、类型和正则表达式合法、默认值满足类型
+func Validate(content string, params []Param) error {
+	defined := make(map[string]Param)
+	for _, p := range params {
+		if !nameRegexp.MatchString(p.Name) {
+			return fmt.Errorf("参数名`%s`不合法，只能包含字母、数字和下划线，且不能以数字开头", p.Name)
+		}
+		if _, ok := defined[p.Name]; ok {
+			return fmt.Errorf("参数`%s`重复定义", p.Name)
+		}
+		if p.Pattern != "" {
+			if _, err := regexp.Compile(p.Pattern); err != nil {
+				return fmt.Errorf("参数`%s`的正则表达式错误：%s", p.Name, err.Error())
+			}
+		}
+		if !types[p.Type] {
+			return fmt.Errorf("参数`%s`的类型`%s`不支持", p.Name, p.Type)
+		}
+		if p.Default != "" {
+			if _, err := format(p, p.Default); err != nil {
+				return fmt.Errorf("参数`%s`的默认值错误：%s", p.Name, err.Error())
+			}
+		}
+		defined[p.Name] = p
+	}
+	for _, name := range Placeholders(content) {
+		if _, ok := defined[name]; !ok {
+			return fmt.Errorf("占位符`{{%s}}`没有定义参数", name)
+		}
+	}
+	return nil
+}
+
+// 按参数类型校验并格式化参数值
+func format(p Param, value string) (string, error) {
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(value) {
+			return "", fmt.Errorf("值`%s`不满足正则表达式%s", value, p.Pattern)
+		}
+	}
+	switch p.Type {
+	case TypeString:
+		return "'" + valueReplacer.Replace(value) + "'", nil
+	case TypeInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("值`%s`不是合法的整数格式", value)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case TypeFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("值`%s`不是合法的浮点数格式", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case TypeIdentifier:
+		if !identifierRegexp.MatchString(value) {
+			return "", fmt.Errorf("值`%s`不是合法的标识符格式", value)
+		}
+		return "`" + value + "`", nil
+	case TypeDate:
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "", fmt.Errorf("值`%s`不是合法的日期格式(2006-01-02)", value)
+		}
+		return "'" + value + "'", nil
+	case TypeDatetime:
+		if _, err := time.Parse("2006-01-02 15:04:05", value); err != nil {
+			return "", fmt.Errorf("值`%s`不是合法的时间格式(2006-01-02 15:04:05)", value)
+		}
+		return "'" + value + "'", nil
+	}
+	return "", fmt.Errorf("参数`%s`的类型`%s`不支持", p.Name, p.Type)
+}
+
+// Render 校验参数值并替换SQL片段中的占位符，值按照参数类型转义，不会改变语句结构
+func Render(content string, params []Param, values map[string]string) (string, error) {
+	if err := Validate(content, params); err != nil {
+		return "", err
+	}
+	formatted := make(map[string]string, len(params))
+	for _, p := range params {
+		value, ok := values[p.Name]
+		if !ok || value == "" {
+			value = p.Default
+		}
+		if value == "" {
+			// 允许字符串参数显式传入空值
+			if _, ok := values[p.Name]; !ok || p.Type != TypeString {
+				return "", fmt.Errorf("参数`%s`的值不能为空", p.Name)
+			}
+		}
+		v, err := format(p, value)
+		if err != nil {
+			return "", fmt.Errorf("参数`%s`校验失败：%s", p.Name, err.Error())
+		}
+		formatted[p.Name] = v
+	}
+	for name := range values {
+		if _, ok := formatted[name]; !ok {
+			return "", fmt.Errorf("参数`%s`没有定义", name)
+		}
+	}
+	result := placeholderRegexp.ReplaceAllStringFunc(content, func(s string) string {
+		return formatted[placeholderRegexp.FindStringSubmatch(s)[1]]
+	})
+	if strings.TrimSpace(result) == "" {
+		return "", errors.New("替换后的SQL为空")
+	}
+	return result, nil
+}
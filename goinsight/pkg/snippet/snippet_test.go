@@ -0,0 +1,51 @@
+package snippet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	params := []Param{
+		{Name: "table", Type: TypeIdentifier},
+		{Name: "name", Type: TypeString},
+		{Name: "id", Type: TypeInt, Default: "10"},
+		{Name: "day", Type: TypeDate, Pattern: `^2024-`},
+	}
+	content := "select * from {{table}} where name={{ name }} and id>{{id}} and created_at>{{day}}"
+	tests := []struct {
+		values  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{map[string]string{"table": "users", "name": "a'b", "day": "2024-01-01"},
+			"select * from `users` where name='a\\'b' and id>10 and created_at>'2024-01-01'", false},
+		{map[string]string{"table": "users", "name": "", "id": "3", "day": "2024-01-01"},
+			"select * from `users` where name='' and id>3 and created_at>'2024-01-01'", false},
+		// 标识符不能包含反引号
+		{map[string]string{"table": "users` where 1=1 --", "name": "a", "day": "2024-01-01"}, "", true},
+		{map[string]string{"table": "users", "name": "a", "id": "1 or 1=1", "day": "2024-01-01"}, "", true},
+		{map[string]string{"table": "users", "name": "a", "day": "2023-01-01"}, "", true},
+		{map[string]string{"table": "users", "day": "2024-01-01"}, "", true},
+		{map[string]string{"table": "users", "name": "a", "day": "2024-01-01", "extra": "1"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := Render(content, params, tt.values)
+		if tt.wantErr {
+			assert.Error(t, err, tt.values)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("select {{a}}, {{a}}", []Param{{Name: "a", Type: TypeInt}}))
+	assert.Error(t, Validate("select {{a}}, {{b}}", []Param{{Name: "a", Type: TypeInt}}))
+	assert.Error(t, Validate("select {{a}}", []Param{{Name: "a", Type: "json"}}))
+	assert.Error(t, Validate("select {{a}}", []Param{{Name: "a", Type: TypeInt}, {Name: "a", Type: TypeInt}}))
+	assert.Error(t, Validate("select {{a}}", []Param{{Name: "a", Type: TypeInt, Default: "x"}}))
+	assert.Equal(t, []string{"b", "a"}, Placeholders("{{b}} {{a}} {{ b }}"))
+}
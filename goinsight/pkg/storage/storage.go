@@ -0,0 +1,44 @@
+/*
+@Desc    :   导出文件的存储，支持本地磁盘和兼容S3协议的对象存储
+*/
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"goInsight/config"
+	"io"
+)
+
+// Storage 文件存储接口，key为文件在存储中的名称
+type Storage interface {
+	// Name 返回存储类型，local/s3
+	Name() string
+	// Put 上传文件
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取文件，返回文件内容和大小
+	Get(ctx context.Context, key string) (io.ReadCloser, int64, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// New 根据配置创建存储，未配置时使用本地磁盘
+func New(cfg config.Export) (Storage, error) {
+	return Open(cfg.Storage, cfg)
+}
+
+// Open 创建指定类型的存储，用于读取切换存储类型之前保存的文件
+func Open(name string, cfg config.Export) (Storage, error) {
+	switch name {
+	case "", "local":
+		dir := cfg.LocalDir
+		if dir == "" {
+			dir = "./media/export"
+		}
+		return NewLocalStorage(dir), nil
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	}
+	return nil, fmt.Errorf("不支持的存储类型：%s", name)
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"goInsight/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟S3协议的对象存储，只实现PutObject/GetObject/RemoveObject
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// 解析aws-chunked编码的请求体，非TLS连接时minio-go使用流式签名上传
+func decodeAWSChunked(r io.Reader) ([]byte, error) {
+	var out bytes.Buffer
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, br, size); err != nil {
+			return nil, err
+		}
+		br.Discard(2)
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		var data []byte
+		var err error
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			data, err = decodeAWSChunked(r.Body)
+		} else {
+			data, err = io.ReadAll(r.Body)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+	content := []byte("export file content")
+	assert.NoError(t, store.Put(ctx, "a.csv.zip", bytes.NewReader(content), int64(len(content))))
+
+	r, size, err := store.Get(ctx, "a.csv.zip")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, int64(len(content)), size)
+	assert.Equal(t, content, data)
+
+	assert.NoError(t, store.Delete(ctx, "a.csv.zip"))
+	_, _, err = store.Get(ctx, "a.csv.zip")
+	assert.Error(t, err)
+}
+
+func TestLocalStorage(t *testing.T) {
+	store, err := New(config.Export{Storage: "local", LocalDir: t.TempDir()})
+	assert.NoError(t, err)
+	testStorage(t, store)
+	// 删除不存在的文件不返回错误
+	assert.NoError(t, store.Delete(context.Background(), "missing.zip"))
+	assert.Error(t, store.Put(context.Background(), "../a.zip", strings.NewReader(""), 0))
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := New(config.Export{Storage: "s3", S3: config.S3{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "goinsight",
+		Prefix:    "export/",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}})
+	assert.NoError(t, err)
+	testStorage(t, store)
+
+	// 对象名包含前缀
+	assert.NoError(t, store.Put(context.Background(), "b.zip", strings.NewReader("b"), 1))
+	assert.Contains(t, fake.objects, "/goinsight/export/b.zip")
+
+	_, err = New(config.Export{Storage: "ftp"})
+	assert.Error(t, err)
+}
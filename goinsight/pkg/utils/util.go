@@ -17,14 +17,30 @@ func GetFileSize(filePath string) (int64, error) {
 }
 
 func EncryptAndTarGzFiles(inputFile, outputFile, filePath, key string) error {
+	return EncryptAndZipFiles([]string{inputFile}, outputFile, filePath, key)
+}
+
+// 将多个文件加密压缩到同一个zip文件中
+func EncryptAndZipFiles(inputFiles []string, outputFile, filePath, key string) error {
 	// 创建zip文件
 	fzip, err := os.Create(fmt.Sprintf("%s/%s", filePath, outputFile))
 	if err != nil {
 		return err
 	}
+	defer fzip.Close()
 	zipw := zip.NewWriter(fzip)
 	defer zipw.Close()
 
+	for _, inputFile := range inputFiles {
+		if err := encryptFileToZip(zipw, inputFile, filePath, key); err != nil {
+			return err
+		}
+	}
+	zipw.Flush()
+	return nil
+}
+
+func encryptFileToZip(zipw *zip.Writer, inputFile, filePath, key string) error {
 	// 打开待压缩的文件
 	f, err := os.Open(fmt.Sprintf("%s/%s", filePath, inputFile))
 	if err != nil {
@@ -39,9 +55,6 @@ func EncryptAndTarGzFiles(inputFile, outputFile, filePath, key string) error {
 	}
 
 	// 将文件内容复制到zip文件中
-	if _, err = io.Copy(w, f); err != nil {
-		return err
-	}
-	zipw.Flush()
-	return nil
+	_, err = io.Copy(w, f)
+	return err
 }
@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 请求头
+const (
+	HeaderEvent      = "X-GoInsight-Event"
+	HeaderDelivery   = "X-GoInsight-Delivery"
+	HeaderTimestamp  = "X-GoInsight-Timestamp"
+	HeaderSignature  = "X-GoInsight-Signature"
+	signaturePrefix  = "sha256="
+	maxResponseBytes = 1024
+)
+
+// 重试间隔，第n次失败后等待 baseBackoff * 2^(n-1)，最长maxBackoff
+var (
+	baseBackoff = 5 * time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// Request 一次投递请求
+type Request struct {
+	URL        string
+	Secret     string
+	Event      string
+	DeliveryID string
+	Body       []byte
+	Timeout    time.Duration
+}
+
+// Response 接收方的响应，ResponseBody最多保留1024字节
+type Response struct {
+	StatusCode   int
+	ResponseBody string
+}
+
+// Sign 计算签名，签名内容为"时间戳.请求体"，接收方需要使用相同的密钥校验
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名，供接收方和测试使用
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff 第attempt次投递失败后的等待时间
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Send 发送一次请求，响应状态码不是2xx时返回错误
+func Send(req Request) (*Response, error) {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := time.Now().Unix()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "goInsight-Webhook")
+	httpReq.Header.Set(HeaderEvent, req.Event)
+	httpReq.Header.Set(HeaderDelivery, req.DeliveryID)
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if req.Secret != "" {
+		httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, req.Body))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	result := &Response{StatusCode: resp.StatusCode, ResponseBody: string(body)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"created"}`)
+	sig := Sign("secret", 1700000000, body)
+	assert.Equal(t, "sha256=", sig[:7])
+	assert.Len(t, sig, 7+64)
+	assert.True(t, Verify("secret", 1700000000, body, sig))
+	assert.False(t, Verify("other", 1700000000, body, sig))
+	assert.False(t, Verify("secret", 1700000001, body, sig))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, Backoff(0))
+	assert.Equal(t, 5*time.Second, Backoff(1))
+	assert.Equal(t, 10*time.Second, Backoff(2))
+	assert.Equal(t, 40*time.Second, Backoff(4))
+	assert.Equal(t, 5*time.Minute, Backoff(20))
+}
+
+func TestSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if r.Header.Get(HeaderEvent) != "completed" || !Verify("secret", timestamp, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	req := Request{URL: server.URL, Secret: "secret", Event: "completed", DeliveryID: "1", Body: []byte(`{}`)}
+	resp, err := Send(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", resp.ResponseBody)
+
+	req.Secret = "wrong"
+	resp, err = Send(req)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}